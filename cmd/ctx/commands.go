@@ -2,25 +2,40 @@ package main
 
 import (
 	"fmt"
+	"io"
+	"net/http"
 	"os"
 	"os/exec"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/contextsubstrate/ctx/internal/cost"
 	"github.com/contextsubstrate/ctx/internal/delta"
+	"github.com/contextsubstrate/ctx/internal/fsck"
 	"github.com/contextsubstrate/ctx/internal/graph"
+	"github.com/contextsubstrate/ctx/internal/htmlreport"
 	ctxdiff "github.com/contextsubstrate/ctx/internal/diff"
 	"github.com/contextsubstrate/ctx/internal/index"
+	"github.com/contextsubstrate/ctx/internal/migrate"
 	"github.com/contextsubstrate/ctx/internal/optimize"
 	"github.com/contextsubstrate/ctx/internal/pack"
 	"github.com/contextsubstrate/ctx/internal/replay"
 	"github.com/contextsubstrate/ctx/internal/sharing"
 	"github.com/contextsubstrate/ctx/internal/store"
 	"github.com/contextsubstrate/ctx/internal/telemetry"
+	"github.com/contextsubstrate/ctx/internal/tui"
+	"github.com/contextsubstrate/ctx/internal/ui"
 	"github.com/contextsubstrate/ctx/internal/verify"
 	"github.com/spf13/cobra"
 )
 
 var diffHuman bool
+var diffFormat string
+var showFormat string
+var replayFormat string
+var diffMany bool
+var diffThreshold float64
 var indexCommit string
 var deltaBase string
 var deltaHead string
@@ -32,6 +47,27 @@ var optimizeIncludeTests bool
 var optimizeHuman bool
 var metricsLimit int
 var benchmarkCommits int
+var replayAll bool
+var replayModel string
+var replaySince string
+var replayUntil string
+var replayFromFile string
+var replayWorkers int
+var replayJSON bool
+var replayInteractive bool
+var costPrices string
+var costJSON bool
+var uiAddr string
+var lineageFormat string
+var fsckRehash bool
+var fsckJSON bool
+var fsckQuarantine bool
+var fsckFetch bool
+var fsckRemote string
+var migrateDryRun bool
+var repackAll bool
+var packKeyID string
+var keyNewDefault bool
 
 var initCmd = &cobra.Command{
 	Use:   "init",
@@ -54,7 +90,7 @@ var initCmd = &cobra.Command{
 var packCmd = &cobra.Command{
 	Use:   "pack <log-file>",
 	Short: "Create a context pack from an execution log",
-	Long:  "Read an execution log (JSON) and produce an immutable, content-addressed Context Pack.",
+	Long:  "Read an execution log (JSON) and produce an immutable, content-addressed Context Pack.\nSecrets and personal data are redacted unless disabled in .ctx/config.json.\nWith --key, or a default encryption_key in config.json, content is encrypted.",
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		root, err := store.DiscoverStore()
@@ -67,7 +103,7 @@ var packCmd = &cobra.Command{
 			return err
 		}
 
-		p, err := pack.CreatePack(root, log)
+		p, err := pack.CreatePackWithKey(root, log, packKeyID)
 		if err != nil {
 			return err
 		}
@@ -76,6 +112,14 @@ var packCmd = &cobra.Command{
 			return fmt.Errorf("registering pack: %w", err)
 		}
 
+		if len(p.Redactions) > 0 {
+			n := 0
+			for _, r := range p.Redactions {
+				n += r.Count
+			}
+			fmt.Fprintf(os.Stderr, "Redacted %d value(s); see ctx show for details\n", n)
+		}
+
 		_, hex, _ := store.ParseHash(p.Hash)
 		fmt.Printf("ctx://%s\n", hex)
 		return nil
@@ -97,29 +141,92 @@ var showCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
+		if err := pack.CheckKey(root, p); err != nil {
+			return err
+		}
 
-		fmt.Print(pack.FormatPack(p))
+		switch showFormat {
+		case "text":
+			fmt.Print(pack.FormatPack(p))
+		case "html":
+			data, err := htmlreport.Pack(root, p)
+			if err != nil {
+				return err
+			}
+			os.Stdout.Write(data)
+		default:
+			return fmt.Errorf("unknown format %q (expected text or html)", showFormat)
+		}
 		return nil
 	},
 }
 
 var replayCmd = &cobra.Command{
-	Use:   "replay <hash>",
+	Use:   "replay [hash]",
 	Short: "Replay a captured agent run",
-	Long:  "Re-execute an agent run step-by-step as recorded in the context pack.",
-	Args:  cobra.ExactArgs(1),
+	Long: `Re-execute an agent run step-by-step as recorded in the context pack.
+
+With --all, --model, --since, --until or --from-file, replays every selected
+pack concurrently and prints an aggregate fidelity report.
+
+With --interactive, pauses before each step so it can be run, skipped,
+replaced with the recorded output, or re-run with edited parameters.`,
+	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		root, err := store.DiscoverStore()
 		if err != nil {
 			return err
 		}
 
-		report, err := replay.Replay(root, args[0])
+		batch := replayAll || replayModel != "" || replaySince != "" || replayUntil != "" || replayFromFile != ""
+		if batch {
+			if len(args) > 0 {
+				return fmt.Errorf("cannot combine a pack hash with batch selection flags")
+			}
+			if replayInteractive {
+				return fmt.Errorf("--interactive cannot be combined with batch replay")
+			}
+			if replayFormat != "text" {
+				return fmt.Errorf("--format %s is not supported with batch replay (use --json)", replayFormat)
+			}
+			return runBatchReplay(root)
+		}
+		if len(args) == 0 {
+			return fmt.Errorf("requires a pack hash or --all")
+		}
+		if replayJSON {
+			return fmt.Errorf("--json only applies to batch replay (use --format json)")
+		}
+
+		var report *replay.ReplayReport
+		if replayInteractive {
+			report, err = replay.ReplayInteractive(root, args[0], os.Stdin, os.Stdout)
+			fmt.Println()
+		} else {
+			report, err = replay.Replay(root, args[0])
+		}
 		if err != nil {
 			return err
 		}
 
-		fmt.Print(report.Summary())
+		switch replayFormat {
+		case "text":
+			fmt.Print(report.Summary())
+		case "json":
+			data, err := report.JSON()
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+		case "html":
+			data, err := htmlreport.Replay(root, report)
+			if err != nil {
+				return err
+			}
+			os.Stdout.Write(data)
+		default:
+			return fmt.Errorf("unknown format %q (expected text, json or html)", replayFormat)
+		}
 
 		switch report.Fidelity {
 		case replay.FidelityDegraded:
@@ -131,22 +238,128 @@ var replayCmd = &cobra.Command{
 	},
 }
 
+// runBatchReplay selects packs from the replay flags, replays them, and exits
+// with the worst fidelity code observed.
+func runBatchReplay(root string) error {
+	sel := replay.PackSelector{
+		Model:    replayModel,
+		HashFile: replayFromFile,
+	}
+	var err error
+	if sel.Since, err = parseDateFlag(replaySince, false); err != nil {
+		return fmt.Errorf("--since: %w", err)
+	}
+	if sel.Until, err = parseDateFlag(replayUntil, true); err != nil {
+		return fmt.Errorf("--until: %w", err)
+	}
+
+	hashes, err := replay.SelectPacks(root, sel)
+	if err != nil {
+		return err
+	}
+	if len(hashes) == 0 {
+		return fmt.Errorf("no packs match the selection")
+	}
+
+	report := replay.ReplayBatch(root, hashes, replayWorkers)
+	if replayJSON {
+		data, err := report.JSON()
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	} else {
+		fmt.Print(report.Summary())
+	}
+
+	switch report.Fidelity() {
+	case replay.FidelityDegraded:
+		os.Exit(1)
+	case replay.FidelityFailed:
+		os.Exit(2)
+	}
+	return nil
+}
+
+// parseDateFlag parses a YYYY-MM-DD or RFC 3339 timestamp. A bare date used as an
+// upper bound is extended to the end of that day so the bound is inclusive.
+func parseDateFlag(value string, endOfDay bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q (expected YYYY-MM-DD or RFC 3339)", value)
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Nanosecond)
+	}
+	return t, nil
+}
+
 var diffCmd = &cobra.Command{
 	Use:   "diff <hash-a> <hash-b>",
 	Short: "Compare two context packs",
-	Long:  "Compare two context packs and produce a structured drift report.",
-	Args:  cobra.ExactArgs(2),
+	Long: `Compare two context packs and produce a structured drift report.
+
+With --many, compares any number of runs of the same task: groups them by
+step-sequence similarity and reports which steps are stable across all runs.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if diffMany {
+			return cobra.MinimumNArgs(2)(cmd, args)
+		}
+		return cobra.ExactArgs(2)(cmd, args)
+	},
 	RunE: func(cmd *cobra.Command, args []string) error {
 		root, err := store.DiscoverStore()
 		if err != nil {
 			return err
 		}
 
+		switch diffFormat {
+		case "", "json", "html":
+		case "human":
+			diffHuman = true
+		default:
+			return fmt.Errorf("unknown format %q (expected json, human or html)", diffFormat)
+		}
+		if diffMany && diffFormat == "html" {
+			return fmt.Errorf("--format html is not supported with --many")
+		}
+
+		if diffMany {
+			report, err := ctxdiff.DiffMany(root, args, diffThreshold)
+			if err != nil {
+				return err
+			}
+			if diffHuman {
+				fmt.Print(report.Human())
+				return nil
+			}
+			data, err := report.JSON()
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+			return nil
+		}
+
 		report, err := ctxdiff.Diff(root, args[0], args[1])
 		if err != nil {
 			return err
 		}
 
+		if diffFormat == "html" {
+			data, err := htmlreport.Diff(root, report)
+			if err != nil {
+				return err
+			}
+			os.Stdout.Write(data)
+			return nil
+		}
 		if diffHuman {
 			fmt.Print(report.Human())
 		} else {
@@ -160,6 +373,48 @@ var diffCmd = &cobra.Command{
 	},
 }
 
+var costCmd = &cobra.Command{
+	Use:   "cost <hash>",
+	Short: "Show token usage, latency and cost of a context pack",
+	Long: `Break down the token usage and latency recorded in a context pack by step
+and by model, priced using the "prices" table in .ctx/config.json or the file
+given with --prices.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		root, err := store.DiscoverStore()
+		if err != nil {
+			return err
+		}
+
+		p, err := pack.LoadPack(root, args[0])
+		if err != nil {
+			return err
+		}
+
+		var prices cost.PriceTable
+		if costPrices != "" {
+			prices, err = cost.LoadPriceFile(costPrices)
+		} else {
+			prices, err = cost.LoadPrices(root)
+		}
+		if err != nil {
+			return err
+		}
+
+		report := cost.Compute(p, prices)
+		if costJSON {
+			data, err := report.JSON()
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+		} else {
+			fmt.Print(report.Human())
+		}
+		return nil
+	},
+}
+
 var verifyCmd = &cobra.Command{
 	Use:   "verify <artifact>",
 	Short: "Verify artifact provenance",
@@ -198,7 +453,387 @@ var forkCmd = &cobra.Command{
 		}
 
 		fmt.Printf("Draft created at %s\n", draftPath)
-		fmt.Println("Edit the draft, then finalize with 'ctx finalize <draft-path>'")
+		fmt.Println("Edit the draft with 'ctx draft', then finalize with 'ctx finalize <draft>'")
+		return nil
+	},
+}
+
+var finalizeCmd = &cobra.Command{
+	Use:   "finalize <draft>",
+	Short: "Finalize a draft into a new context pack",
+	Long:  "Check a draft's required fields and blob references, then store it as an immutable context pack. The draft can be given as a path, a draft name, or its parent's hash prefix.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		root, err := store.DiscoverStore()
+		if err != nil {
+			return err
+		}
+
+		path, err := sharing.ResolveDraft(root, args[0])
+		if err != nil {
+			return err
+		}
+		p, err := sharing.FinalizeDraft(root, path)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Finalized %s\n", p.Hash)
+		fmt.Printf("Forked from %s\n", store.ShortHash(p.Parent, 12))
+		return nil
+	},
+}
+
+var draftCmd = &cobra.Command{
+	Use:   "draft",
+	Short: "List and edit drafts",
+	Long:  "List drafts created by 'ctx fork' and edit them without touching raw JSON. Edits store new content as blobs and update the draft's references.",
+}
+
+var draftListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List drafts",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		root, err := store.DiscoverStore()
+		if err != nil {
+			return err
+		}
+
+		drafts, err := sharing.ListDrafts(root)
+		if err != nil {
+			return err
+		}
+		fmt.Print(sharing.FormatDraftList(drafts))
+		return nil
+	},
+}
+
+var draftSetSystemPromptCmd = &cobra.Command{
+	Use:   "set-system-prompt <draft> <file>",
+	Short: "Replace a draft's system prompt with a file's contents ('-' for stdin)",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return editDraft(args[0], func(root string, p *pack.Pack) error {
+			content, err := readContent(args[1])
+			if err != nil {
+				return err
+			}
+			return sharing.SetSystemPrompt(root, p, content)
+		})
+	},
+}
+
+var draftSetPromptCmd = &cobra.Command{
+	Use:   "set-prompt <draft> <index> <file>",
+	Short: "Replace one of a draft's prompts with a file's contents ('-' for stdin)",
+	Args:  cobra.ExactArgs(3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		i, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid prompt index %q", args[1])
+		}
+		return editDraft(args[0], func(root string, p *pack.Pack) error {
+			content, err := readContent(args[2])
+			if err != nil {
+				return err
+			}
+			return sharing.SetPrompt(root, p, i, content)
+		})
+	},
+}
+
+var draftReplaceInputCmd = &cobra.Command{
+	Use:   "replace-input <draft> <name> <file>",
+	Short: "Replace a named input with a file's contents ('-' for stdin)",
+	Args:  cobra.ExactArgs(3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return editDraft(args[0], func(root string, p *pack.Pack) error {
+			content, err := readContent(args[2])
+			if err != nil {
+				return err
+			}
+			return sharing.ReplaceInput(root, p, args[1], content)
+		})
+	},
+}
+
+var draftDropStepCmd = &cobra.Command{
+	Use:   "drop-step <draft> <index>",
+	Short: "Remove a step from a draft and renumber the steps after it",
+	Long: `Remove a step from a draft. Steps are named by the index ctx show prints
+in brackets, not by their position in the list. Each later step takes the
+index of the one before it, and step inputs are updated to match.`,
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		i, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid step index %q", args[1])
+		}
+		return editDraft(args[0], func(root string, p *pack.Pack) error {
+			return sharing.DropStep(p, i)
+		})
+	},
+}
+
+var draftSetParamCmd = &cobra.Command{
+	Use:   "set-param <draft> <index> <key> <value>",
+	Short: "Set a step parameter (the value is parsed as JSON, falling back to a string)",
+	Long: `Set a parameter of the step with the given index, as ctx show prints it in
+brackets. The value is parsed as JSON, falling back to a plain string.`,
+	Args:  cobra.ExactArgs(4),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		i, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid step index %q", args[1])
+		}
+		return editDraft(args[0], func(root string, p *pack.Pack) error {
+			return sharing.SetParam(root, p, i, args[2], args[3])
+		})
+	},
+}
+
+var fsckCmd = &cobra.Command{
+	Use:   "fsck",
+	Short: "Check the store for integrity problems",
+	Long: `Check the whole store: every blob in objects/ must match its hash, every
+registered pack must load, validate, have its canonical hash and reference only
+blobs that exist, and graph snapshots must reference only known paths and
+symbols. Exits with status 1 if problems remain.
+
+Repairs are opt-in: --rehash re-stores packs hashed the old way, --quarantine
+moves corrupt blobs and stray temp files to .ctx/quarantine/, and --fetch copies
+missing blobs from the remote store set in config.json (or --remote).`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		root, err := store.DiscoverStore()
+		if err != nil {
+			return err
+		}
+
+		if fsckRehash {
+			rehashed, err := pack.RehashPacks(root)
+			if err != nil {
+				return err
+			}
+			for _, r := range rehashed {
+				fmt.Fprintf(os.Stderr, "rehashed %s -> %s\n", r.Old, r.New)
+			}
+		}
+
+		report, err := fsck.Check(root)
+		if err != nil {
+			return err
+		}
+
+		opts := fsck.Options{Quarantine: fsckQuarantine}
+		if fsckFetch || fsckRemote != "" {
+			opts.FetchFrom = fsckRemote
+			if opts.FetchFrom == "" {
+				cfg, err := store.LoadConfig(root)
+				if err != nil {
+					return err
+				}
+				if cfg.Remote == "" {
+					return fmt.Errorf("--fetch needs a remote: set \"remote\" in config.json or pass --remote")
+				}
+				opts.FetchFrom = cfg.Remote
+			}
+		}
+		if !report.OK() && (opts.Quarantine || opts.FetchFrom != "") {
+			actions, err := fsck.Repair(root, report, opts)
+			for _, a := range actions {
+				fmt.Fprintln(os.Stderr, a)
+			}
+			if err != nil {
+				return err
+			}
+			if report, err = fsck.Check(root); err != nil {
+				return err
+			}
+		}
+
+		if fsckJSON {
+			data, err := report.JSON()
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+		} else {
+			fmt.Print(report.Human())
+		}
+
+		if !report.OK() {
+			os.Exit(1)
+		}
+		return nil
+	},
+}
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Upgrade the store to this version's format",
+	Long: `Upgrade .ctx/ from the format version in config.json to the one this build
+writes, applying each migration step in order. config.json, packs/ and graph/
+are first copied to .ctx/backups/<version>-<time>/.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		root, err := store.DiscoverStore()
+		if err != nil {
+			return err
+		}
+
+		if migrateDryRun {
+			version, err := migrate.Version(root)
+			if err != nil {
+				return err
+			}
+			plan, err := migrate.Plan(root)
+			if err != nil {
+				return err
+			}
+			if len(plan) == 0 {
+				fmt.Printf("Store is at version %s; nothing to migrate\n", version)
+				return nil
+			}
+			for _, s := range plan {
+				fmt.Printf("%s -> %s  %s\n", s.From, s.To, s.Description)
+			}
+			return nil
+		}
+
+		result, err := migrate.Migrate(root)
+		if result != nil {
+			for _, s := range result.Applied {
+				fmt.Fprintf(os.Stderr, "migrated %s -> %s: %s\n", s.From, s.To, s.Description)
+			}
+		}
+		if err != nil {
+			if result != nil && result.Backup != "" {
+				fmt.Fprintf(os.Stderr, "backup kept in %s\n", result.Backup)
+			}
+			return err
+		}
+		if len(result.Applied) == 0 {
+			fmt.Printf("Store is at version %s; nothing to migrate\n", result.To)
+			return nil
+		}
+		fmt.Printf("Migrated store from %s to %s (backup in .ctx/%s)\n", result.From, result.To, result.Backup)
+		return nil
+	},
+}
+
+var repackCmd = &cobra.Command{
+	Use:   "repack",
+	Short: "Compress loose objects and consolidate them into a packfile",
+	Long: `Compress every uncompressed loose object in .ctx/objects/ with the codec set
+by "compression" in config.json, where that makes it smaller, then move the
+loose objects into a new packfile under .ctx/packfiles/: one archive plus a
+sorted hash index. Blob hashes are over the uncompressed content, so no hash
+or pack changes. With --all, existing packfiles are merged into the new one
+too.
+
+Set "compression": "zstd" in config.json to compress objects, both as they
+are written and when repacking. With compression off (the default), repack
+only consolidates.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		root, err := store.DiscoverStore()
+		if err != nil {
+			return err
+		}
+
+		cfg, err := store.LoadConfig(root)
+		if err != nil {
+			return err
+		}
+		if cfg.Compression != "" && cfg.Compression != store.CompressionNone {
+			stats, err := store.CompressObjects(root, cfg.Compression)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Compressed %d of %d loose object(s): %d -> %d bytes\n", stats.Compressed, stats.Objects, stats.BytesBefore, stats.BytesAfter)
+		}
+
+		packed, err := store.PackObjects(root, repackAll)
+		if err != nil {
+			return err
+		}
+		if packed.Packfile == "" {
+			fmt.Println("No objects to pack")
+			return nil
+		}
+		fmt.Printf("Packed %d object(s) into packfile %s, removed %d loose object(s)", packed.Objects, packed.Packfile[:12], packed.LooseRemoved)
+		if repackAll {
+			fmt.Printf(" and %d old packfile(s)", packed.PacksReplaced)
+		}
+		fmt.Println()
+		return nil
+	},
+}
+
+var keyCmd = &cobra.Command{
+	Use:   "key",
+	Short: "Manage encryption keys",
+	Long: `Manage the keys in .ctx/keys/ that encrypted packs are sealed with. Packs are
+encrypted with 'ctx pack --key <id>', or with the key named by "encryption_key"
+in config.json. Keep key files out of anything that copies the store.`,
+}
+
+var keyNewCmd = &cobra.Command{
+	Use:   "new",
+	Short: "Generate an encryption key",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		root, err := store.DiscoverStore()
+		if err != nil {
+			return err
+		}
+
+		key, err := store.GenerateKey(root)
+		if err != nil {
+			return err
+		}
+		if keyNewDefault {
+			cfg, err := store.LoadConfig(root)
+			if err != nil {
+				return err
+			}
+			cfg.EncryptionKey = key.ID
+			if err := store.WriteConfig(root, cfg); err != nil {
+				return err
+			}
+		}
+		fmt.Println(key.ID)
+		return nil
+	},
+}
+
+var keyListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List encryption keys",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		root, err := store.DiscoverStore()
+		if err != nil {
+			return err
+		}
+
+		ids, err := store.ListKeys(root)
+		if err != nil {
+			return err
+		}
+		cfg, err := store.LoadConfig(root)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if id == cfg.EncryptionKey {
+				fmt.Printf("%s (default)\n", id)
+			} else {
+				fmt.Println(id)
+			}
+		}
 		return nil
 	},
 }
@@ -223,6 +858,36 @@ var logCmd = &cobra.Command{
 	},
 }
 
+var lineageCmd = &cobra.Command{
+	Use:   "lineage <hash>",
+	Short: "Show the fork family tree of a pack",
+	Long:  "Walk a pack's parents and find the packs forked from it, annotating each fork with a summary of what changed relative to its parent.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		root, err := store.DiscoverStore()
+		if err != nil {
+			return err
+		}
+
+		l, err := sharing.BuildLineage(root, args[0])
+		if err != nil {
+			return err
+		}
+
+		switch lineageFormat {
+		case "text":
+			fmt.Print(l.Text())
+		case "dot":
+			fmt.Print(l.DOT())
+		case "mermaid":
+			fmt.Print(l.Mermaid())
+		default:
+			return fmt.Errorf("unknown format %q (expected text, dot or mermaid)", lineageFormat)
+		}
+		return nil
+	},
+}
+
 var indexCmd = &cobra.Command{
 	Use:   "index",
 	Short: "Index a commit into the context graph",
@@ -497,8 +1162,109 @@ func estimateWarmTokens(storeRoot, headSHA string, dr *delta.DeltaReport) int {
 	return tokens
 }
 
+var uiCmd = &cobra.Command{
+	Use:   "ui",
+	Short: "Browse the store in a local web interface",
+	Long:  "Start a local HTTP server for browsing context packs, their fork lineage, diffs between packs, and the indexed context graph.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		root, err := store.DiscoverStore()
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Serving ctx UI at http://%s (Ctrl-C to stop)\n", uiAddr)
+		if err := http.ListenAndServe(uiAddr, ui.Handler(root, uiAddr)); err != nil {
+			return fmt.Errorf("serving UI: %w", err)
+		}
+		return nil
+	},
+}
+
+var tuiCmd = &cobra.Command{
+	Use:   "tui",
+	Short: "Browse the store in an interactive terminal UI",
+	Long:  "Browse context packs, preview their blobs, diff two marked packs and walk the indexed context graph from the terminal. Use the arrow keys or j/k to move, enter to open, esc to go back, / to search and q to quit.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		root, err := store.DiscoverStore()
+		if err != nil {
+			return err
+		}
+
+		app, err := tui.New(root)
+		if err != nil {
+			return err
+		}
+		return tui.Run(app, os.Stdin, os.Stdout)
+	},
+}
+
+// editDraft resolves a draft, applies an edit to it and saves it.
+func editDraft(ref string, edit func(root string, p *pack.Pack) error) error {
+	root, err := store.DiscoverStore()
+	if err != nil {
+		return err
+	}
+	path, err := sharing.ResolveDraft(root, ref)
+	if err != nil {
+		return err
+	}
+	p, err := sharing.LoadDraft(path)
+	if err != nil {
+		return err
+	}
+	if err := edit(root, p); err != nil {
+		return err
+	}
+	if err := sharing.SaveDraft(path, p); err != nil {
+		return err
+	}
+	fmt.Printf("Updated %s\n", path)
+	return nil
+}
+
+// readContent reads a file, or standard input when the path is "-".
+func readContent(path string) ([]byte, error) {
+	if path == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("reading stdin: %w", err)
+		}
+		return data, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return data, nil
+}
+
 func init() {
+	replayCmd.Flags().BoolVar(&replayAll, "all", false, "replay every registered pack")
+	replayCmd.Flags().StringVar(&replayModel, "model", "", "replay only packs recorded with this model")
+	replayCmd.Flags().StringVar(&replaySince, "since", "", "replay only packs created on or after this date")
+	replayCmd.Flags().StringVar(&replayUntil, "until", "", "replay only packs created on or before this date")
+	replayCmd.Flags().StringVar(&replayFromFile, "from-file", "", "replay the packs listed in a file (one hash per line)")
+	replayCmd.Flags().IntVar(&replayWorkers, "workers", replay.DefaultBatchWorkers, "maximum number of packs replayed concurrently")
+	replayCmd.Flags().BoolVar(&replayJSON, "json", false, "output the batch report as JSON")
+	replayCmd.Flags().BoolVarP(&replayInteractive, "interactive", "i", false, "step through the replay, pausing before each step")
+	costCmd.Flags().StringVar(&costPrices, "prices", "", "price table JSON file to use instead of .ctx/config.json")
+	costCmd.Flags().BoolVar(&costJSON, "json", false, "output the cost report as JSON")
 	diffCmd.Flags().BoolVar(&diffHuman, "human", false, "output human-readable summary instead of JSON")
+	diffCmd.Flags().StringVar(&diffFormat, "format", "", "output format: json, human or html")
+	showCmd.Flags().StringVar(&showFormat, "format", "text", "output format: text or html")
+	replayCmd.Flags().StringVar(&replayFormat, "format", "text", "output format for a single replay: text, json or html")
+	diffCmd.Flags().BoolVar(&diffMany, "many", false, "compare any number of packs and cluster them by behavior")
+	diffCmd.Flags().Float64Var(&diffThreshold, "threshold", ctxdiff.DefaultClusterThreshold, "step similarity (0-1) at which --many groups runs together")
+	lineageCmd.Flags().StringVar(&lineageFormat, "format", "text", "output format: text, dot or mermaid")
+	fsckCmd.Flags().BoolVar(&fsckRehash, "rehash", false, "re-store packs under their canonical hash, rewriting references to them")
+	fsckCmd.Flags().BoolVar(&fsckJSON, "json", false, "output the report as JSON")
+	fsckCmd.Flags().BoolVar(&fsckQuarantine, "quarantine", false, "move corrupt blobs and stray temp files to .ctx/quarantine/")
+	fsckCmd.Flags().BoolVar(&fsckFetch, "fetch", false, "copy missing blobs from the remote store configured in config.json")
+	fsckCmd.Flags().StringVar(&fsckRemote, "remote", "", "store directory to fetch missing blobs from (implies --fetch)")
+	migrateCmd.Flags().BoolVar(&migrateDryRun, "dry-run", false, "list the migration steps without applying them")
+	repackCmd.Flags().BoolVar(&repackAll, "all", false, "merge existing packfiles into the new one")
+	packCmd.Flags().StringVar(&packKeyID, "key", "", "encrypt the pack's content with this key from .ctx/keys/")
+	keyNewCmd.Flags().BoolVar(&keyNewDefault, "default", false, "make the new key the store's default for new packs")
 	indexCmd.Flags().StringVar(&indexCommit, "commit", "", "specific commit SHA to index (defaults to HEAD)")
 	deltaCmd.Flags().StringVar(&deltaBase, "base", "", "base commit SHA (required)")
 	deltaCmd.Flags().StringVar(&deltaHead, "head", "", "head commit SHA (required)")
@@ -510,19 +1276,38 @@ func init() {
 	optimizeCmd.Flags().BoolVar(&optimizeHuman, "human", false, "output human-readable summary instead of JSON")
 	metricsCmd.Flags().IntVar(&metricsLimit, "limit", 20, "number of recent runs to display")
 	benchmarkCmd.Flags().IntVar(&benchmarkCommits, "commits", 10, "number of recent commits to benchmark")
+	uiCmd.Flags().StringVar(&uiAddr, "addr", ui.DefaultAddr, "address to listen on")
 	rootCmd.AddCommand(initCmd)
 	rootCmd.AddCommand(packCmd)
 	rootCmd.AddCommand(showCmd)
 	rootCmd.AddCommand(replayCmd)
 	rootCmd.AddCommand(diffCmd)
+	rootCmd.AddCommand(costCmd)
 	rootCmd.AddCommand(verifyCmd)
 	rootCmd.AddCommand(forkCmd)
+	draftCmd.AddCommand(draftListCmd)
+	draftCmd.AddCommand(draftSetSystemPromptCmd)
+	draftCmd.AddCommand(draftSetPromptCmd)
+	draftCmd.AddCommand(draftReplaceInputCmd)
+	draftCmd.AddCommand(draftDropStepCmd)
+	draftCmd.AddCommand(draftSetParamCmd)
+	rootCmd.AddCommand(draftCmd)
+	rootCmd.AddCommand(finalizeCmd)
+	rootCmd.AddCommand(fsckCmd)
+	rootCmd.AddCommand(migrateCmd)
+	rootCmd.AddCommand(repackCmd)
+	keyCmd.AddCommand(keyNewCmd)
+	keyCmd.AddCommand(keyListCmd)
+	rootCmd.AddCommand(keyCmd)
 	rootCmd.AddCommand(logCmd)
+	rootCmd.AddCommand(lineageCmd)
 	rootCmd.AddCommand(indexCmd)
 	rootCmd.AddCommand(deltaCmd)
 	rootCmd.AddCommand(optimizeCmd)
 	rootCmd.AddCommand(metricsCmd)
 	rootCmd.AddCommand(benchmarkCmd)
+	rootCmd.AddCommand(uiCmd)
+	rootCmd.AddCommand(tuiCmd)
 
 	// Shell completion (bash, zsh, fish, powershell) via Cobra's built-in generator
 	completionCmd := &cobra.Command{
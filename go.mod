@@ -2,9 +2,14 @@ module github.com/contextsubstrate/ctx
 
 go 1.23
 
-require github.com/spf13/cobra v1.10.2
+require (
+	github.com/klauspost/compress v1.18.4
+	github.com/spf13/cobra v1.10.2
+	golang.org/x/term v0.27.0
+)
 
 require (
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/spf13/pflag v1.0.9 // indirect
+	golang.org/x/sys v0.28.0 // indirect
 )
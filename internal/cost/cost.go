@@ -0,0 +1,267 @@
+// Package cost prices the token usage recorded in context packs.
+package cost
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/contextsubstrate/ctx/internal/pack"
+	"github.com/contextsubstrate/ctx/internal/store"
+)
+
+// PriceTable maps model identifiers to token prices. A key ending in "*"
+// matches any model identifier with that prefix.
+type PriceTable map[string]store.ModelPrice
+
+// LoadPrices reads the price table from the store's config.json.
+func LoadPrices(storeRoot string) (PriceTable, error) {
+	cfg, err := store.LoadConfig(storeRoot)
+	if err != nil {
+		return nil, err
+	}
+	return PriceTable(cfg.Prices), nil
+}
+
+// LoadPriceFile reads a standalone price table: a JSON object in the same
+// shape as the "prices" section of config.json.
+func LoadPriceFile(path string) (PriceTable, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading price table: %w", err)
+	}
+	var prices PriceTable
+	if err := json.Unmarshal(data, &prices); err != nil {
+		return nil, fmt.Errorf("parsing price table: %w", err)
+	}
+	return prices, nil
+}
+
+// Lookup returns the price for a model: an exact match if present, otherwise
+// the wildcard entry with the longest matching prefix.
+func (t PriceTable) Lookup(model string) (store.ModelPrice, bool) {
+	if price, ok := t[model]; ok {
+		return price, true
+	}
+
+	best := -1
+	var match store.ModelPrice
+	for key, price := range t {
+		prefix, ok := strings.CutSuffix(key, "*")
+		if ok && strings.HasPrefix(model, prefix) && len(prefix) > best {
+			best = len(prefix)
+			match = price
+		}
+	}
+	return match, best >= 0
+}
+
+// Price returns the USD cost of the given usage. Cached tokens are billed at
+// the prompt rate when the price has no separate cached rate.
+func Price(u pack.Usage, price store.ModelPrice) float64 {
+	cached := price.Cached
+	if cached == 0 {
+		cached = price.Prompt
+	}
+	return (float64(u.PromptTokens)*price.Prompt +
+		float64(u.CompletionTokens)*price.Completion +
+		float64(u.CachedTokens)*cached) / 1e6
+}
+
+// StepCost is the usage and cost of a single step.
+type StepCost struct {
+	Index     int        `json:"index"`
+	Tool      string     `json:"tool"`
+	Agent     string     `json:"agent,omitempty"`
+	Model     string     `json:"model"`
+	Usage     pack.Usage `json:"usage"`
+	LatencyMs int64      `json:"latency_ms,omitempty"`
+	Cost      float64    `json:"cost"`
+	Priced    bool       `json:"priced"`
+}
+
+// ModelCost is the usage and cost attributed to one model across a pack.
+type ModelCost struct {
+	Model  string     `json:"model"`
+	Steps  int        `json:"steps"`
+	Usage  pack.Usage `json:"usage"`
+	Cost   float64    `json:"cost"`
+	Priced bool       `json:"priced"`
+}
+
+// Report is the cost breakdown of a pack.
+type Report struct {
+	PackHash  string      `json:"pack_hash"`
+	Steps     []StepCost  `json:"steps"`
+	Models    []ModelCost `json:"models"`
+	Usage     pack.Usage  `json:"usage"`
+	LatencyMs int64       `json:"latency_ms"`
+	Cost      float64     `json:"cost"`
+	Unpriced  []string    `json:"unpriced,omitempty"`
+}
+
+// Compute prices every step that recorded usage. Models missing from the
+// price table contribute no cost and are listed in Unpriced.
+func Compute(p *pack.Pack, prices PriceTable) *Report {
+	r := &Report{PackHash: p.Hash}
+	byModel := make(map[string]*ModelCost)
+
+	for i := range p.Steps {
+		s := &p.Steps[i]
+		if s.Usage == nil && s.LatencyMs == 0 {
+			continue
+		}
+
+		sc := StepCost{
+			Index:     s.Index,
+			Tool:      s.Tool,
+			Agent:     s.Agent,
+			Model:     p.StepModel(s).Identifier,
+			LatencyMs: s.LatencyMs,
+		}
+		if s.Usage != nil {
+			sc.Usage = *s.Usage
+		}
+		price, ok := prices.Lookup(sc.Model)
+		sc.Priced = ok || sc.Usage.Total() == 0
+		if ok {
+			sc.Cost = Price(sc.Usage, price)
+		}
+		r.Steps = append(r.Steps, sc)
+
+		r.Usage.Add(sc.Usage)
+		r.LatencyMs += sc.LatencyMs
+		r.Cost += sc.Cost
+
+		mc, seen := byModel[sc.Model]
+		if !seen {
+			mc = &ModelCost{Model: sc.Model, Priced: true}
+			byModel[sc.Model] = mc
+		}
+		mc.Steps++
+		mc.Usage.Add(sc.Usage)
+		mc.Cost += sc.Cost
+		mc.Priced = mc.Priced && sc.Priced
+	}
+
+	for _, mc := range byModel {
+		r.Models = append(r.Models, *mc)
+		if !mc.Priced {
+			r.Unpriced = append(r.Unpriced, mc.Model)
+		}
+	}
+	sort.Slice(r.Models, func(i, j int) bool { return r.Models[i].Model < r.Models[j].Model })
+	sort.Strings(r.Unpriced)
+
+	return r
+}
+
+// Priced reports whether every step with usage had a price.
+func (r *Report) Priced() bool {
+	return len(r.Unpriced) == 0
+}
+
+// JSON returns the report as JSON bytes.
+func (r *Report) JSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
+
+// Human returns a human-readable cost breakdown.
+func (r *Report) Human() string {
+	if len(r.Steps) == 0 {
+		return "No usage recorded in this pack.\n"
+	}
+
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("Cost for %s\n\n", store.ShortHash(r.PackHash, 12)))
+
+	b.WriteString(fmt.Sprintf("%-6s  %-20s  %-20s  %8s  %10s  %8s  %10s\n", "Step", "Tool", "Model", "Prompt", "Completion", "Cached", "Cost"))
+	for _, s := range r.Steps {
+		b.WriteString(fmt.Sprintf("%-6d  %-20s  %-20s  %8d  %10d  %8d  %10s\n",
+			s.Index, s.Tool, s.Model, s.Usage.PromptTokens, s.Usage.CompletionTokens, s.Usage.CachedTokens, formatCost(s.Cost, s.Priced)))
+	}
+
+	b.WriteString("\nBy model:\n")
+	for _, m := range r.Models {
+		b.WriteString(fmt.Sprintf("  %-20s  %d step(s)  %d tokens  %s\n", m.Model, m.Steps, m.Usage.Total(), formatCost(m.Cost, m.Priced)))
+	}
+
+	b.WriteString(fmt.Sprintf("\nTotal:   %s\n", pack.FormatUsage(r.Usage)))
+	b.WriteString(fmt.Sprintf("Latency: %s\n", time.Duration(r.LatencyMs)*time.Millisecond))
+	b.WriteString(fmt.Sprintf("Cost:    %s\n", formatCost(r.Cost, true)))
+	if !r.Priced() {
+		b.WriteString(fmt.Sprintf("\nNo price configured for: %s (add them under \"prices\" in .ctx/config.json)\n", strings.Join(r.Unpriced, ", ")))
+	}
+
+	return b.String()
+}
+
+// formatCost renders a USD amount, or "n/a" when it could not be priced.
+func formatCost(usd float64, priced bool) string {
+	if !priced {
+		return "n/a"
+	}
+	return fmt.Sprintf("$%.4f", usd)
+}
+
+// Delta compares the usage, latency and cost of two packs.
+type Delta struct {
+	TokensA    int      `json:"tokens_a"`
+	TokensB    int      `json:"tokens_b"`
+	LatencyMsA int64    `json:"latency_ms_a"`
+	LatencyMsB int64    `json:"latency_ms_b"`
+	CostA      float64  `json:"cost_a"`
+	CostB      float64  `json:"cost_b"`
+	Unpriced   []string `json:"unpriced,omitempty"`
+}
+
+// Compare builds the delta between two cost reports.
+func Compare(a, b *Report) *Delta {
+	d := &Delta{
+		TokensA:    a.Usage.Total(),
+		TokensB:    b.Usage.Total(),
+		LatencyMsA: a.LatencyMs,
+		LatencyMsB: b.LatencyMs,
+		CostA:      a.Cost,
+		CostB:      b.Cost,
+	}
+
+	seen := make(map[string]bool)
+	for _, m := range append(append([]string{}, a.Unpriced...), b.Unpriced...) {
+		if !seen[m] {
+			seen[m] = true
+			d.Unpriced = append(d.Unpriced, m)
+		}
+	}
+	sort.Strings(d.Unpriced)
+	return d
+}
+
+// Changed reports whether the two packs differ in tokens, latency or cost.
+func (d *Delta) Changed() bool {
+	return d.TokensA != d.TokensB || d.LatencyMsA != d.LatencyMsB || d.CostA != d.CostB
+}
+
+// Human returns the delta as aligned "A → B (±change)" lines.
+func (d *Delta) Human() string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("Tokens:  %d → %d (%+d)\n", d.TokensA, d.TokensB, d.TokensB-d.TokensA))
+
+	la := time.Duration(d.LatencyMsA) * time.Millisecond
+	lb := time.Duration(d.LatencyMsB) * time.Millisecond
+	sign := "+"
+	if lb < la {
+		sign = "-"
+	}
+	b.WriteString(fmt.Sprintf("Latency: %s → %s (%s%s)\n", la, lb, sign, (lb - la).Abs()))
+
+	if len(d.Unpriced) > 0 {
+		b.WriteString(fmt.Sprintf("Cost:    unavailable (no price for %s)\n", strings.Join(d.Unpriced, ", ")))
+	} else {
+		b.WriteString(fmt.Sprintf("Cost:    $%.4f → $%.4f (%+.4f)\n", d.CostA, d.CostB, d.CostB-d.CostA))
+	}
+	return b.String()
+}
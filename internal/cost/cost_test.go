@@ -0,0 +1,138 @@
+package cost
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/contextsubstrate/ctx/internal/pack"
+	"github.com/contextsubstrate/ctx/internal/store"
+)
+
+func usagePack() *pack.Pack {
+	return &pack.Pack{
+		Hash:  "sha256:" + strings.Repeat("ab", 32),
+		Model: pack.Model{Identifier: "gpt-4o-2024-08-06"},
+		Steps: []pack.Step{
+			{Index: 0, Tool: "search", Usage: &pack.Usage{PromptTokens: 1000, CompletionTokens: 200}, LatencyMs: 1200},
+			{Index: 1, Tool: "read_file"},
+			{Index: 2, Tool: "summarize", Model: "local-llama", Usage: &pack.Usage{PromptTokens: 500, CompletionTokens: 100}, LatencyMs: 800},
+			{Index: 3, Tool: "write", Usage: &pack.Usage{PromptTokens: 2000, CompletionTokens: 400, CachedTokens: 1000}, LatencyMs: 2000},
+		},
+	}
+}
+
+func TestPriceTableLookup(t *testing.T) {
+	prices := PriceTable{
+		"gpt-4o":   {Prompt: 1},
+		"gpt-4o*":  {Prompt: 2},
+		"gpt-*":    {Prompt: 3},
+		"claude-*": {Prompt: 4},
+	}
+
+	tests := []struct {
+		model  string
+		prompt float64
+		ok     bool
+	}{
+		{"gpt-4o", 1, true},
+		{"gpt-4o-mini", 2, true},
+		{"gpt-3.5-turbo", 3, true},
+		{"claude-opus-4-6", 4, true},
+		{"llama", 0, false},
+	}
+	for _, tt := range tests {
+		price, ok := prices.Lookup(tt.model)
+		if ok != tt.ok || price.Prompt != tt.prompt {
+			t.Errorf("Lookup(%q) = %v, %v; want prompt %v, %v", tt.model, price, ok, tt.prompt, tt.ok)
+		}
+	}
+}
+
+func TestCompute(t *testing.T) {
+	prices := PriceTable{"gpt-4o*": {Prompt: 2.5, Completion: 10, Cached: 1.25}}
+	r := Compute(usagePack(), prices)
+
+	if len(r.Steps) != 3 {
+		t.Fatalf("expected 3 priced steps (step without usage skipped), got %d", len(r.Steps))
+	}
+	if r.Usage.Total() != 5200 {
+		t.Errorf("expected 5200 total tokens, got %d", r.Usage.Total())
+	}
+	if r.LatencyMs != 4000 {
+		t.Errorf("expected 4000ms latency, got %d", r.LatencyMs)
+	}
+
+	// step 0: 1000*2.5 + 200*10 = 4500; step 3: 2000*2.5 + 400*10 + 1000*1.25 = 10250
+	want := (4500.0 + 10250.0) / 1e6
+	if math.Abs(r.Cost-want) > 1e-12 {
+		t.Errorf("expected cost %v, got %v", want, r.Cost)
+	}
+
+	if len(r.Unpriced) != 1 || r.Unpriced[0] != "local-llama" {
+		t.Errorf("expected local-llama unpriced, got %v", r.Unpriced)
+	}
+	if len(r.Models) != 2 || r.Models[0].Model != "gpt-4o-2024-08-06" || r.Models[0].Steps != 2 {
+		t.Errorf("unexpected per-model breakdown: %+v", r.Models)
+	}
+
+	human := r.Human()
+	for _, want := range []string{"By model:", "n/a", "No price configured for: local-llama"} {
+		if !strings.Contains(human, want) {
+			t.Errorf("expected %q in output:\n%s", want, human)
+		}
+	}
+}
+
+func TestPriceCachedDefaultsToPrompt(t *testing.T) {
+	u := pack.Usage{CachedTokens: 1000000}
+	if got := Price(u, store.ModelPrice{Prompt: 3}); got != 3 {
+		t.Errorf("expected cached tokens billed at prompt rate, got %v", got)
+	}
+}
+
+func TestLoadPrices(t *testing.T) {
+	root := filepath.Join(t.TempDir(), ".ctx")
+	os.MkdirAll(root, 0755)
+
+	cfg := store.DefaultConfig()
+	cfg.Prices = map[string]store.ModelPrice{"gpt-4o*": {Prompt: 2.5, Completion: 10}}
+	if err := store.WriteConfig(root, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	prices, err := LoadPrices(root)
+	if err != nil {
+		t.Fatalf("LoadPrices failed: %v", err)
+	}
+	if _, ok := prices.Lookup("gpt-4o-mini"); !ok {
+		t.Error("expected gpt-4o-mini to be priced from config")
+	}
+}
+
+func TestCompareDelta(t *testing.T) {
+	prices := PriceTable{"*": {Prompt: 1, Completion: 1}}
+	a := Compute(usagePack(), prices)
+
+	p := usagePack()
+	p.Steps[0].Usage = &pack.Usage{PromptTokens: 3000, CompletionTokens: 200}
+	p.Steps[0].LatencyMs = 200
+	b := Compute(p, prices)
+
+	d := Compare(a, b)
+	if !d.Changed() {
+		t.Fatal("expected delta to report a change")
+	}
+	human := d.Human()
+	for _, want := range []string{"Tokens:  5200 → 7200 (+2000)", "Latency: 4s → 3s (-1s)"} {
+		if !strings.Contains(human, want) {
+			t.Errorf("expected %q in output:\n%s", want, human)
+		}
+	}
+
+	if Compare(a, a).Changed() {
+		t.Error("expected identical reports to be unchanged")
+	}
+}
@@ -0,0 +1,75 @@
+package diff
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/contextsubstrate/ctx/internal/pack"
+	"github.com/contextsubstrate/ctx/internal/store"
+)
+
+// CompareAgents compares the declared agents of two packs by name.
+func CompareAgents(a *pack.Pack, b *pack.Pack) []DriftEntry {
+	var entries []DriftEntry
+
+	for _, agentA := range a.Agents {
+		agentB := b.FindAgent(agentA.Name)
+		if agentB == nil {
+			entries = append(entries, DriftEntry{
+				Type:        AgentDrift,
+				Description: fmt.Sprintf("Agent %q removed in pack B", agentA.Name),
+				PackA:       agentA.Name,
+			})
+			continue
+		}
+
+		if agentA.Model.Identifier != agentB.Model.Identifier {
+			entries = append(entries, DriftEntry{
+				Type:        AgentDrift,
+				Description: fmt.Sprintf("Agent %q uses a different model", agentA.Name),
+				PackA:       agentA.Model.Identifier,
+				PackB:       agentB.Model.Identifier,
+			})
+		}
+		if !reflect.DeepEqual(agentA.Model.Parameters, agentB.Model.Parameters) {
+			entries = append(entries, DriftEntry{
+				Type:        AgentDrift,
+				Description: fmt.Sprintf("Agent %q model parameters differ", agentA.Name),
+				PackA:       agentA.Model.Parameters,
+				PackB:       agentB.Model.Parameters,
+				Params:      CompareParams(agentA.Model.Parameters, agentB.Model.Parameters),
+			})
+		}
+		if promptA, promptB := a.AgentSystemPrompt(agentA.Name), b.AgentSystemPrompt(agentA.Name); promptA != promptB {
+			entries = append(entries, DriftEntry{
+				Type:        PromptDrift,
+				Description: fmt.Sprintf("Agent %q system prompts differ", agentA.Name),
+				PackA:       store.ShortHash(promptA, 12),
+				PackB:       store.ShortHash(promptB, 12),
+				refA:        promptA,
+				refB:        promptB,
+			})
+		}
+		if !reflect.DeepEqual(agentA.Parameters, agentB.Parameters) {
+			entries = append(entries, DriftEntry{
+				Type:        AgentDrift,
+				Description: fmt.Sprintf("Agent %q parameters differ", agentA.Name),
+				PackA:       agentA.Parameters,
+				PackB:       agentB.Parameters,
+				Params:      CompareParams(agentA.Parameters, agentB.Parameters),
+			})
+		}
+	}
+
+	for _, agentB := range b.Agents {
+		if a.FindAgent(agentB.Name) == nil {
+			entries = append(entries, DriftEntry{
+				Type:        AgentDrift,
+				Description: fmt.Sprintf("Agent %q added in pack B", agentB.Name),
+				PackB:       agentB.Name,
+			})
+		}
+	}
+
+	return entries
+}
@@ -0,0 +1,162 @@
+package diff
+
+import (
+	"encoding/json"
+
+	"github.com/contextsubstrate/ctx/internal/pack"
+	"github.com/contextsubstrate/ctx/internal/textdiff"
+)
+
+// AlignOp describes how a step in pack A corresponds to a step in pack B.
+type AlignOp string
+
+const (
+	AlignMatch      AlignOp = "match"      // same tool and parameters
+	AlignChange     AlignOp = "change"     // same tool, different parameters
+	AlignSubstitute AlignOp = "substitute" // a different tool in the same place
+	AlignMove       AlignOp = "move"       // same tool and parameters, elsewhere in the sequence
+	AlignInsert     AlignOp = "insert"     // only in pack B
+	AlignDelete     AlignOp = "delete"     // only in pack A
+)
+
+// StepPair links a step position in pack A to one in pack B. A or B is -1
+// when the step exists on one side only.
+type StepPair struct {
+	A  int     `json:"a"`
+	B  int     `json:"b"`
+	Op AlignOp `json:"op"`
+}
+
+// AlignSteps aligns two step sequences. Steps with the same tool and
+// parameters are matched along their longest common subsequence; the
+// remaining steps are paired as moves (identical steps in a different place),
+// changes (same tool between the same matched neighbours), substitutions,
+// or plain insertions and deletions.
+func AlignSteps(a, b []pack.Step) []StepPair {
+	keysA := make([]string, len(a))
+	for i := range a {
+		keysA[i] = stepKey(&a[i])
+	}
+	keysB := make([]string, len(b))
+	for i := range b {
+		keysB[i] = stepKey(&b[i])
+	}
+
+	// Split the edit script into matched anchors and the gaps between them.
+	type gap struct{ dels, ins []int }
+	var items []interface{} // StepPair for anchors, *gap for gaps
+	var cur *gap
+	for _, op := range textdiff.Strings(keysA, keysB) {
+		if op.Kind == textdiff.Equal {
+			cur = nil
+			items = append(items, StepPair{A: op.OldLine - 1, B: op.NewLine - 1, Op: AlignMatch})
+			continue
+		}
+		if cur == nil {
+			cur = &gap{}
+			items = append(items, cur)
+		}
+		if op.Kind == textdiff.Delete {
+			cur.dels = append(cur.dels, op.OldLine-1)
+		} else {
+			cur.ins = append(cur.ins, op.NewLine-1)
+		}
+	}
+
+	// Identical steps left unmatched on both sides were moved.
+	usedB := make(map[int]bool)
+	moved := make(map[int]int)
+	unmatchedB := make(map[string][]int)
+	for _, it := range items {
+		if g, ok := it.(*gap); ok {
+			for _, j := range g.ins {
+				unmatchedB[keysB[j]] = append(unmatchedB[keysB[j]], j)
+			}
+		}
+	}
+	for _, it := range items {
+		g, ok := it.(*gap)
+		if !ok {
+			continue
+		}
+		for _, i := range g.dels {
+			if js := unmatchedB[keysA[i]]; len(js) > 0 {
+				moved[i] = js[0]
+				usedB[js[0]] = true
+				unmatchedB[keysA[i]] = js[1:]
+			}
+		}
+	}
+
+	var pairs []StepPair
+	for _, it := range items {
+		g, ok := it.(*gap)
+		if !ok {
+			pairs = append(pairs, it.(StepPair))
+			continue
+		}
+
+		var ins []int
+		for _, j := range g.ins {
+			if !usedB[j] {
+				ins = append(ins, j)
+			}
+		}
+
+		// Within a gap, pair steps using the same tool, then pair whatever
+		// is left positionally as substitutions.
+		partner := make(map[int]StepPair)
+		taken := make(map[int]bool)
+		for _, i := range g.dels {
+			if _, ok := moved[i]; ok {
+				continue
+			}
+			for _, j := range ins {
+				if !taken[j] && a[i].Tool == b[j].Tool {
+					partner[i] = StepPair{A: i, B: j, Op: AlignChange}
+					taken[j] = true
+					break
+				}
+			}
+		}
+		var restB []int
+		for _, j := range ins {
+			if !taken[j] {
+				restB = append(restB, j)
+			}
+		}
+		for _, i := range g.dels {
+			if _, ok := moved[i]; ok {
+				continue
+			}
+			if _, ok := partner[i]; !ok && len(restB) > 0 {
+				partner[i] = StepPair{A: i, B: restB[0], Op: AlignSubstitute}
+				restB = restB[1:]
+			}
+		}
+
+		for _, i := range g.dels {
+			if p, ok := partner[i]; ok {
+				pairs = append(pairs, p)
+			} else if j, ok := moved[i]; ok {
+				pairs = append(pairs, StepPair{A: i, B: j, Op: AlignMove})
+			} else {
+				pairs = append(pairs, StepPair{A: i, B: -1, Op: AlignDelete})
+			}
+		}
+		for _, j := range restB {
+			pairs = append(pairs, StepPair{A: -1, B: j, Op: AlignInsert})
+		}
+	}
+
+	return pairs
+}
+
+// stepKey identifies a step by its tool and parameters.
+func stepKey(s *pack.Step) string {
+	params, err := json.Marshal(s.Parameters)
+	if err != nil {
+		return s.Tool
+	}
+	return s.Tool + "\x00" + string(params)
+}
@@ -0,0 +1,135 @@
+package diff
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// LikelyCause points at the differences most likely to explain the rest of
+// a drift report. Entries are indices into DriftReport.Entries.
+type LikelyCause struct {
+	Summary    string `json:"summary"`
+	Entries    []int  `json:"entries"`
+	Downstream int    `json:"downstream"`
+}
+
+// runLevelRank orders run-wide differences by how likely they are to explain
+// everything else: changed inputs first, environment last.
+var runLevelRank = map[DriftType]int{
+	InputDrift:       0,
+	PromptDrift:      1,
+	ModelDrift:       2,
+	SamplingDrift:    3,
+	AgentDrift:       4,
+	EnvironmentDrift: 5,
+}
+
+// RankCauses finds the earliest divergence between two runs and marks every
+// difference that follows from it as downstream.
+//
+// Differences that apply to the whole run (inputs, prompts, model, sampling,
+// agents) precede the first step, so when any exist they are the likely
+// cause and every step and output difference is downstream. Otherwise the
+// first divergent step in the alignment is the cause; its own output
+// difference is downstream when the call itself changed, as are all later
+// steps and the final outputs. Environment differences such as a tool
+// version bump rarely explain a run on their own, so they are the cause
+// only when nothing else diverges before the final outputs.
+func RankCauses(entries []DriftEntry) *LikelyCause {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	var causes, environment []int
+	for i, e := range entries {
+		if _, ok := runLevelRank[e.Type]; !ok || e.seq != 0 {
+			continue
+		}
+		if e.Type == EnvironmentDrift {
+			environment = append(environment, i)
+		} else {
+			causes = append(causes, i)
+		}
+	}
+	sort.SliceStable(causes, func(x, y int) bool {
+		return runLevelRank[entries[causes[x]].Type] < runLevelRank[entries[causes[y]].Type]
+	})
+
+	if len(causes) == 0 {
+		causes = firstStepCauses(entries)
+	}
+	if len(causes) == 0 {
+		causes = environment
+	}
+	if len(causes) == 0 {
+		// Only the final outputs differ.
+		for i := range entries {
+			causes = append(causes, i)
+		}
+	}
+
+	isCause := make(map[int]bool)
+	for _, i := range causes {
+		isCause[i] = true
+	}
+	lc := &LikelyCause{Entries: causes}
+	for i := range entries {
+		entries[i].Downstream = !isCause[i]
+		if entries[i].Downstream {
+			lc.Downstream++
+		}
+	}
+
+	var descriptions []string
+	for _, i := range causes {
+		descriptions = append(descriptions, entries[i].Description)
+	}
+	lc.Summary = strings.Join(descriptions, "; ")
+	return lc
+}
+
+// firstStepCauses returns the entries of the earliest divergent step pair.
+// An output difference is only a cause when nothing about the call changed.
+func firstStepCauses(entries []DriftEntry) []int {
+	first := 0
+	for _, e := range entries {
+		if e.seq > 0 && (first == 0 || e.seq < first) {
+			first = e.seq
+		}
+	}
+	if first == 0 {
+		return nil
+	}
+
+	var calls, outputs []int
+	for i, e := range entries {
+		if e.seq != first {
+			continue
+		}
+		if e.Type == ReasoningDrift && e.refA != "" {
+			outputs = append(outputs, i)
+		} else {
+			calls = append(calls, i)
+		}
+	}
+	if len(calls) > 0 {
+		return calls
+	}
+	return outputs
+}
+
+// causeHuman renders the likely cause section of a human-readable report.
+func (r *DriftReport) causeHuman() string {
+	if r.LikelyCause == nil || r.LikelyCause.Downstream == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("Likely cause:\n")
+	for _, i := range r.LikelyCause.Entries {
+		b.WriteString(fmt.Sprintf("  [%s] %s\n", r.Entries[i].Type, r.Entries[i].Description))
+	}
+	b.WriteString(fmt.Sprintf("  (%d later difference(s) are likely downstream effects)\n\n", r.LikelyCause.Downstream))
+	return b.String()
+}
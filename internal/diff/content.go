@@ -0,0 +1,117 @@
+package diff
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/contextsubstrate/ctx/internal/store"
+	"github.com/contextsubstrate/ctx/internal/textdiff"
+)
+
+const (
+	// MaxContentDiffBytes is the largest blob, per side, that is diffed.
+	MaxContentDiffBytes = 256 * 1024
+
+	// maxWordDiffBytes bounds word-level diffs, whose LCS table grows with the
+	// square of the number of words.
+	maxWordDiffBytes = 16 * 1024
+
+	// contentDiffContext is the number of context lines in unified diffs.
+	contentDiffContext = 3
+)
+
+// ContentDiff describes how the content behind two differing blobs changed.
+type ContentDiff struct {
+	SizeA   int    `json:"size_a"`
+	SizeB   int    `json:"size_b"`
+	Unified string `json:"unified,omitempty"`
+	Words   string `json:"words,omitempty"`
+	Binary  bool   `json:"binary,omitempty"`
+	Omitted string `json:"omitted,omitempty"`
+}
+
+// CompareContent diffs two blob contents. Binary content, content larger
+// than MaxContentDiffBytes, and changes too large to diff within
+// textdiff.MaxCells are reported by size only. Prose additionally gets an
+// inline word-level diff.
+func CompareContent(a, b []byte) *ContentDiff {
+	d := &ContentDiff{SizeA: len(a), SizeB: len(b)}
+
+	if isBinary(a) || isBinary(b) {
+		d.Binary = true
+		return d
+	}
+	if len(a) > MaxContentDiffBytes || len(b) > MaxContentDiffBytes {
+		d.Omitted = fmt.Sprintf("content exceeds %d bytes", MaxContentDiffBytes)
+		return d
+	}
+
+	if !textdiff.LinesFit(string(a), string(b)) {
+		d.Omitted = "too many changed lines to diff"
+		return d
+	}
+
+	d.Unified = textdiff.Unified(string(a), string(b), contentDiffContext)
+	if isProse(a) && isProse(b) && len(a) <= maxWordDiffBytes && len(b) <= maxWordDiffBytes && textdiff.WordsFit(string(a), string(b)) {
+		d.Words = textdiff.FormatWords(textdiff.Words(string(a), string(b)))
+	}
+	return d
+}
+
+// attachContentDiffs loads the blobs behind entries that record content refs
+// and fills in their content diffs. Unreadable blobs are noted, not fatal.
+func attachContentDiffs(storeRoot string, entries []DriftEntry) {
+	for i := range entries {
+		e := &entries[i]
+		if e.refA == "" || e.refB == "" {
+			continue
+		}
+
+		a, err := store.ReadBlob(storeRoot, e.refA)
+		if err != nil {
+			e.Content = &ContentDiff{Omitted: fmt.Sprintf("pack A content unavailable: %v", err)}
+			continue
+		}
+		b, err := store.ReadBlob(storeRoot, e.refB)
+		if err != nil {
+			e.Content = &ContentDiff{Omitted: fmt.Sprintf("pack B content unavailable: %v", err)}
+			continue
+		}
+		e.Content = CompareContent(a, b)
+	}
+}
+
+// isBinary treats content as binary if it is not valid UTF-8 or contains a NUL byte.
+func isBinary(data []byte) bool {
+	return bytes.IndexByte(data, 0) >= 0 || !utf8.Valid(data)
+}
+
+// isProse reports whether text reads as prose rather than code or data: a
+// few lines, or long lines on average.
+func isProse(data []byte) bool {
+	lines := bytes.Count(data, []byte("\n")) + 1
+	return lines <= 3 || len(data)/lines >= 60
+}
+
+// human renders the content diff with every line prefixed by indent. Prose is
+// shown as an inline word diff, everything else as a unified diff.
+func (d *ContentDiff) human(indent string) string {
+	var text string
+	switch {
+	case d.Binary:
+		text = fmt.Sprintf("(binary content, %d → %d bytes)\n", d.SizeA, d.SizeB)
+	case d.Omitted != "":
+		text = fmt.Sprintf("(diff omitted: %s)\n", d.Omitted)
+	case d.Words != "":
+		text = d.Words + "\n"
+	case d.Unified != "":
+		text = d.Unified
+	default:
+		return ""
+	}
+
+	lines := textdiff.SplitLines(text)
+	return indent + strings.Join(lines, "\n"+indent) + "\n"
+}
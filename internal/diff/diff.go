@@ -1,6 +1,7 @@
 package diff
 
 import (
+	"github.com/contextsubstrate/ctx/internal/cost"
 	"github.com/contextsubstrate/ctx/internal/pack"
 	"github.com/contextsubstrate/ctx/internal/store"
 )
@@ -15,6 +16,11 @@ func Diff(storeRoot string, hashA string, hashB string) (*DriftReport, error) {
 	if err != nil {
 		return nil, err
 	}
+	for _, p := range []*pack.Pack{a, b} {
+		if err := pack.CheckKey(storeRoot, p); err != nil {
+			return nil, err
+		}
+	}
 
 	report := &DriftReport{
 		PackHashA: store.ShortHash(a.Hash, 12),
@@ -26,11 +32,33 @@ func Diff(storeRoot string, hashA string, hashB string) (*DriftReport, error) {
 		return report, nil
 	}
 
-	// Run all comparisons
-	report.Entries = append(report.Entries, ComparePrompts(a, b)...)
-	report.Entries = append(report.Entries, CompareSteps(a, b)...)
-	report.Entries = append(report.Entries, CompareOutputs(a, b)...)
-
+	report.Entries = ComparePacks(a, b)
+	attachContentDiffs(storeRoot, report.Entries)
 	report.HasDrift = len(report.Entries) > 0
+	report.LikelyCause = RankCauses(report.Entries)
+
+	// Usage and cost are reported alongside drift but are not drift themselves
+	if a.HasUsage() || b.HasUsage() {
+		prices, err := cost.LoadPrices(storeRoot)
+		if err != nil {
+			return nil, err
+		}
+		report.Cost = cost.Compare(cost.Compute(a, prices), cost.Compute(b, prices))
+	}
+
 	return report, nil
 }
+
+// ComparePacks runs every comparison between two loaded packs. Content diffs
+// and cost deltas, which need the store, are left to Diff.
+func ComparePacks(a *pack.Pack, b *pack.Pack) []DriftEntry {
+	var entries []DriftEntry
+	entries = append(entries, CompareModel(a, b)...)
+	entries = append(entries, CompareEnvironment(a, b)...)
+	entries = append(entries, ComparePrompts(a, b)...)
+	entries = append(entries, CompareInputs(a, b)...)
+	entries = append(entries, CompareAgents(a, b)...)
+	entries = append(entries, CompareSteps(a, b)...)
+	entries = append(entries, CompareOutputs(a, b)...)
+	return entries
+}
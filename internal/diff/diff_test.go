@@ -2,8 +2,12 @@ package diff
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/contextsubstrate/ctx/internal/pack"
@@ -304,3 +308,576 @@ func TestDiffNonExistentPack(t *testing.T) {
 	}
 	_ = store.ShortHash(fakeHash, 12) // just to use the import
 }
+
+func createAgentPack(t *testing.T, root string, agents []pack.LogAgent, steps []pack.LogStep) *pack.Pack {
+	t.Helper()
+	log := &pack.ExecutionLog{
+		Model:        pack.LogModel{Identifier: "test-model", Parameters: map[string]interface{}{}},
+		SystemPrompt: "sys",
+		Prompts:      defaultPrompts(),
+		Steps:        steps,
+		Outputs:      defaultOutputs(),
+		Environment:  pack.LogEnvironment{OS: "darwin", Runtime: "go1.22", ToolVersions: map[string]string{}},
+		Agents:       agents,
+	}
+	p, err := pack.CreatePack(root, log)
+	if err != nil {
+		t.Fatalf("CreatePack failed: %v", err)
+	}
+	return p
+}
+
+func TestDiffAgentDrift(t *testing.T) {
+	root := setupTestStore(t)
+	steps := []pack.LogStep{{Index: 0, Type: "tool_call", Tool: "search", Parameters: map[string]interface{}{}, Output: "out", Agent: "Researcher"}}
+	a := createAgentPack(t, root, []pack.LogAgent{
+		{Name: "Researcher", Model: pack.LogModel{Identifier: "gpt-4"}},
+		{Name: "Writer"},
+	}, steps)
+	b := createAgentPack(t, root, []pack.LogAgent{
+		{Name: "Researcher", Model: pack.LogModel{Identifier: "gpt-4o"}, SystemPrompt: "Be thorough."},
+	}, steps)
+
+	report, err := Diff(root, a.Hash, b.Hash)
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+
+	var modelChanged, writerRemoved, promptChanged bool
+	for _, e := range report.Entries {
+		switch {
+		case e.Type == AgentDrift && e.Description == `Agent "Researcher" uses a different model`:
+			modelChanged = true
+		case e.Type == AgentDrift && e.Description == `Agent "Writer" removed in pack B`:
+			writerRemoved = true
+		case e.Type == PromptDrift && e.Description == `Agent "Researcher" system prompts differ`:
+			promptChanged = true
+		}
+	}
+	if !modelChanged || !writerRemoved || !promptChanged {
+		t.Errorf("missing agent drift entries: %+v", report.Entries)
+	}
+}
+
+func TestDiffStepAgentChanged(t *testing.T) {
+	root := setupTestStore(t)
+	agents := []pack.LogAgent{{Name: "Researcher"}, {Name: "Writer"}}
+	a := createAgentPack(t, root, agents, []pack.LogStep{{Index: 0, Type: "tool_call", Tool: "search", Parameters: map[string]interface{}{}, Output: "out", Agent: "Researcher"}})
+	b := createAgentPack(t, root, agents, []pack.LogStep{{Index: 0, Type: "tool_call", Tool: "search", Parameters: map[string]interface{}{}, Output: "out", Agent: "Writer"}})
+
+	report, err := Diff(root, a.Hash, b.Hash)
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+
+	found := false
+	for _, e := range report.Entries {
+		if e.Type == AgentDrift && e.StepIndex == 0 && e.PackA == "Researcher" && e.PackB == "Writer" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected agent_drift for step 0, got %+v", report.Entries)
+	}
+}
+
+func TestDiffCostDelta(t *testing.T) {
+	root := setupTestStore(t)
+	cfg := store.DefaultConfig()
+	cfg.Prices = map[string]store.ModelPrice{"test-*": {Prompt: 1, Completion: 2}}
+	if err := store.WriteConfig(root, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	stepsA := defaultSteps()
+	stepsA[0].Usage = &pack.LogUsage{PromptTokens: 1000, CompletionTokens: 100}
+	stepsA[0].LatencyMs = 1000
+	stepsB := defaultSteps()
+	stepsB[0].Usage = &pack.LogUsage{PromptTokens: 1500, CompletionTokens: 100}
+	stepsB[0].LatencyMs = 1000
+
+	a := createPack(t, root, "sys", defaultPrompts(), stepsA, defaultOutputs())
+	b := createPack(t, root, "sys", defaultPrompts(), stepsB, defaultOutputs())
+
+	report, err := Diff(root, a.Hash, b.Hash)
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	if report.HasDrift {
+		t.Errorf("usage changes alone should not count as drift: %+v", report.Entries)
+	}
+	if report.Cost == nil || report.Cost.TokensA != 1100 || report.Cost.TokensB != 1600 {
+		t.Fatalf("unexpected cost delta: %+v", report.Cost)
+	}
+
+	human := report.Human()
+	for _, want := range []string{"No differences found.", "Tokens:  1100 → 1600 (+500)", "Cost:    $0.0012 → $0.0017"} {
+		if !strings.Contains(human, want) {
+			t.Errorf("expected %q in output:\n%s", want, human)
+		}
+	}
+}
+
+func TestDiffPromptContent(t *testing.T) {
+	root := setupTestStore(t)
+	a := createPack(t, root, "You are a helpful assistant.", defaultPrompts(), defaultSteps(), defaultOutputs())
+	b := createPack(t, root, "You are a concise assistant.", defaultPrompts(), defaultSteps(), defaultOutputs())
+
+	report, err := Diff(root, a.Hash, b.Hash)
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+
+	e := report.Entries[0]
+	if e.Content == nil {
+		t.Fatal("expected content diff on system prompt drift")
+	}
+	if e.Content.Words != "You are a [-helpful-]{+concise+} assistant." {
+		t.Errorf("unexpected word diff: %q", e.Content.Words)
+	}
+	if !strings.Contains(e.Content.Unified, "-You are a helpful assistant.") {
+		t.Errorf("unexpected unified diff: %q", e.Content.Unified)
+	}
+	if !strings.Contains(report.Human(), "[-helpful-]{+concise+}") {
+		t.Errorf("expected word diff in human output:\n%s", report.Human())
+	}
+
+	data, err := report.JSON()
+	if err != nil {
+		t.Fatalf("JSON failed: %v", err)
+	}
+	if !strings.Contains(string(data), `"unified"`) {
+		t.Errorf("expected unified diff in JSON output:\n%s", data)
+	}
+}
+
+func TestDiffStepOutputContent(t *testing.T) {
+	root := setupTestStore(t)
+	stepsA := defaultSteps()
+	stepsA[0].Output = "line 1\nline 2\nline 3\nline 4\nline 5\n"
+	stepsB := defaultSteps()
+	stepsB[0].Output = "line 1\nline 2\nline three\nline 4\nline 5\n"
+
+	a := createPack(t, root, "sys", defaultPrompts(), stepsA, defaultOutputs())
+	b := createPack(t, root, "sys", defaultPrompts(), stepsB, defaultOutputs())
+
+	report, err := Diff(root, a.Hash, b.Hash)
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+
+	for _, e := range report.Entries {
+		if e.Type != ReasoningDrift {
+			continue
+		}
+		if e.Content == nil || !strings.Contains(e.Content.Unified, "-line 3\n+line three\n") {
+			t.Errorf("expected line diff of step output, got %+v", e.Content)
+		}
+		if e.Content.Words != "" {
+			t.Errorf("expected no word diff for line-oriented text, got %q", e.Content.Words)
+		}
+		return
+	}
+	t.Fatal("expected reasoning drift entry")
+}
+
+func TestCompareContentLimits(t *testing.T) {
+	if d := CompareContent([]byte("a\x00b"), []byte("text")); !d.Binary || d.Unified != "" {
+		t.Errorf("expected binary content to be reported by size only, got %+v", d)
+	}
+
+	big := []byte(strings.Repeat("x\n", MaxContentDiffBytes))
+	if d := CompareContent(big, []byte("small")); d.Omitted == "" || d.Unified != "" {
+		t.Errorf("expected oversized content to be omitted, got %+v", d)
+	}
+
+	// Short lines keep each side under the byte cap but would need an LCS
+	// table of tens of millions of cells.
+	var a, b strings.Builder
+	for i := 0; i < 20000; i++ {
+		fmt.Fprintf(&a, "a%d\n", i)
+		fmt.Fprintf(&b, "b%d\n", i)
+	}
+	if d := CompareContent([]byte(a.String()), []byte(b.String())); d.Omitted == "" || d.Unified != "" {
+		t.Errorf("expected too many changed lines to be omitted, got %+v", d.Omitted)
+	}
+}
+
+func toolSteps(calls ...string) []pack.LogStep {
+	var steps []pack.LogStep
+	for i, call := range calls {
+		tool, path, _ := strings.Cut(call, ":")
+		steps = append(steps, pack.LogStep{
+			Index: i, Type: "tool_call", Tool: tool,
+			Parameters: map[string]interface{}{"path": path},
+			Output:     "out " + call, Deterministic: true,
+		})
+	}
+	return steps
+}
+
+func alignmentOps(t *testing.T, a, b []pack.LogStep) []StepPair {
+	t.Helper()
+	root := setupTestStore(t)
+	pa := createPack(t, root, "sys", defaultPrompts(), a, defaultOutputs())
+	pb := createPack(t, root, "sys", defaultPrompts(), b, defaultOutputs())
+	return AlignSteps(pa.Steps, pb.Steps)
+}
+
+func TestAlignStepsInsertion(t *testing.T) {
+	pairs := alignmentOps(t,
+		toolSteps("read_file:a", "search:q", "write_file:out"),
+		toolSteps("read_file:a", "read_file:extra", "search:q", "write_file:out"))
+
+	want := []StepPair{
+		{A: 0, B: 0, Op: AlignMatch},
+		{A: -1, B: 1, Op: AlignInsert},
+		{A: 1, B: 2, Op: AlignMatch},
+		{A: 2, B: 3, Op: AlignMatch},
+	}
+	if !reflect.DeepEqual(pairs, want) {
+		t.Errorf("unexpected alignment:\n%+v\nwant:\n%+v", pairs, want)
+	}
+}
+
+func TestAlignStepsChangeMoveSubstitute(t *testing.T) {
+	pairs := alignmentOps(t,
+		toolSteps("lint:x", "read_file:a", "search:q", "write_file:out", "test:y"),
+		toolSteps("read_file:b", "search:q", "summarize:s", "test:y", "lint:x"))
+
+	want := []StepPair{
+		{A: 0, B: 4, Op: AlignMove},
+		{A: 1, B: 0, Op: AlignChange},
+		{A: 2, B: 1, Op: AlignMatch},
+		{A: 3, B: 2, Op: AlignSubstitute},
+		{A: 4, B: 3, Op: AlignMatch},
+	}
+	if !reflect.DeepEqual(pairs, want) {
+		t.Errorf("unexpected alignment:\n%+v\nwant:\n%+v", pairs, want)
+	}
+}
+
+func TestDiffInsertedStepReportedOnce(t *testing.T) {
+	root := setupTestStore(t)
+	a := createPack(t, root, "sys", defaultPrompts(), toolSteps("read_file:a", "search:q", "write_file:out"), defaultOutputs())
+	b := createPack(t, root, "sys", defaultPrompts(), toolSteps("read_file:a", "read_file:extra", "search:q", "write_file:out"), defaultOutputs())
+
+	report, err := Diff(root, a.Hash, b.Hash)
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	if len(report.Entries) != 1 {
+		t.Fatalf("expected a single insertion, got %+v", report.Entries)
+	}
+	e := report.Entries[0]
+	if e.Alignment != AlignInsert || e.StepIndex != 1 || e.Description != "Step 1: read_file added in pack B" {
+		t.Errorf("unexpected entry: %+v", e)
+	}
+}
+
+func TestDiffStepIndexBZero(t *testing.T) {
+	root := setupTestStore(t)
+	a := createPack(t, root, "sys", defaultPrompts(), toolSteps("search:q"), defaultOutputs())
+	b := createPack(t, root, "sys", defaultPrompts(), toolSteps("read_file:extra", "search:q"), defaultOutputs())
+
+	report, err := Diff(root, a.Hash, b.Hash)
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	if len(report.Entries) == 0 {
+		t.Fatal("expected drift entries")
+	}
+	for _, e := range report.Entries {
+		if e.StepIndexB == nil {
+			continue
+		}
+		data, err := json.Marshal(e)
+		if err != nil {
+			t.Fatalf("Marshal failed: %v", err)
+		}
+		if want := fmt.Sprintf(`"step_index_b":%d`, *e.StepIndexB); !strings.Contains(string(data), want) {
+			t.Errorf("expected %s in %s", want, data)
+		}
+	}
+	if e := report.Entries[0]; e.Alignment != AlignInsert || e.StepIndexB == nil || *e.StepIndexB != 0 {
+		t.Errorf("expected an insertion at step 0 of pack B, got %+v", e)
+	}
+}
+
+func TestCompareParams(t *testing.T) {
+	a := map[string]interface{}{
+		"query":       "go",
+		"max_results": float64(10),
+		"filters":     map[string]interface{}{"lang": "en", "a/b": true},
+		"tags":        []interface{}{"x", "y"},
+	}
+	b := map[string]interface{}{
+		"query":       "go",
+		"max_results": float64(20),
+		"filters":     map[string]interface{}{"lang": "de"},
+		"tags":        []interface{}{"x", "y", "z"},
+		"safe":        true,
+	}
+
+	got := CompareParams(a, b)
+	want := []ParamChange{
+		{Path: "/filters/a~1b", Op: ParamRemoved, Old: true},
+		{Path: "/filters/lang", Op: ParamChanged, Old: "en", New: "de"},
+		{Path: "/max_results", Op: ParamChanged, Old: float64(10), New: float64(20)},
+		{Path: "/safe", Op: ParamAdded, New: true},
+		{Path: "/tags/2", Op: ParamAdded, New: "z"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected param changes:\n%+v\nwant:\n%+v", got, want)
+	}
+}
+
+func TestDiffParamDriftPaths(t *testing.T) {
+	root := setupTestStore(t)
+	stepsA := []pack.LogStep{{Index: 0, Type: "tool_call", Tool: "search", Parameters: map[string]interface{}{"query": "go", "max_results": float64(10)}, Output: "out", Deterministic: true}}
+	stepsB := []pack.LogStep{{Index: 0, Type: "tool_call", Tool: "search", Parameters: map[string]interface{}{"query": "go", "max_results": float64(20)}, Output: "out", Deterministic: true}}
+
+	a := createPack(t, root, "sys", defaultPrompts(), stepsA, defaultOutputs())
+	b := createPack(t, root, "sys", defaultPrompts(), stepsB, defaultOutputs())
+
+	report, err := Diff(root, a.Hash, b.Hash)
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	if len(report.Entries) != 1 || len(report.Entries[0].Params) != 1 {
+		t.Fatalf("expected one param change, got %+v", report.Entries)
+	}
+	if !strings.Contains(report.Human(), "/max_results: 10 → 20") {
+		t.Errorf("expected param path in human output:\n%s", report.Human())
+	}
+}
+
+func createEnvPack(t *testing.T, root string, model pack.LogModel, env pack.LogEnvironment, inputs []pack.LogInput) *pack.Pack {
+	t.Helper()
+	log := &pack.ExecutionLog{
+		Model:        model,
+		SystemPrompt: "sys",
+		Prompts:      defaultPrompts(),
+		Inputs:       inputs,
+		Steps:        defaultSteps(),
+		Outputs:      defaultOutputs(),
+		Environment:  env,
+	}
+	p, err := pack.CreatePack(root, log)
+	if err != nil {
+		t.Fatalf("CreatePack failed: %v", err)
+	}
+	return p
+}
+
+func TestDiffModelSamplingEnvironmentInputDrift(t *testing.T) {
+	root := setupTestStore(t)
+	a := createEnvPack(t, root,
+		pack.LogModel{Identifier: "gpt-4", Parameters: map[string]interface{}{"temperature": float64(0), "seed": float64(1)}},
+		pack.LogEnvironment{OS: "darwin", Runtime: "go1.22", ToolVersions: map[string]string{"read_file": "1.0"}},
+		[]pack.LogInput{{Name: "a.txt", Content: "alpha\n"}, {Name: "old.txt", Content: "x"}})
+	b := createEnvPack(t, root,
+		pack.LogModel{Identifier: "gpt-4o", Parameters: map[string]interface{}{"temperature": float64(0.7), "seed": float64(1)}},
+		pack.LogEnvironment{OS: "linux", Runtime: "go1.22", ToolVersions: map[string]string{"read_file": "1.1"}},
+		[]pack.LogInput{{Name: "a.txt", Content: "beta\n"}, {Name: "new.txt", Content: "y"}})
+
+	report, err := Diff(root, a.Hash, b.Hash)
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+
+	got := make(map[string]bool)
+	for _, e := range report.Entries {
+		got[string(e.Type)+": "+e.Description] = true
+	}
+	for _, want := range []string{
+		"model_drift: Different model",
+		"sampling_drift: Sampling parameters differ (temperature)",
+		"environment_drift: Different OS",
+		`environment_drift: Tool "read_file" version changed`,
+		`input_drift: Input "a.txt" content differs`,
+		`input_drift: Input "old.txt" removed in pack B`,
+		`input_drift: Input "new.txt" added in pack B`,
+	} {
+		if !got[want] {
+			t.Errorf("missing entry %q in %+v", want, report.Entries)
+		}
+	}
+	if !strings.Contains(report.Human(), "[sampling_drift]") {
+		t.Errorf("expected sampling drift in human output:\n%s", report.Human())
+	}
+}
+
+func TestDiffLikelyCauseFirstStep(t *testing.T) {
+	root := setupTestStore(t)
+	stepsA := toolSteps("read_file:a", "search:q", "write_file:out")
+	stepsB := toolSteps("read_file:a", "search:other", "write_file:out")
+	stepsB[2].Output = "different"
+
+	a := createPack(t, root, "sys", defaultPrompts(), stepsA, []pack.LogOutput{{Name: "r", Content: "A"}})
+	b := createPack(t, root, "sys", defaultPrompts(), stepsB, []pack.LogOutput{{Name: "r", Content: "B"}})
+
+	report, err := Diff(root, a.Hash, b.Hash)
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+
+	lc := report.LikelyCause
+	if lc == nil || len(lc.Entries) != 1 {
+		t.Fatalf("expected a single likely cause, got %+v", lc)
+	}
+	cause := report.Entries[lc.Entries[0]]
+	if cause.Type != ParamDrift || cause.StepIndex != 1 || cause.Downstream {
+		t.Errorf("expected step 1 param drift as likely cause, got %+v", cause)
+	}
+	if lc.Downstream != len(report.Entries)-1 {
+		t.Errorf("expected every other entry downstream, got %d of %d", lc.Downstream, len(report.Entries))
+	}
+
+	human := report.Human()
+	if !strings.HasPrefix(strings.SplitN(human, "Likely cause:\n", 2)[1], "  [param_drift] Step 1: search") {
+		t.Errorf("expected likely cause at top of human output:\n%s", human)
+	}
+	if !strings.Contains(human, "(downstream)") {
+		t.Errorf("expected downstream markers:\n%s", human)
+	}
+}
+
+func TestDiffLikelyCauseRunLevel(t *testing.T) {
+	root := setupTestStore(t)
+	stepsB := defaultSteps()
+	stepsB[0].Output = "changed"
+	a := createPack(t, root, "sys A", defaultPrompts(), defaultSteps(), defaultOutputs())
+	b := createPack(t, root, "sys B", defaultPrompts(), stepsB, defaultOutputs())
+
+	report, err := Diff(root, a.Hash, b.Hash)
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	lc := report.LikelyCause
+	if lc == nil || len(lc.Entries) != 1 || report.Entries[lc.Entries[0]].Type != PromptDrift {
+		t.Fatalf("expected prompt drift as likely cause, got %+v", lc)
+	}
+	if lc.Summary != "System prompts differ" || lc.Downstream != 1 {
+		t.Errorf("unexpected likely cause: %+v", lc)
+	}
+}
+
+func TestDiffLikelyCauseIgnoresEnvironment(t *testing.T) {
+	root := setupTestStore(t)
+	create := func(runtime, path string) *pack.Pack {
+		steps := defaultSteps()
+		steps[0].Parameters = map[string]interface{}{"path": path}
+		p, err := pack.CreatePack(root, &pack.ExecutionLog{
+			Model:        pack.LogModel{Identifier: "test-model", Parameters: map[string]interface{}{}},
+			SystemPrompt: "sys",
+			Prompts:      defaultPrompts(),
+			Steps:        steps,
+			Outputs:      defaultOutputs(),
+			Environment:  pack.LogEnvironment{OS: "darwin", Runtime: runtime, ToolVersions: map[string]string{}},
+		})
+		if err != nil {
+			t.Fatalf("CreatePack failed: %v", err)
+		}
+		return p
+	}
+	a := create("go1.22", "a.txt")
+
+	report, err := Diff(root, a.Hash, create("go1.23", "b.txt").Hash)
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	lc := report.LikelyCause
+	if lc == nil || len(lc.Entries) != 1 || report.Entries[lc.Entries[0]].Type != ParamDrift {
+		t.Fatalf("expected the step divergence as likely cause, got %+v", lc)
+	}
+
+	report, err = Diff(root, a.Hash, create("go1.23", "a.txt").Hash)
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	lc = report.LikelyCause
+	if lc == nil || len(lc.Entries) != 1 || report.Entries[lc.Entries[0]].Type != EnvironmentDrift {
+		t.Fatalf("expected environment drift as the only cause, got %+v", lc)
+	}
+}
+
+func TestDiffMany(t *testing.T) {
+	root := setupTestStore(t)
+	outputs := func(s string) []pack.LogOutput { return []pack.LogOutput{{Name: "r", Content: s}} }
+
+	var hashes []string
+	for i := 0; i < 3; i++ {
+		steps := toolSteps("read_file:a", "search:q", "write_file:out")
+		steps[1].Output = fmt.Sprintf("results %d", i%2)
+		hashes = append(hashes, createPack(t, root, "sys", defaultPrompts(), steps, outputs(fmt.Sprint(i))).Hash)
+	}
+	odd := toolSteps("list_dir:.", "search:other", "search:more", "write_file:elsewhere")
+	hashes = append(hashes, createPack(t, root, "sys", defaultPrompts(), odd, outputs("odd")).Hash)
+
+	report, err := DiffMany(root, hashes, DefaultClusterThreshold)
+	if err != nil {
+		t.Fatalf("DiffMany failed: %v", err)
+	}
+
+	if len(report.Pairs) != 6 {
+		t.Errorf("expected 6 pairs, got %d", len(report.Pairs))
+	}
+	if len(report.Clusters) != 2 || len(report.Clusters[0].Packs) != 3 || len(report.Clusters[1].Packs) != 1 {
+		t.Fatalf("expected groups of 3 and 1, got %+v", report.Clusters)
+	}
+
+	want := []StepStability{
+		{Index: 0, Tool: "read_file", SameCall: 3, SameOutput: 3},
+		{Index: 1, Tool: "search", SameCall: 3, SameOutput: 2},
+		{Index: 2, Tool: "write_file", SameCall: 3, SameOutput: 3},
+	}
+	if !reflect.DeepEqual(report.Steps, want) {
+		t.Errorf("unexpected step stability:\n%+v\nwant:\n%+v", report.Steps, want)
+	}
+	if len(report.Extra) != 1 || report.Extra[0] != (ExtraStep{Tool: "search", Runs: 1}) {
+		t.Errorf("expected the odd run's second search as an extra call, got %+v", report.Extra)
+	}
+
+	human := report.Human()
+	for _, want := range []string{"Comparing 4 runs", "Group 1 (3 run(s)): read_file → search → write_file", "same call in 3/4 runs"} {
+		if !strings.Contains(human, want) {
+			t.Errorf("expected %q in output:\n%s", want, human)
+		}
+	}
+
+	if _, err := DiffMany(root, hashes[:1], DefaultClusterThreshold); err == nil {
+		t.Error("expected error for a single pack")
+	}
+}
+
+func TestDiffEncryptedPackWithoutKey(t *testing.T) {
+	root := setupTestStore(t)
+	key, _ := store.GenerateKey(root)
+	a := createPack(t, root, "system prompt", defaultPrompts(), defaultSteps(), defaultOutputs())
+	log := &pack.ExecutionLog{
+		Model:        pack.LogModel{Identifier: "test-model"},
+		SystemPrompt: "system prompt",
+		Prompts:      defaultPrompts(),
+		Steps:        defaultSteps(),
+		Outputs:      []pack.LogOutput{{Name: "result.txt", Content: "secret result"}},
+		Environment:  pack.LogEnvironment{OS: "darwin", Runtime: "go1.22"},
+	}
+	b, err := pack.CreatePackWithKey(root, log, key.ID)
+	if err != nil {
+		t.Fatalf("CreatePackWithKey failed: %v", err)
+	}
+
+	report, err := Diff(root, a.Hash, b.Hash)
+	if err != nil {
+		t.Fatalf("Diff failed with the key present: %v", err)
+	}
+	if !report.HasDrift {
+		t.Error("expected the decrypted output to differ")
+	}
+
+	os.RemoveAll(filepath.Join(root, store.KeyDir))
+	if _, err := Diff(root, a.Hash, b.Hash); !errors.Is(err, store.ErrKeyUnavailable) {
+		t.Errorf("expected diff to refuse without the key, got %v", err)
+	}
+}
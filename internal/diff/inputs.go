@@ -0,0 +1,54 @@
+package diff
+
+import (
+	"fmt"
+
+	"github.com/contextsubstrate/ctx/internal/pack"
+	"github.com/contextsubstrate/ctx/internal/store"
+)
+
+// CompareInputs compares the input files of two packs by name and content.
+func CompareInputs(a *pack.Pack, b *pack.Pack) []DriftEntry {
+	var entries []DriftEntry
+
+	refsB := make(map[string]string) // name -> content_ref
+	for _, in := range b.Inputs {
+		refsB[in.Name] = in.ContentRef
+	}
+	namesA := make(map[string]bool)
+
+	for _, in := range a.Inputs {
+		namesA[in.Name] = true
+		refB, ok := refsB[in.Name]
+		if !ok {
+			entries = append(entries, DriftEntry{
+				Type:        InputDrift,
+				Description: fmt.Sprintf("Input %q removed in pack B", in.Name),
+				PackA:       store.ShortHash(in.ContentRef, 12),
+			})
+			continue
+		}
+		if in.ContentRef != refB {
+			entries = append(entries, DriftEntry{
+				Type:        InputDrift,
+				Description: fmt.Sprintf("Input %q content differs", in.Name),
+				PackA:       store.ShortHash(in.ContentRef, 12),
+				PackB:       store.ShortHash(refB, 12),
+				refA:        in.ContentRef,
+				refB:        refB,
+			})
+		}
+	}
+
+	for _, in := range b.Inputs {
+		if !namesA[in.Name] {
+			entries = append(entries, DriftEntry{
+				Type:        InputDrift,
+				Description: fmt.Sprintf("Input %q added in pack B", in.Name),
+				PackB:       store.ShortHash(in.ContentRef, 12),
+			})
+		}
+	}
+
+	return entries
+}
@@ -0,0 +1,306 @@
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/contextsubstrate/ctx/internal/pack"
+	"github.com/contextsubstrate/ctx/internal/store"
+)
+
+// DefaultClusterThreshold is the step-sequence similarity at or above which
+// two runs are placed in the same behavioral group.
+const DefaultClusterThreshold = 0.8
+
+// maxDivergentPairs caps the pairs listed in the human-readable many report.
+const maxDivergentPairs = 5
+
+// PairDrift summarizes the drift between two of the compared runs.
+type PairDrift struct {
+	PackA       string  `json:"pack_a"`
+	PackB       string  `json:"pack_b"`
+	Differences int     `json:"differences"`
+	Similarity  float64 `json:"similarity"`
+	LikelyCause string  `json:"likely_cause,omitempty"`
+}
+
+// Cluster is a group of runs whose step sequences are similar.
+type Cluster struct {
+	Packs []string `json:"packs"`
+	Tools []string `json:"tools"`
+}
+
+// StepStability describes how one step of the reference run behaves across
+// all runs: how many made the same call and how many also got the same output.
+type StepStability struct {
+	Index      int    `json:"index"`
+	Tool       string `json:"tool"`
+	SameCall   int    `json:"same_call"`
+	SameOutput int    `json:"same_output"`
+	Stable     bool   `json:"stable"`
+}
+
+// ExtraStep counts runs that made a call the reference run did not.
+type ExtraStep struct {
+	Tool string `json:"tool"`
+	Runs int    `json:"runs"`
+}
+
+// ManyReport is the result of comparing several runs of the same task.
+type ManyReport struct {
+	Packs     []string        `json:"packs"`
+	Reference string          `json:"reference"`
+	Threshold float64         `json:"threshold"`
+	Pairs     []PairDrift     `json:"pairs"`
+	Clusters  []Cluster       `json:"clusters"`
+	Steps     []StepStability `json:"steps"`
+	Extra     []ExtraStep     `json:"extra_steps,omitempty"`
+	Stability float64         `json:"stability"`
+}
+
+// DiffMany compares every pair of the given packs, groups the runs by
+// step-sequence similarity, and reports which steps of the most
+// representative run are stable across all runs.
+func DiffMany(storeRoot string, hashes []string, threshold float64) (*ManyReport, error) {
+	if len(hashes) < 2 {
+		return nil, fmt.Errorf("comparing many packs: need at least 2, got %d", len(hashes))
+	}
+
+	packs := make([]*pack.Pack, len(hashes))
+	for i, h := range hashes {
+		p, err := pack.LoadPack(storeRoot, h)
+		if err != nil {
+			return nil, err
+		}
+		if err := pack.CheckKey(storeRoot, p); err != nil {
+			return nil, err
+		}
+		packs[i] = p
+	}
+
+	report := &ManyReport{Threshold: threshold}
+	for _, p := range packs {
+		report.Packs = append(report.Packs, store.ShortHash(p.Hash, 12))
+	}
+
+	// Pairwise drift and similarity
+	n := len(packs)
+	sim := make([][]float64, n)
+	for i := range sim {
+		sim[i] = make([]float64, n)
+		sim[i][i] = 1
+	}
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			sim[i][j] = StepSimilarity(packs[i].Steps, packs[j].Steps)
+			sim[j][i] = sim[i][j]
+
+			pair := PairDrift{PackA: report.Packs[i], PackB: report.Packs[j], Similarity: sim[i][j]}
+			if packs[i].Hash != packs[j].Hash {
+				entries := ComparePacks(packs[i], packs[j])
+				pair.Differences = len(entries)
+				if lc := RankCauses(entries); lc != nil {
+					pair.LikelyCause = lc.Summary
+				}
+			}
+			report.Pairs = append(report.Pairs, pair)
+		}
+	}
+
+	report.Clusters = clusterRuns(packs, report.Packs, sim, threshold)
+
+	// The reference run is the medoid: the run most similar to all others.
+	ref, best := 0, -1.0
+	for i := range packs {
+		total := 0.0
+		for j := range packs {
+			total += sim[i][j]
+		}
+		if total > best {
+			ref, best = i, total
+		}
+	}
+	report.Reference = report.Packs[ref]
+	report.Steps, report.Extra = stepStability(packs, ref)
+
+	stable := 0
+	for _, s := range report.Steps {
+		if s.Stable {
+			stable++
+		}
+	}
+	if len(report.Steps) > 0 {
+		report.Stability = float64(stable) / float64(len(report.Steps))
+	}
+
+	return report, nil
+}
+
+// StepSimilarity scores two step sequences from 0 to 1: twice the number of
+// identical calls (same tool and parameters, in order) over the total number
+// of steps. Two empty sequences are identical.
+func StepSimilarity(a, b []pack.Step) float64 {
+	if len(a)+len(b) == 0 {
+		return 1
+	}
+	matched := 0
+	for _, pair := range AlignSteps(a, b) {
+		if pair.Op == AlignMatch {
+			matched++
+		}
+	}
+	return 2 * float64(matched) / float64(len(a)+len(b))
+}
+
+// clusterRuns groups runs by single-linkage clustering: runs whose similarity
+// reaches the threshold end up in the same group. Groups are ordered by size.
+func clusterRuns(packs []*pack.Pack, names []string, sim [][]float64, threshold float64) []Cluster {
+	group := make([]int, len(packs))
+	for i := range group {
+		group[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if group[i] != i {
+			group[i] = find(group[i])
+		}
+		return group[i]
+	}
+	for i := range packs {
+		for j := i + 1; j < len(packs); j++ {
+			if sim[i][j] >= threshold {
+				group[find(j)] = find(i)
+			}
+		}
+	}
+
+	var clusters []Cluster
+	index := make(map[int]int)
+	for i := range packs {
+		root := find(i)
+		k, ok := index[root]
+		if !ok {
+			k = len(clusters)
+			index[root] = k
+			clusters = append(clusters, Cluster{Tools: toolSequence(packs[i])})
+		}
+		clusters[k].Packs = append(clusters[k].Packs, names[i])
+	}
+	sort.SliceStable(clusters, func(x, y int) bool { return len(clusters[x].Packs) > len(clusters[y].Packs) })
+	return clusters
+}
+
+// stepStability aligns every run against the reference run and counts, per
+// reference step, the runs that made the same call and got the same output.
+func stepStability(packs []*pack.Pack, ref int) ([]StepStability, []ExtraStep) {
+	refSteps := packs[ref].Steps
+	steps := make([]StepStability, len(refSteps))
+	for i, s := range refSteps {
+		steps[i] = StepStability{Index: i, Tool: s.Tool}
+	}
+
+	extra := make(map[string]int)
+	for _, p := range packs {
+		inserted := make(map[string]bool)
+		for _, pair := range AlignSteps(refSteps, p.Steps) {
+			switch pair.Op {
+			case AlignMatch, AlignMove:
+				steps[pair.A].SameCall++
+				if refSteps[pair.A].OutputRef == p.Steps[pair.B].OutputRef {
+					steps[pair.A].SameOutput++
+				}
+			case AlignInsert:
+				inserted[p.Steps[pair.B].Tool] = true
+			}
+		}
+		for tool := range inserted {
+			extra[tool]++
+		}
+	}
+
+	for i := range steps {
+		steps[i].Stable = steps[i].SameOutput == len(packs)
+	}
+
+	var extras []ExtraStep
+	for tool, runs := range extra {
+		extras = append(extras, ExtraStep{Tool: tool, Runs: runs})
+	}
+	sort.Slice(extras, func(i, j int) bool {
+		if extras[i].Runs != extras[j].Runs {
+			return extras[i].Runs > extras[j].Runs
+		}
+		return extras[i].Tool < extras[j].Tool
+	})
+	return steps, extras
+}
+
+func toolSequence(p *pack.Pack) []string {
+	tools := make([]string, len(p.Steps))
+	for i, s := range p.Steps {
+		tools[i] = s.Tool
+	}
+	return tools
+}
+
+// JSON returns the report as JSON bytes.
+func (r *ManyReport) JSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
+
+// Human returns a human-readable summary of the many-run comparison.
+func (r *ManyReport) Human() string {
+	var b strings.Builder
+	total := len(r.Packs)
+	b.WriteString(fmt.Sprintf("Comparing %d runs\n\n", total))
+
+	b.WriteString(fmt.Sprintf("Behavioral groups (step similarity ≥ %.2f):\n", r.Threshold))
+	for i, c := range r.Clusters {
+		b.WriteString(fmt.Sprintf("  Group %d (%d run(s)): %s\n", i+1, len(c.Packs), strings.Join(c.Tools, " → ")))
+		b.WriteString(fmt.Sprintf("    %s\n", strings.Join(c.Packs, ", ")))
+	}
+
+	b.WriteString(fmt.Sprintf("\nStep stability (reference run %s):\n", r.Reference))
+	for _, s := range r.Steps {
+		switch {
+		case s.Stable:
+			b.WriteString(fmt.Sprintf("  ✓ [%d] %-20s stable\n", s.Index, s.Tool))
+		case s.SameCall == total:
+			b.WriteString(fmt.Sprintf("  ≈ [%d] %-20s same call in all runs, same output in %d/%d\n", s.Index, s.Tool, s.SameOutput, total))
+		default:
+			b.WriteString(fmt.Sprintf("  ✗ [%d] %-20s same call in %d/%d runs, same output in %d/%d\n", s.Index, s.Tool, s.SameCall, total, s.SameOutput, total))
+		}
+	}
+	if len(r.Extra) > 0 {
+		b.WriteString("\nCalls missing from the reference run:\n")
+		for _, e := range r.Extra {
+			b.WriteString(fmt.Sprintf("  %-20s in %d run(s)\n", e.Tool, e.Runs))
+		}
+	}
+
+	stable := 0
+	for _, s := range r.Steps {
+		if s.Stable {
+			stable++
+		}
+	}
+	b.WriteString(fmt.Sprintf("\nStable steps: %d/%d (%.0f%%)\n", stable, len(r.Steps), r.Stability*100))
+
+	pairs := append([]PairDrift(nil), r.Pairs...)
+	sort.SliceStable(pairs, func(i, j int) bool { return pairs[i].Similarity < pairs[j].Similarity })
+	if len(pairs) > maxDivergentPairs {
+		pairs = pairs[:maxDivergentPairs]
+	}
+	b.WriteString("\nMost divergent pairs:\n")
+	for _, p := range pairs {
+		b.WriteString(fmt.Sprintf("  %s vs %s  similarity %.2f, %d difference(s)", p.PackA, p.PackB, p.Similarity, p.Differences))
+		if p.LikelyCause != "" {
+			b.WriteString(fmt.Sprintf("  — %s", p.LikelyCause))
+		}
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
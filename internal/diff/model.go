@@ -0,0 +1,116 @@
+package diff
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/contextsubstrate/ctx/internal/pack"
+)
+
+// CompareModel compares the model identifier and sampling parameters
+// (temperature, top_p, seed, …) of two packs.
+func CompareModel(a *pack.Pack, b *pack.Pack) []DriftEntry {
+	var entries []DriftEntry
+
+	if a.Model.Identifier != b.Model.Identifier {
+		entries = append(entries, DriftEntry{
+			Type:        ModelDrift,
+			Description: "Different model",
+			PackA:       a.Model.Identifier,
+			PackB:       b.Model.Identifier,
+		})
+	}
+
+	if !reflect.DeepEqual(a.Model.Parameters, b.Model.Parameters) {
+		changes := CompareParams(a.Model.Parameters, b.Model.Parameters)
+		entries = append(entries, DriftEntry{
+			Type:        SamplingDrift,
+			Description: fmt.Sprintf("Sampling parameters differ (%s)", strings.Join(changedKeys(changes), ", ")),
+			PackA:       a.Model.Parameters,
+			PackB:       b.Model.Parameters,
+			Params:      changes,
+		})
+	}
+
+	return entries
+}
+
+// CompareEnvironment compares the OS, runtime and tool versions recorded in two packs.
+func CompareEnvironment(a *pack.Pack, b *pack.Pack) []DriftEntry {
+	var entries []DriftEntry
+
+	if a.Environment.OS != b.Environment.OS {
+		entries = append(entries, DriftEntry{
+			Type:        EnvironmentDrift,
+			Description: "Different OS",
+			PackA:       a.Environment.OS,
+			PackB:       b.Environment.OS,
+		})
+	}
+	if a.Environment.Runtime != b.Environment.Runtime {
+		entries = append(entries, DriftEntry{
+			Type:        EnvironmentDrift,
+			Description: "Different runtime",
+			PackA:       a.Environment.Runtime,
+			PackB:       b.Environment.Runtime,
+		})
+	}
+
+	tools := make(map[string]bool)
+	for tool := range a.Environment.ToolVersions {
+		tools[tool] = true
+	}
+	for tool := range b.Environment.ToolVersions {
+		tools[tool] = true
+	}
+	names := make([]string, 0, len(tools))
+	for tool := range tools {
+		names = append(names, tool)
+	}
+	sort.Strings(names)
+
+	for _, tool := range names {
+		va, inA := a.Environment.ToolVersions[tool]
+		vb, inB := b.Environment.ToolVersions[tool]
+		switch {
+		case !inB:
+			entries = append(entries, DriftEntry{
+				Type:        EnvironmentDrift,
+				Description: fmt.Sprintf("Tool %q not recorded in pack B", tool),
+				PackA:       va,
+			})
+		case !inA:
+			entries = append(entries, DriftEntry{
+				Type:        EnvironmentDrift,
+				Description: fmt.Sprintf("Tool %q added in pack B", tool),
+				PackB:       vb,
+			})
+		case va != vb:
+			entries = append(entries, DriftEntry{
+				Type:        EnvironmentDrift,
+				Description: fmt.Sprintf("Tool %q version changed", tool),
+				PackA:       va,
+				PackB:       vb,
+			})
+		}
+	}
+
+	return entries
+}
+
+// changedKeys returns the top-level parameter names touched by a set of changes.
+func changedKeys(changes []ParamChange) []string {
+	var keys []string
+	seen := make(map[string]bool)
+	for _, c := range changes {
+		key, _, _ := strings.Cut(strings.TrimPrefix(c.Path, "/"), "/")
+		key = strings.NewReplacer("~1", "/", "~0", "~").Replace(key)
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
@@ -38,6 +38,8 @@ func CompareOutputs(a *pack.Pack, b *pack.Pack) []DriftEntry {
 				Description: fmt.Sprintf("Output %q content differs", name),
 				PackA:       store.ShortHash(refA, 12),
 				PackB:       store.ShortHash(refB, 12),
+				refA:        refA,
+				refB:        refB,
 			})
 		}
 	}
@@ -0,0 +1,124 @@
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ParamOp is the kind of change made to a single parameter value.
+type ParamOp string
+
+const (
+	ParamAdded   ParamOp = "added"
+	ParamRemoved ParamOp = "removed"
+	ParamChanged ParamOp = "changed"
+)
+
+// ParamChange is one difference between two parameter objects, located by a
+// JSON pointer (RFC 6901) such as "/filters/max_results".
+type ParamChange struct {
+	Path string      `json:"path"`
+	Op   ParamOp     `json:"op"`
+	Old  interface{} `json:"old,omitempty"`
+	New  interface{} `json:"new,omitempty"`
+}
+
+// CompareParams computes the structural difference between two parameter
+// objects, descending into nested objects and arrays. Changes are ordered by path.
+func CompareParams(a, b map[string]interface{}) []ParamChange {
+	var changes []ParamChange
+	compareValues("", a, b, &changes)
+	return changes
+}
+
+func compareValues(path string, a, b interface{}, changes *[]ParamChange) {
+	switch va := a.(type) {
+	case map[string]interface{}:
+		if vb, ok := b.(map[string]interface{}); ok {
+			compareObjects(path, va, vb, changes)
+			return
+		}
+	case []interface{}:
+		if vb, ok := b.([]interface{}); ok {
+			compareArrays(path, va, vb, changes)
+			return
+		}
+	}
+
+	if !reflect.DeepEqual(a, b) {
+		*changes = append(*changes, ParamChange{Path: path, Op: ParamChanged, Old: a, New: b})
+	}
+}
+
+func compareObjects(path string, a, b map[string]interface{}, changes *[]ParamChange) {
+	keys := make(map[string]bool)
+	for k := range a {
+		keys[k] = true
+	}
+	for k := range b {
+		keys[k] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	for _, k := range sorted {
+		child := path + "/" + escapePointer(k)
+		va, inA := a[k]
+		vb, inB := b[k]
+		switch {
+		case !inB:
+			*changes = append(*changes, ParamChange{Path: child, Op: ParamRemoved, Old: va})
+		case !inA:
+			*changes = append(*changes, ParamChange{Path: child, Op: ParamAdded, New: vb})
+		default:
+			compareValues(child, va, vb, changes)
+		}
+	}
+}
+
+func compareArrays(path string, a, b []interface{}, changes *[]ParamChange) {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		child := path + "/" + strconv.Itoa(i)
+		switch {
+		case i >= len(b):
+			*changes = append(*changes, ParamChange{Path: child, Op: ParamRemoved, Old: a[i]})
+		case i >= len(a):
+			*changes = append(*changes, ParamChange{Path: child, Op: ParamAdded, New: b[i]})
+		default:
+			compareValues(child, a[i], b[i], changes)
+		}
+	}
+}
+
+// escapePointer escapes a key for use as a JSON pointer reference token.
+func escapePointer(key string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
+
+// String renders the change as "/path: old → new", "+ /path: new" or "- /path: old".
+func (c ParamChange) String() string {
+	switch c.Op {
+	case ParamAdded:
+		return fmt.Sprintf("+ %s: %s", c.Path, formatValue(c.New))
+	case ParamRemoved:
+		return fmt.Sprintf("- %s: %s", c.Path, formatValue(c.Old))
+	default:
+		return fmt.Sprintf("%s: %s → %s", c.Path, formatValue(c.Old), formatValue(c.New))
+	}
+}
+
+// formatValue renders a parameter value as compact JSON.
+func formatValue(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(data)
+}
@@ -18,6 +18,8 @@ func ComparePrompts(a *pack.Pack, b *pack.Pack) []DriftEntry {
 			Description: "System prompts differ",
 			PackA:       store.ShortHash(a.SystemPrompt, 12),
 			PackB:       store.ShortHash(b.SystemPrompt, 12),
+			refA:        a.SystemPrompt,
+			refB:        b.SystemPrompt,
 		})
 	}
 
@@ -35,6 +37,8 @@ func ComparePrompts(a *pack.Pack, b *pack.Pack) []DriftEntry {
 				StepIndex:   i,
 				PackA:       store.ShortHash(a.Prompts[i].ContentRef, 12),
 				PackB:       store.ShortHash(b.Prompts[i].ContentRef, 12),
+				refA:        a.Prompts[i].ContentRef,
+				refB:        b.Prompts[i].ContentRef,
 			})
 		}
 		if a.Prompts[i].Role != b.Prompts[i].Role {
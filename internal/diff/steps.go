@@ -8,74 +8,119 @@ import (
 	"github.com/contextsubstrate/ctx/internal/store"
 )
 
-// CompareSteps compares execution steps between two packs, aligned by index.
+// CompareSteps compares execution steps between two packs, aligned with
+// AlignSteps so that an inserted or removed step is reported once instead of
+// shifting every later step.
 func CompareSteps(a *pack.Pack, b *pack.Pack) []DriftEntry {
 	var entries []DriftEntry
 
-	minLen := len(a.Steps)
-	if len(b.Steps) < minLen {
-		minLen = len(b.Steps)
-	}
-
-	for i := 0; i < minLen; i++ {
-		sa := a.Steps[i]
-		sb := b.Steps[i]
-
-		// Tool drift
-		if sa.Tool != sb.Tool {
+	for k, pair := range AlignSteps(a.Steps, b.Steps) {
+		first := len(entries)
+		switch pair.Op {
+		case AlignDelete:
+			s := &a.Steps[pair.A]
 			entries = append(entries, DriftEntry{
 				Type:        ToolDrift,
-				Description: fmt.Sprintf("Step %d: different tool", i),
-				StepIndex:   i,
-				PackA:       sa.Tool,
-				PackB:       sb.Tool,
+				Description: fmt.Sprintf("%s: %s removed in pack B", stepLabel(pair.A, s), s.Tool),
+				StepIndex:   pair.A,
+				Alignment:   pair.Op,
+				PackA:       s.Tool,
 			})
-			continue // If tools differ, no point comparing params or output
-		}
 
-		// Param drift
-		if !reflect.DeepEqual(sa.Parameters, sb.Parameters) {
+		case AlignInsert:
+			s := &b.Steps[pair.B]
 			entries = append(entries, DriftEntry{
-				Type:        ParamDrift,
-				Description: fmt.Sprintf("Step %d: %s called with different parameters", i, sa.Tool),
-				StepIndex:   i,
-				PackA:       sa.Parameters,
-				PackB:       sb.Parameters,
+				Type:        ToolDrift,
+				Description: fmt.Sprintf("%s: %s added in pack B", stepLabel(pair.B, s), s.Tool),
+				StepIndex:   pair.B,
+				StepIndexB:  &pair.B,
+				Alignment:   pair.Op,
+				PackB:       s.Tool,
 			})
+
+		default:
+			entries = append(entries, compareStepPair(pair, &a.Steps[pair.A], &b.Steps[pair.B])...)
 		}
 
-		// Reasoning drift (output divergence at same step)
-		if sa.OutputRef != sb.OutputRef {
-			entries = append(entries, DriftEntry{
-				Type:        ReasoningDrift,
-				Description: fmt.Sprintf("Step %d: %s produced different output", i, sa.Tool),
-				StepIndex:   i,
-				PackA:       store.ShortHash(sa.OutputRef, 12),
-				PackB:       store.ShortHash(sb.OutputRef, 12),
-			})
+		for i := first; i < len(entries); i++ {
+			entries[i].seq = k + 1
 		}
 	}
 
-	// Handle mismatched step counts
-	if len(a.Steps) > len(b.Steps) {
-		for i := minLen; i < len(a.Steps); i++ {
-			entries = append(entries, DriftEntry{
-				Type:        ToolDrift,
-				Description: fmt.Sprintf("Step %d: %s removed in pack B", i, a.Steps[i].Tool),
-				StepIndex:   i,
-				PackA:       a.Steps[i].Tool,
-			})
-		}
-	} else if len(b.Steps) > len(a.Steps) {
-		for i := minLen; i < len(b.Steps); i++ {
-			entries = append(entries, DriftEntry{
-				Type:        ToolDrift,
-				Description: fmt.Sprintf("Step %d: %s added in pack B", i, b.Steps[i].Tool),
-				StepIndex:   i,
-				PackB:       b.Steps[i].Tool,
-			})
+	return entries
+}
+
+// compareStepPair reports how two aligned steps differ.
+func compareStepPair(pair StepPair, sa, sb *pack.Step) []DriftEntry {
+	var entries []DriftEntry
+	i := pair.A
+	label := stepLabel(i, sa)
+	if pair.A != pair.B {
+		label = fmt.Sprintf("%s (step %d in pack B)", label, pair.B)
+	}
+	entry := func(t DriftType, description string, packA, packB interface{}) DriftEntry {
+		return DriftEntry{
+			Type:        t,
+			Description: description,
+			StepIndex:   i,
+			StepIndexB:  &pair.B,
+			Alignment:   pair.Op,
+			PackA:       packA,
+			PackB:       packB,
 		}
 	}
 
+	// Moved step (identical call made at a different point in the run)
+	if pair.Op == AlignMove {
+		entries = append(entries, entry(ToolDrift,
+			fmt.Sprintf("%s: %s moved to step %d in pack B", stepLabel(i, sa), sa.Tool, pair.B), i, pair.B))
+	}
+
+	// Agent drift (a different agent performed this step)
+	if sa.Agent != sb.Agent {
+		entries = append(entries, entry(AgentDrift,
+			fmt.Sprintf("%s: performed by a different agent", label), sa.Agent, sb.Agent))
+	}
+
+	// Tool drift
+	if sa.Tool != sb.Tool {
+		entries = append(entries, entry(ToolDrift,
+			fmt.Sprintf("%s: different tool", label), sa.Tool, sb.Tool))
+		return entries // If tools differ, no point comparing params or output
+	}
+
+	// Param drift
+	if !reflect.DeepEqual(sa.Parameters, sb.Parameters) {
+		e := entry(ParamDrift,
+			fmt.Sprintf("%s: %s called with different parameters", label, sa.Tool), sa.Parameters, sb.Parameters)
+		e.Params = CompareParams(sa.Parameters, sb.Parameters)
+		entries = append(entries, e)
+	}
+
+	// Sub-run drift (the step delegated to a different child pack)
+	if sa.ChildPack != sb.ChildPack {
+		entries = append(entries, entry(ReasoningDrift,
+			fmt.Sprintf("%s: %s delegated to a different sub-run", label, sa.Tool),
+			store.ShortHash(sa.ChildPack, 12), store.ShortHash(sb.ChildPack, 12)))
+	}
+
+	// Reasoning drift (output divergence at the aligned step)
+	if sa.OutputRef != sb.OutputRef {
+		e := entry(ReasoningDrift,
+			fmt.Sprintf("%s: %s produced different output", label, sa.Tool),
+			store.ShortHash(sa.OutputRef, 12), store.ShortHash(sb.OutputRef, 12))
+		e.refA, e.refB = sa.OutputRef, sb.OutputRef
+		entries = append(entries, e)
+	}
+
 	return entries
 }
+
+// stepLabel names a step in drift descriptions, including the agent that
+// performed it in multi-agent packs.
+func stepLabel(i int, s *pack.Step) string {
+	if s.Agent == "" {
+		return fmt.Sprintf("Step %d", i)
+	}
+	return fmt.Sprintf("Step %d (%s)", i, s.Agent)
+}
@@ -4,24 +4,51 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+
+	"github.com/contextsubstrate/ctx/internal/cost"
 )
 
 type DriftType string
 
 const (
-	PromptDrift    DriftType = "prompt_drift"
-	ToolDrift      DriftType = "tool_drift"
-	ParamDrift     DriftType = "param_drift"
-	ReasoningDrift DriftType = "reasoning_drift"
-	OutputDrift    DriftType = "output_drift"
+	PromptDrift      DriftType = "prompt_drift"
+	ToolDrift        DriftType = "tool_drift"
+	ParamDrift       DriftType = "param_drift"
+	ReasoningDrift   DriftType = "reasoning_drift"
+	OutputDrift      DriftType = "output_drift"
+	AgentDrift       DriftType = "agent_drift"
+	ModelDrift       DriftType = "model_drift"
+	SamplingDrift    DriftType = "sampling_drift"
+	EnvironmentDrift DriftType = "environment_drift"
+	InputDrift       DriftType = "input_drift"
 )
 
 type DriftEntry struct {
 	Type        DriftType   `json:"type"`
 	Description string      `json:"description"`
 	StepIndex   int         `json:"step_index,omitempty"`
+	StepIndexB  *int        `json:"step_index_b,omitempty"` // Nil unless a step in pack B is involved
+	Alignment   AlignOp     `json:"alignment,omitempty"`
 	PackA       interface{} `json:"pack_a,omitempty"`
 	PackB       interface{} `json:"pack_b,omitempty"`
+
+	// Params lists the individual parameter changes for parameter drift.
+	Params []ParamChange `json:"params,omitempty"`
+
+	// Content shows how the underlying text changed for prompt, step output
+	// and final output drift.
+	Content *ContentDiff `json:"content,omitempty"`
+
+	// Downstream marks differences that likely follow from the report's
+	// likely cause rather than being independent changes.
+	Downstream bool `json:"downstream,omitempty"`
+
+	// refA and refB are the full blob refs behind Content.
+	refA, refB string
+
+	// seq is the 1-based position of the step pair in the alignment, or 0
+	// for differences that are not tied to a step.
+	seq int
 }
 
 type DriftReport struct {
@@ -29,6 +56,15 @@ type DriftReport struct {
 	PackHashB string       `json:"pack_hash_b"`
 	Entries   []DriftEntry `json:"entries"`
 	HasDrift  bool         `json:"has_drift"`
+
+	LikelyCause *LikelyCause `json:"likely_cause,omitempty"`
+	Cost        *cost.Delta  `json:"cost,omitempty"`
+}
+
+// ContentRefs returns the full blob refs compared by the entry's content
+// diff, or empty strings when the entry has none.
+func (e *DriftEntry) ContentRefs() (string, string) {
+	return e.refA, e.refB
 }
 
 // JSON returns the report as JSON bytes.
@@ -39,15 +75,34 @@ func (r *DriftReport) JSON() ([]byte, error) {
 // Human returns a human-readable summary of the drift report.
 func (r *DriftReport) Human() string {
 	if !r.HasDrift {
+		if r.Cost != nil && r.Cost.Changed() {
+			return "No differences found.\n\n" + r.Cost.Human()
+		}
 		return "No differences found.\n"
 	}
 
 	var b strings.Builder
 	b.WriteString(fmt.Sprintf("Comparing %s vs %s\n\n", r.PackHashA, r.PackHashB))
+	b.WriteString(r.causeHuman())
 	b.WriteString(fmt.Sprintf("%d difference(s) found:\n\n", len(r.Entries)))
 
 	for i, e := range r.Entries {
-		b.WriteString(fmt.Sprintf("  %d. [%s] %s\n", i+1, e.Type, e.Description))
+		downstream := ""
+		if e.Downstream {
+			downstream = " (downstream)"
+		}
+		b.WriteString(fmt.Sprintf("  %d. [%s] %s%s\n", i+1, e.Type, e.Description, downstream))
+		for _, c := range e.Params {
+			b.WriteString(fmt.Sprintf("       %s\n", c))
+		}
+		if e.Content != nil {
+			b.WriteString(e.Content.human("       "))
+		}
+	}
+
+	if r.Cost != nil {
+		b.WriteString("\n")
+		b.WriteString(r.Cost.Human())
 	}
 
 	return b.String()
@@ -0,0 +1,407 @@
+// Package fsck checks a store for integrity problems and repairs what it can.
+package fsck
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/contextsubstrate/ctx/internal/graph"
+	"github.com/contextsubstrate/ctx/internal/pack"
+	"github.com/contextsubstrate/ctx/internal/store"
+)
+
+// ProblemKind classifies an integrity problem.
+type ProblemKind string
+
+const (
+	CorruptBlob          ProblemKind = "corrupt_blob"
+	CorruptPackfile      ProblemKind = "corrupt_packfile"
+	StrayTempFile        ProblemKind = "stray_temp_file"
+	UnexpectedObject     ProblemKind = "unexpected_object"
+	UnreadableManifest   ProblemKind = "unreadable_manifest"
+	InvalidManifest      ProblemKind = "invalid_manifest"
+	NonCanonicalManifest ProblemKind = "non_canonical_manifest"
+	MissingBlob          ProblemKind = "missing_blob"
+	UnreadableGraph      ProblemKind = "unreadable_graph"
+	DanglingGraphRef     ProblemKind = "dangling_graph_ref"
+)
+
+// Problem is one integrity problem found in the store. Ref is the blob or
+// pack concerned, if any; Path is the file concerned, relative to the store.
+type Problem struct {
+	Kind   ProblemKind `json:"kind"`
+	Ref    string      `json:"ref,omitempty"`
+	Path   string      `json:"path,omitempty"`
+	Detail string      `json:"detail"`
+}
+
+// Report is the result of checking a store. EncryptedSkipped counts
+// encrypted objects that could not be verified because their key is not in
+// this store.
+type Report struct {
+	ObjectsChecked   int       `json:"objects_checked"`
+	PackfilesChecked int       `json:"packfiles_checked"`
+	PacksChecked     int       `json:"packs_checked"`
+	SnapshotsChecked int       `json:"snapshots_checked"`
+	EncryptedSkipped int       `json:"encrypted_skipped,omitempty"`
+	Problems         []Problem `json:"problems"`
+}
+
+// Check scans the whole store without modifying it: every object's content,
+// loose or in a packfile, or a chunked blob's reassembled content, must
+// match its name, every registered pack must load, validate, have its
+// canonical hash and reference only blobs that exist, and every graph
+// snapshot must reference only known path and symbol IDs.
+func Check(storeRoot string) (*Report, error) {
+	report := &Report{}
+	if err := report.checkObjects(storeRoot); err != nil {
+		return nil, err
+	}
+	if err := report.checkPackfiles(storeRoot); err != nil {
+		return nil, err
+	}
+	if err := report.checkPacks(storeRoot); err != nil {
+		return nil, err
+	}
+	if err := report.checkGraph(storeRoot); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func (r *Report) add(p Problem) {
+	r.Problems = append(r.Problems, p)
+}
+
+// checkObjects recomputes the hash of every file in objects/.
+func (r *Report) checkObjects(storeRoot string) error {
+	objects := filepath.Join(storeRoot, "objects")
+	err := filepath.WalkDir(objects, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == objects {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, _ := filepath.Rel(storeRoot, path)
+		if strings.HasSuffix(path, ".tmp") {
+			r.add(Problem{Kind: StrayTempFile, Path: rel, Detail: "left behind by an interrupted write"})
+			return nil
+		}
+
+		ref := "sha256:" + filepath.Base(filepath.Dir(path)) + d.Name()
+		if !store.ValidateHash(ref) {
+			r.add(Problem{Kind: UnexpectedObject, Path: rel, Detail: "name is not a blob hash"})
+			return nil
+		}
+		data, err := store.ReadObject(storeRoot, ref)
+		r.checkObject(storeRoot, ref, rel, data, err)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("scanning objects: %w", err)
+	}
+	return nil
+}
+
+// checkObject verifies one object's content, decrypted and decompressed,
+// against its hash.
+func (r *Report) checkObject(storeRoot, ref, rel string, data []byte, err error) {
+	r.ObjectsChecked++
+	if errors.Is(err, store.ErrKeyUnavailable) {
+		r.EncryptedSkipped++
+		return
+	}
+	if err != nil {
+		r.add(Problem{Kind: CorruptBlob, Ref: ref, Path: rel, Detail: err.Error()})
+		return
+	}
+	actual := store.HashContent(data)
+	if actual == ref {
+		return
+	}
+	if list, ok := store.ParseChunkList(data); ok {
+		r.checkChunks(storeRoot, ref, rel, list)
+		return
+	}
+	r.add(Problem{Kind: CorruptBlob, Ref: ref, Path: rel, Detail: fmt.Sprintf("content hashes to %s", store.ShortHash(actual, 12))})
+}
+
+// checkPackfiles verifies every packfile and every object in it, and looks
+// for files left behind by an interrupted repack.
+func (r *Report) checkPackfiles(storeRoot string) error {
+	dir := filepath.Join(storeRoot, store.PackfileDir)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("reading packfiles: %w", err)
+	}
+	ids, err := store.ListPackfiles(storeRoot)
+	if err != nil {
+		return err
+	}
+	indexed := make(map[string]bool)
+	for _, id := range ids {
+		indexed[id+".pack"] = true
+		indexed[id+".idx"] = true
+	}
+	for _, e := range entries {
+		if !indexed[e.Name()] {
+			r.add(Problem{Kind: StrayTempFile, Path: filepath.Join(store.PackfileDir, e.Name()), Detail: "left behind by an interrupted repack"})
+		}
+	}
+
+	for _, id := range ids {
+		r.PackfilesChecked++
+		rel := filepath.Join(store.PackfileDir, id+".pack")
+		if err := store.VerifyPackfile(storeRoot, id); err != nil {
+			r.add(Problem{Kind: CorruptPackfile, Path: rel, Detail: err.Error()})
+		}
+		err := store.WalkPackfile(storeRoot, id, func(ref string, data []byte, err error) error {
+			if isLoose(storeRoot, ref) {
+				return nil // Shadowed: reads use the loose copy, checked with objects/
+			}
+			r.checkObject(storeRoot, ref, rel, data, err)
+			return nil
+		})
+		if err != nil {
+			r.add(Problem{Kind: CorruptPackfile, Path: rel, Detail: err.Error()})
+		}
+	}
+	return nil
+}
+
+// checkChunks verifies that a chunked blob's chunks exist and reassemble to
+// its hash. Corrupt chunks are also reported on their own.
+func (r *Report) checkChunks(storeRoot, ref, rel string, list *store.ChunkList) {
+	missing := false
+	seen := make(map[string]bool)
+	for _, c := range list.Chunks {
+		if seen[c.Ref] {
+			continue // Repeated content repeats chunks
+		}
+		seen[c.Ref] = true
+		if !store.BlobExists(storeRoot, c.Ref) {
+			r.add(Problem{Kind: MissingBlob, Ref: c.Ref, Detail: fmt.Sprintf("chunk of %s", store.ShortHash(ref, 12))})
+			missing = true
+		}
+	}
+	if missing {
+		return
+	}
+	if _, err := store.ReadBlob(storeRoot, ref); err != nil && !errors.Is(err, store.ErrKeyUnavailable) {
+		r.add(Problem{Kind: CorruptBlob, Ref: ref, Path: rel, Detail: err.Error()})
+	}
+}
+
+// checkPacks loads and validates every registered pack.
+func (r *Report) checkPacks(storeRoot string) error {
+	hashes, err := pack.RegisteredPacks(storeRoot)
+	if err != nil {
+		return err
+	}
+	for _, h := range hashes {
+		r.PacksChecked++
+		if !store.BlobExists(storeRoot, h) {
+			r.add(Problem{Kind: MissingBlob, Ref: h, Detail: "manifest of a registered pack"})
+			continue
+		}
+		p, err := pack.LoadPack(storeRoot, h)
+		if err != nil {
+			r.add(Problem{Kind: UnreadableManifest, Ref: h, Detail: err.Error()})
+			continue
+		}
+		if err := p.Validate(); err != nil {
+			r.add(Problem{Kind: InvalidManifest, Ref: h, Detail: err.Error()})
+		}
+		canonical, err := pack.CanonicalHash(p)
+		if err != nil {
+			r.add(Problem{Kind: UnreadableManifest, Ref: h, Detail: err.Error()})
+		} else if canonical != p.Hash {
+			r.add(Problem{Kind: NonCanonicalManifest, Ref: h, Detail: fmt.Sprintf("canonical hash is %s (run ctx fsck --rehash)", canonical)})
+		}
+		for _, ref := range p.BlobRefs() {
+			switch {
+			case !store.ValidateHash(ref.Ref):
+				r.add(Problem{Kind: InvalidManifest, Ref: h, Detail: fmt.Sprintf("%s is not a blob hash: %q", ref.Field, ref.Ref)})
+			case !store.BlobExists(storeRoot, ref.Ref):
+				r.add(Problem{Kind: MissingBlob, Ref: ref.Ref, Detail: fmt.Sprintf("%s of pack %s", ref.Field, store.ShortHash(h, 12))})
+			}
+		}
+	}
+	return nil
+}
+
+// checkGraph verifies that every snapshot refers only to known paths, and
+// that call edges refer only to symbols defined in the same snapshot.
+func (r *Report) checkGraph(storeRoot string) error {
+	snapshots := filepath.Join(storeRoot, graph.GraphDir, "snapshots")
+	entries, err := os.ReadDir(snapshots)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("reading snapshots: %w", err)
+	}
+
+	paths, err := graph.ReadRecords[graph.PathRecord](graph.PathsPath(storeRoot))
+	if err != nil {
+		r.add(Problem{Kind: UnreadableGraph, Path: rel(storeRoot, graph.PathsPath(storeRoot)), Detail: err.Error()})
+		return nil
+	}
+	knownPaths := make(map[string]bool)
+	for _, p := range paths {
+		knownPaths[p.PathID] = true
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		sha := entry.Name()
+		r.SnapshotsChecked++
+		c := snapshotChecker{report: r, root: storeRoot, knownPaths: knownPaths}
+
+		files, ok := readSnapshot[graph.FileSnapshot](&c, graph.FilesPath(storeRoot, sha))
+		if ok {
+			for _, f := range files {
+				c.path(graph.FilesPath(storeRoot, sha), f.PathID)
+			}
+		}
+		symbols, ok := readSnapshot[graph.SymbolRecord](&c, graph.SymbolsPath(storeRoot, sha))
+		knownSymbols := make(map[string]bool)
+		if ok {
+			for _, s := range symbols {
+				c.path(graph.SymbolsPath(storeRoot, sha), s.PathID)
+				knownSymbols[s.SymbolID] = true
+			}
+		}
+		regions, ok := readSnapshot[graph.RegionRecord](&c, graph.RegionsPath(storeRoot, sha))
+		if ok {
+			for _, reg := range regions {
+				c.path(graph.RegionsPath(storeRoot, sha), reg.PathID)
+			}
+		}
+		imports, ok := readSnapshot[graph.ImportEdge](&c, graph.ImportEdgesPath(storeRoot, sha))
+		if ok {
+			for _, e := range imports {
+				c.path(graph.ImportEdgesPath(storeRoot, sha), e.FromPathID)
+				if e.ToPathID != "" {
+					c.path(graph.ImportEdgesPath(storeRoot, sha), e.ToPathID)
+				}
+			}
+		}
+		calls, ok := readSnapshot[graph.CallEdge](&c, graph.CallEdgesPath(storeRoot, sha))
+		if ok && symbols != nil {
+			for _, e := range calls {
+				for _, id := range []string{e.FromSymbolID, e.ToSymbolID} {
+					if id != "" && !knownSymbols[id] {
+						c.dangling(graph.CallEdgesPath(storeRoot, sha), "symbol", id)
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// snapshotChecker reports dangling references in one snapshot, once per ID.
+type snapshotChecker struct {
+	report     *Report
+	root       string
+	knownPaths map[string]bool
+	reported   map[string]bool
+}
+
+func (c *snapshotChecker) path(file, id string) {
+	if !c.knownPaths[id] {
+		c.dangling(file, "path", id)
+	}
+}
+
+func (c *snapshotChecker) dangling(file, kind, id string) {
+	key := file + "\x00" + id
+	if c.reported[key] {
+		return
+	}
+	if c.reported == nil {
+		c.reported = make(map[string]bool)
+	}
+	c.reported[key] = true
+	c.report.add(Problem{Kind: DanglingGraphRef, Path: rel(c.root, file), Detail: fmt.Sprintf("unknown %s ID %s", kind, id)})
+}
+
+func readSnapshot[T any](c *snapshotChecker, path string) ([]T, bool) {
+	records, err := graph.ReadRecords[T](path)
+	if err != nil {
+		c.report.add(Problem{Kind: UnreadableGraph, Path: rel(c.root, path), Detail: err.Error()})
+		return nil, false
+	}
+	return records, true
+}
+
+// isLoose reports whether ref is stored as a loose object.
+func isLoose(storeRoot, ref string) bool {
+	_, hexStr, err := store.ParseHash(ref)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(filepath.Join(storeRoot, "objects", hexStr[:2], hexStr[2:]))
+	return err == nil
+}
+
+func rel(root, path string) string {
+	if r, err := filepath.Rel(root, path); err == nil {
+		return r
+	}
+	return path
+}
+
+// OK reports whether no problems were found.
+func (r *Report) OK() bool {
+	return len(r.Problems) == 0
+}
+
+// JSON returns the report as JSON bytes.
+func (r *Report) JSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
+
+// Human returns a human-readable summary of the check.
+func (r *Report) Human() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Checked %d object(s), %d pack(s), %d graph snapshot(s)", r.ObjectsChecked, r.PacksChecked, r.SnapshotsChecked)
+	if r.PackfilesChecked > 0 {
+		fmt.Fprintf(&b, ", %d packfile(s)", r.PackfilesChecked)
+	}
+	b.WriteString("\n")
+	if r.EncryptedSkipped > 0 {
+		fmt.Fprintf(&b, "Skipped %d encrypted object(s) whose key is not available.\n", r.EncryptedSkipped)
+	}
+	if r.OK() {
+		b.WriteString("No problems found.\n")
+		return b.String()
+	}
+
+	problems := append([]Problem(nil), r.Problems...)
+	sort.SliceStable(problems, func(i, j int) bool { return problems[i].Kind < problems[j].Kind })
+	fmt.Fprintf(&b, "%d problem(s):\n", len(problems))
+	for _, p := range problems {
+		subject := p.Path
+		if p.Ref != "" {
+			subject = store.ShortHash(p.Ref, 12)
+		}
+		fmt.Fprintf(&b, "  %-24s %s  %s\n", p.Kind, subject, p.Detail)
+	}
+	return b.String()
+}
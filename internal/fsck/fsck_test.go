@@ -0,0 +1,348 @@
+package fsck
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/contextsubstrate/ctx/internal/graph"
+	"github.com/contextsubstrate/ctx/internal/pack"
+	"github.com/contextsubstrate/ctx/internal/store"
+)
+
+func setupTestStore(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	root := filepath.Join(dir, ".ctx")
+	os.MkdirAll(filepath.Join(root, "objects"), 0755)
+	os.MkdirAll(filepath.Join(root, "packs"), 0755)
+	os.MkdirAll(filepath.Join(root, "refs"), 0755)
+	return root
+}
+
+func createPack(t *testing.T, root string) *pack.Pack {
+	t.Helper()
+	log := &pack.ExecutionLog{
+		Model:        pack.LogModel{Identifier: "test-model"},
+		SystemPrompt: "You are helpful.",
+		Steps: []pack.LogStep{
+			{Index: 0, Type: "tool_call", Tool: "search", Parameters: map[string]interface{}{"q": "a<b"}, Output: "out"},
+		},
+		Environment: pack.LogEnvironment{OS: "linux", Runtime: "go1.22", ToolVersions: map[string]string{}},
+	}
+	p, err := pack.CreatePack(root, log)
+	if err != nil {
+		t.Fatalf("CreatePack failed: %v", err)
+	}
+	if err := pack.RegisterPack(root, p.Hash); err != nil {
+		t.Fatalf("RegisterPack failed: %v", err)
+	}
+	return p
+}
+
+func TestCheckClean(t *testing.T) {
+	root := setupTestStore(t)
+	createPack(t, root)
+
+	report, err := Check(root)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if !report.OK() || report.PacksChecked != 1 {
+		t.Errorf("expected one clean pack, got %+v", report)
+	}
+	if !strings.Contains(report.Human(), "No problems found.") {
+		t.Errorf("unexpected summary:\n%s", report.Human())
+	}
+}
+
+func TestCheckStepWithoutOutput(t *testing.T) {
+	root := setupTestStore(t)
+	log := &pack.ExecutionLog{
+		Model:        pack.LogModel{Identifier: "test-model"},
+		SystemPrompt: "You are helpful.",
+		Steps: []pack.LogStep{
+			{Index: 0, Type: "tool_call", Tool: "touch", Parameters: map[string]interface{}{}, Output: ""},
+		},
+		Environment: pack.LogEnvironment{OS: "linux", Runtime: "go1.22", ToolVersions: map[string]string{}},
+	}
+	p, err := pack.CreatePack(root, log)
+	if err != nil {
+		t.Fatalf("CreatePack failed: %v", err)
+	}
+	if err := pack.RegisterPack(root, p.Hash); err != nil {
+		t.Fatalf("RegisterPack failed: %v", err)
+	}
+
+	report, err := Check(root)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if !report.OK() {
+		t.Errorf("expected a step without output to be clean, got %+v", report.Problems)
+	}
+}
+
+func TestCheckManifestHashes(t *testing.T) {
+	root := setupTestStore(t)
+	p := createPack(t, root)
+
+	// Stored the old way: struct order and HTML escaping
+	legacy := *p
+	legacy.Hash = ""
+	legacy.Outputs = nil
+	data, _ := json.Marshal(&legacy)
+	legacyHash, _ := store.WriteBlob(root, data)
+	pack.RegisterPack(root, legacyHash)
+
+	// Registered, but the manifest blob is gone
+	missing := "sha256:" + strings.Repeat("ab", 32)
+	pack.RegisterPack(root, missing)
+
+	report, err := Check(root)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	kinds := map[string]ProblemKind{}
+	for _, prob := range report.Problems {
+		kinds[prob.Ref] = prob.Kind
+	}
+	if kinds[legacyHash] != NonCanonicalManifest {
+		t.Errorf("expected the legacy pack to be flagged non-canonical, got %+v", report.Problems)
+	}
+	if kinds[missing] != MissingBlob {
+		t.Errorf("expected the missing manifest to be flagged, got %+v", report.Problems)
+	}
+	if len(report.Problems) != 2 {
+		t.Errorf("expected 2 problems, got %d", len(report.Problems))
+	}
+}
+
+func blobFile(root, ref string) string {
+	hex := store.ShortHash(ref, 64)
+	return filepath.Join(root, "objects", hex[:2], hex[2:])
+}
+
+func TestCheckObjectsAndQuarantine(t *testing.T) {
+	root := setupTestStore(t)
+	p := createPack(t, root)
+	remote := setupTestStore(t)
+	createPack(t, remote) // Same blobs, different manifest
+
+	// Corrupt the step output and leave a temp file behind
+	path := blobFile(root, p.Steps[0].OutputRef)
+	os.Chmod(path, 0644)
+	os.WriteFile(path, []byte("tampered"), 0644)
+	os.WriteFile(path+".tmp", []byte("partial"), 0644)
+
+	report, err := Check(root)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	kinds := map[ProblemKind]int{}
+	for _, prob := range report.Problems {
+		kinds[prob.Kind]++
+	}
+	if kinds[CorruptBlob] != 1 || kinds[StrayTempFile] != 1 || len(report.Problems) != 2 {
+		t.Fatalf("expected one corrupt blob and one temp file, got %+v", report.Problems)
+	}
+
+	actions, err := Repair(root, report, Options{Quarantine: true, FetchFrom: remote})
+	if err != nil {
+		t.Fatalf("Repair failed: %v", err)
+	}
+	if len(actions) != 3 {
+		t.Errorf("expected two quarantines and one fetch, got %v", actions)
+	}
+	if _, err := os.Stat(filepath.Join(root, QuarantineDir, "objects")); err != nil {
+		t.Error("expected damaged files under the quarantine directory")
+	}
+
+	report, _ = Check(root)
+	if !report.OK() {
+		t.Errorf("expected a clean store after repair, got %+v", report.Problems)
+	}
+	if data, _ := store.ReadBlob(root, p.Steps[0].OutputRef); string(data) != "out" {
+		t.Errorf("expected the fetched blob to be restored, got %q", data)
+	}
+}
+
+func TestCheckMissingBlobWithoutFetch(t *testing.T) {
+	root := setupTestStore(t)
+	p := createPack(t, root)
+	os.Remove(blobFile(root, p.SystemPrompt))
+
+	report, _ := Check(root)
+	if len(report.Problems) != 1 || report.Problems[0].Kind != MissingBlob || !strings.Contains(report.Problems[0].Detail, "system_prompt") {
+		t.Fatalf("expected the missing system prompt to be reported, got %+v", report.Problems)
+	}
+
+	actions, err := Repair(root, report, Options{Quarantine: true})
+	if err != nil || len(actions) != 0 {
+		t.Errorf("expected nothing to repair without a fetch source, got %v (%v)", actions, err)
+	}
+}
+
+func TestCheckGraphReferences(t *testing.T) {
+	root := setupTestStore(t)
+	sha := strings.Repeat("ab", 20)
+	graph.AppendRecord(graph.PathsPath(root), graph.PathRecord{PathID: "p1", Path: "main.go"})
+	graph.AppendRecord(graph.FilesPath(root, sha), graph.FileSnapshot{PathID: "p1"})
+	graph.AppendRecord(graph.FilesPath(root, sha), graph.FileSnapshot{PathID: "p2"})
+	graph.AppendRecord(graph.SymbolsPath(root, sha), graph.SymbolRecord{SymbolID: "s1", PathID: "p1"})
+	graph.AppendRecord(graph.CallEdgesPath(root, sha), graph.CallEdge{FromSymbolID: "s1", ToSymbolID: "s9"})
+	os.WriteFile(graph.ImportEdgesPath(root, sha), []byte("{not json\n"), 0644)
+
+	report, err := Check(root)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if report.SnapshotsChecked != 1 {
+		t.Errorf("expected 1 snapshot checked, got %d", report.SnapshotsChecked)
+	}
+	var details []string
+	for _, prob := range report.Problems {
+		details = append(details, string(prob.Kind)+": "+prob.Detail)
+	}
+	joined := strings.Join(details, "\n")
+	for _, want := range []string{"unknown path ID p2", "unknown symbol ID s9", "unreadable_graph"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("expected %q in problems:\n%s", want, joined)
+		}
+	}
+	if len(report.Problems) != 3 {
+		t.Errorf("expected 3 problems, got %d", len(report.Problems))
+	}
+}
+
+func TestCheckChunkedBlob(t *testing.T) {
+	root := setupTestStore(t)
+	data := []byte(strings.Repeat("0123456789abcdef", store.ChunkThreshold/8))
+	ref, _ := store.WriteBlob(root, data)
+
+	report, _ := Check(root)
+	if !report.OK() {
+		t.Fatalf("expected a chunked blob to check clean, got %+v", report.Problems)
+	}
+
+	raw, _ := os.ReadFile(blobFile(root, ref))
+	list, ok := store.ParseChunkList(raw)
+	if !ok {
+		t.Fatal("expected a chunk list")
+	}
+	os.Remove(blobFile(root, list.Chunks[0].Ref))
+
+	report, _ = Check(root)
+	if len(report.Problems) != 1 || report.Problems[0].Kind != MissingBlob || report.Problems[0].Ref != list.Chunks[0].Ref {
+		t.Errorf("expected the missing chunk to be reported, got %+v", report.Problems)
+	}
+}
+
+func TestCheckCompressedObjects(t *testing.T) {
+	root := setupTestStore(t)
+	createPack(t, root)
+	ref, _ := store.WriteBlob(root, []byte(strings.Repeat("compressible ", 100)))
+	if _, err := store.CompressObjects(root, store.CompressionZstd); err != nil {
+		t.Fatalf("CompressObjects failed: %v", err)
+	}
+
+	report, _ := Check(root)
+	if !report.OK() {
+		t.Fatalf("expected a compressed store to check clean, got %+v", report.Problems)
+	}
+
+	path := blobFile(root, ref)
+	raw, _ := os.ReadFile(path)
+	os.Chmod(path, 0644)
+	os.WriteFile(path, raw[:len(raw)-4], 0644)
+
+	report, _ = Check(root)
+	if len(report.Problems) != 1 || report.Problems[0].Kind != CorruptBlob || report.Problems[0].Ref != ref {
+		t.Errorf("expected the truncated object reported corrupt, got %+v", report.Problems)
+	}
+}
+
+func TestCheckPackfiles(t *testing.T) {
+	root := setupTestStore(t)
+	createPack(t, root)
+	remote := setupTestStore(t)
+	payload := []byte("a distinctive payload")
+	ref, _ := store.WriteBlob(root, payload)
+	store.WriteBlob(remote, payload)
+	stats, err := store.PackObjects(root, false)
+	if err != nil {
+		t.Fatalf("PackObjects failed: %v", err)
+	}
+
+	report, _ := Check(root)
+	if !report.OK() || report.PackfilesChecked != 1 || report.ObjectsChecked != stats.Objects {
+		t.Fatalf("expected a clean packed store, got %+v", report)
+	}
+
+	packPath := filepath.Join(root, store.PackfileDir, stats.Packfile+".pack")
+	data, _ := os.ReadFile(packPath)
+	data[bytes.Index(data, payload)] = 'A'
+	os.Chmod(packPath, 0644)
+	os.WriteFile(packPath, data, 0644)
+	os.WriteFile(filepath.Join(root, store.PackfileDir, "pack-1.tmp"), []byte("partial"), 0644)
+
+	report, _ = Check(root)
+	kinds := map[ProblemKind]int{}
+	for _, prob := range report.Problems {
+		kinds[prob.Kind]++
+	}
+	if kinds[CorruptBlob] != 1 || kinds[CorruptPackfile] != 1 || kinds[StrayTempFile] != 1 {
+		t.Fatalf("expected a corrupt packed blob, a corrupt packfile and a temp file, got %+v", report.Problems)
+	}
+
+	if _, err := Repair(root, report, Options{Quarantine: true, FetchFrom: remote}); err != nil {
+		t.Fatalf("Repair failed: %v", err)
+	}
+	if _, err := os.Stat(packPath); err != nil {
+		t.Fatal("expected the packfile left in place")
+	}
+	if got, _ := store.ReadBlob(root, ref); !bytes.Equal(got, payload) {
+		t.Errorf("expected the restored copy to be read, got %q", got)
+	}
+
+	// Merging drops the damaged copy in favor of the restored one
+	if _, err := store.PackObjects(root, true); err != nil {
+		t.Fatalf("PackObjects failed: %v", err)
+	}
+	report, _ = Check(root)
+	if !report.OK() {
+		t.Errorf("expected a clean store after repack, got %+v", report.Problems)
+	}
+}
+
+func TestCheckEncryptedObjects(t *testing.T) {
+	root := setupTestStore(t)
+	key, _ := store.GenerateKey(root)
+	ref, _ := store.WriteBlobWithKey(root, []byte("regulated content"), key)
+
+	report, err := Check(root)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if !report.OK() || report.EncryptedSkipped != 0 {
+		t.Errorf("expected encrypted objects verified with the key, got %+v", report)
+	}
+
+	os.RemoveAll(filepath.Join(root, store.KeyDir))
+	report, err = Check(root)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if !report.OK() || report.EncryptedSkipped != 1 {
+		t.Errorf("expected the object skipped without its key, got %+v", report)
+	}
+	if !strings.Contains(report.Human(), "Skipped 1 encrypted object(s)") {
+		t.Errorf("expected the skip in the summary:\n%s", report.Human())
+	}
+	if !store.BlobExists(root, ref) {
+		t.Error("expected the object left in place")
+	}
+}
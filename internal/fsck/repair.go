@@ -0,0 +1,122 @@
+package fsck
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/contextsubstrate/ctx/internal/store"
+)
+
+// QuarantineDir is where repairs move damaged files, relative to the store.
+const QuarantineDir = "quarantine"
+
+// Options selects the repairs Repair makes.
+type Options struct {
+	// Quarantine moves corrupt blobs and stray temp files out of objects/.
+	Quarantine bool
+	// FetchFrom is another store's directory to copy missing blobs from.
+	FetchFrom string
+}
+
+// Repair fixes the problems in a report that opts allow and returns a line
+// describing each action taken. Corrupt blobs are quarantined before
+// fetching, so a good copy can replace them. A corrupt object inside a
+// packfile is not moved; a fetched loose copy shadows it instead.
+func Repair(storeRoot string, report *Report, opts Options) ([]string, error) {
+	var actions []string
+
+	missing := make(map[string]bool)
+	damagedPacked := make(map[string]bool)
+	for _, p := range report.Problems {
+		switch p.Kind {
+		case CorruptBlob, StrayTempFile:
+			if p.Kind == CorruptBlob && isPackfile(p.Path) {
+				damagedPacked[p.Ref] = true // Shadowed by a fetched loose copy
+				continue
+			}
+			if !opts.Quarantine {
+				continue
+			}
+			dest, err := quarantine(storeRoot, p.Path)
+			if err != nil {
+				return actions, err
+			}
+			actions = append(actions, fmt.Sprintf("quarantined %s to %s", p.Path, dest))
+			if p.Kind == CorruptBlob {
+				missing[p.Ref] = true
+			}
+		case MissingBlob:
+			missing[p.Ref] = true
+		}
+	}
+
+	if opts.FetchFrom == "" {
+		return actions, nil
+	}
+	for ref := range missing {
+		if store.BlobExists(storeRoot, ref) {
+			continue // Corrupt, but not quarantined
+		}
+		data, key, err := fetchBlob(storeRoot, opts.FetchFrom, ref)
+		if err != nil {
+			actions = append(actions, fmt.Sprintf("could not fetch %s: %v", store.ShortHash(ref, 12), err))
+			continue
+		}
+		if _, err := store.WriteBlobWithKey(storeRoot, data, key); err != nil {
+			return actions, err
+		}
+		actions = append(actions, fmt.Sprintf("fetched %s from %s", store.ShortHash(ref, 12), opts.FetchFrom))
+	}
+	for ref := range damagedPacked {
+		data, key, err := fetchBlob(storeRoot, opts.FetchFrom, ref)
+		if err != nil {
+			actions = append(actions, fmt.Sprintf("could not fetch %s: %v", store.ShortHash(ref, 12), err))
+			continue
+		}
+		if _, err := store.RestoreBlob(storeRoot, data, key); err != nil {
+			return actions, err
+		}
+		actions = append(actions, fmt.Sprintf("restored %s from %s over its damaged packed copy", store.ShortHash(ref, 12), opts.FetchFrom))
+	}
+	return actions, nil
+}
+
+// fetchBlob reads a blob from another store along with the key to store it
+// under. A blob the other store holds encrypted stays encrypted here, so
+// fetching it needs its key in both stores.
+func fetchBlob(storeRoot, from, ref string) ([]byte, *store.Key, error) {
+	data, err := store.ReadBlob(from, ref)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyID, err := store.ObjectKeyID(from, ref)
+	if err != nil || keyID == "" {
+		return data, nil, err
+	}
+	key, err := store.LoadKey(storeRoot, keyID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, key, nil
+}
+
+// isPackfile reports whether a problem path, relative to the store, is a
+// packfile, which repairs never move.
+func isPackfile(relPath string) bool {
+	return strings.HasPrefix(relPath, store.PackfileDir+string(filepath.Separator))
+}
+
+// quarantine moves a file, given relative to the store, under the quarantine
+// directory, keeping its relative path.
+func quarantine(storeRoot, relPath string) (string, error) {
+	dest := filepath.Join(QuarantineDir, relPath)
+	if err := os.MkdirAll(filepath.Join(storeRoot, filepath.Dir(dest)), 0755); err != nil {
+		return "", fmt.Errorf("creating quarantine directory: %w", err)
+	}
+	if err := os.Rename(filepath.Join(storeRoot, relPath), filepath.Join(storeRoot, dest)); err != nil {
+		return "", fmt.Errorf("quarantining %s: %w", relPath, err)
+	}
+	return dest, nil
+}
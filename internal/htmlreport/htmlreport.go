@@ -0,0 +1,246 @@
+// Package htmlreport renders packs, drift reports and replay reports as
+// single self-contained HTML documents, built offline from the store.
+package htmlreport
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"strings"
+
+	"github.com/contextsubstrate/ctx/internal/pack"
+	"github.com/contextsubstrate/ctx/internal/store"
+	"github.com/contextsubstrate/ctx/internal/textdiff"
+)
+
+// maxBlobBytes caps how much of a blob is embedded in a report.
+const maxBlobBytes = 64 * 1024
+
+// maxImageBytes caps the size of an image embedded in a report.
+const maxImageBytes = 1 << 20
+
+const style = `
+body { font: 14px/1.5 -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em auto; max-width: 1100px; color: #1f2328; padding: 0 1em; }
+h1 { font-size: 1.5em; } h2 { font-size: 1.2em; margin-top: 1.8em; border-bottom: 1px solid #d0d7de; }
+code, pre, .mono { font: 12px/1.45 ui-monospace, SFMono-Regular, Menlo, Consolas, monospace; }
+pre { background: #f6f8fa; padding: .8em; overflow-x: auto; border-radius: 6px; margin: .4em 0; white-space: pre-wrap; }
+img { max-width: 100%; margin: .4em 0; border: 1px solid #d0d7de; border-radius: 6px; }
+table { border-collapse: collapse; } td, th { padding: .2em .8em .2em 0; text-align: left; vertical-align: top; }
+details { border: 1px solid #d0d7de; border-radius: 6px; margin: .4em 0; padding: .3em .8em; }
+details[open] { padding-bottom: .8em; }
+summary { cursor: pointer; }
+.muted { color: #656d76; }
+.badge { display: inline-block; padding: 0 .5em; border-radius: 1em; font-size: 12px; background: #eaeef2; }
+.ok { background: #dafbe1; } .warn { background: #fff8c5; } .bad { background: #ffebe9; }
+.cause { border-left: 4px solid #cf222e; padding-left: .8em; }
+.downstream { opacity: .7; }
+.j-key { color: #0550ae; } .j-str { color: #0a3069; } .j-num { color: #953800; } .j-lit { color: #8250df; }
+table.sbs { width: 100%; table-layout: fixed; } table.sbs td { padding: 0 .4em; white-space: pre-wrap; word-break: break-all; }
+table.sbs td.n { width: 3em; text-align: right; color: #656d76; user-select: none; }
+td.del { background: #ffebe9; } td.ins { background: #dafbe1; }
+nav { margin-bottom: 1.5em; padding-bottom: .5em; border-bottom: 1px solid #d0d7de; } nav a { margin-right: 1em; }
+a { color: #0969da; text-decoration: none; } a:hover { text-decoration: underline; }
+`
+
+// page wraps body content in a complete HTML document.
+var page = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>{{.Style}}</style>
+</head>
+<body>
+{{with .Nav}}<nav>{{.}}</nav>{{end}}
+{{.Body}}
+<p class="muted">Generated by ctx.</p>
+</body>
+</html>
+`))
+
+// funcs are the helpers available to every report template.
+var funcs = template.FuncMap{
+	"short": func(hash string) string { return store.ShortHash(hash, 12) },
+	"json":  highlightJSON,
+}
+
+// Document wraps a report body in a complete, self-contained HTML page. Nav,
+// if not empty, is rendered above the body.
+func Document(title string, nav, body template.HTML) ([]byte, error) {
+	var out bytes.Buffer
+	err := page.Execute(&out, struct {
+		Title string
+		Style template.CSS
+		Nav   template.HTML
+		Body  template.HTML
+	}{title, template.CSS(style), nav, body})
+	if err != nil {
+		return nil, fmt.Errorf("rendering %s: %w", title, err)
+	}
+	return out.Bytes(), nil
+}
+
+// renderBody executes a body template into an HTML fragment.
+func renderBody(name string, body *template.Template, data interface{}) (template.HTML, error) {
+	var content bytes.Buffer
+	if err := body.Execute(&content, data); err != nil {
+		return "", fmt.Errorf("rendering %s: %w", name, err)
+	}
+	return template.HTML(content.String()), nil
+}
+
+// blobText loads a blob for display, replacing binary content with a size
+// note and truncating large text.
+func blobText(storeRoot, ref string) string {
+	text, _ := blobContent(storeRoot, ref, "")
+	return text
+}
+
+// blobContent loads a blob of the given media type for display. Images of
+// the embeddable types, up to maxImageBytes, are returned as a data URL
+// alongside the size note.
+func blobContent(storeRoot, ref, mediaType string) (string, template.URL) {
+	if ref == "" {
+		return "", ""
+	}
+	data, err := store.ReadBlob(storeRoot, ref)
+	if err != nil {
+		return fmt.Sprintf("(unavailable: %v)", err), ""
+	}
+	if pack.IsBinaryContent(data, mediaType) {
+		if mediaType == "" {
+			return fmt.Sprintf("(binary content, %d bytes)", len(data)), ""
+		}
+		note := fmt.Sprintf("(binary content, %s, %d bytes)", mediaType, len(data))
+		if embeddableImages[mediaType] && len(data) <= maxImageBytes {
+			return note, template.URL("data:" + mediaType + ";base64," + base64.StdEncoding.EncodeToString(data))
+		}
+		return note, ""
+	}
+	if len(data) > maxBlobBytes {
+		return string(data[:maxBlobBytes]) + fmt.Sprintf("\n… (%d more bytes)", len(data)-maxBlobBytes), ""
+	}
+	return string(data), ""
+}
+
+// embeddableImages are the image types shown inline. SVG is excluded since
+// it can carry script.
+var embeddableImages = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// highlightJSON renders a value as indented JSON with keys, strings, numbers
+// and literals wrapped in classed spans.
+func highlightJSON(v interface{}) template.HTML {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return template.HTML(template.HTMLEscapeString(fmt.Sprintf("%v", v)))
+	}
+
+	var b strings.Builder
+	s := string(data)
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '"':
+			j := i + 1
+			for j < len(s) && s[j] != '"' {
+				if s[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			j++
+			class := "j-str"
+			if strings.HasPrefix(s[j:], ":") {
+				class = "j-key"
+			}
+			span(&b, class, s[i:j])
+			i = j
+		case c == '-' || (c >= '0' && c <= '9'):
+			j := i + 1
+			for j < len(s) && strings.IndexByte("0123456789.eE+-", s[j]) >= 0 {
+				j++
+			}
+			span(&b, "j-num", s[i:j])
+			i = j
+		case strings.HasPrefix(s[i:], "true"), strings.HasPrefix(s[i:], "null"):
+			span(&b, "j-lit", s[i:i+4])
+			i += 4
+		case strings.HasPrefix(s[i:], "false"):
+			span(&b, "j-lit", s[i:i+5])
+			i += 5
+		default:
+			b.WriteString(template.HTMLEscapeString(string(c)))
+			i++
+		}
+	}
+	return template.HTML(b.String())
+}
+
+func span(b *strings.Builder, class, text string) {
+	b.WriteString(`<span class="` + class + `">` + template.HTMLEscapeString(text) + `</span>`)
+}
+
+// diffRow is one row of a side-by-side diff. A zero line number leaves that
+// side of the row empty.
+type diffRow struct {
+	Left, Right     string
+	LeftNo, RightNo int
+	Kind            string // "", "change", "del" or "ins"
+}
+
+// sideBySide lays out a line diff of a and b as rows, pairing runs of deleted
+// lines with the inserted lines that replace them.
+func sideBySide(a, b string) []diffRow {
+	var rows []diffRow
+	var dels, ins []textdiff.Line
+	flush := func() {
+		for k := 0; k < len(dels) || k < len(ins); k++ {
+			var row diffRow
+			if k < len(dels) {
+				row.Left, row.LeftNo = dels[k].Text, dels[k].OldLine
+			}
+			if k < len(ins) {
+				row.Right, row.RightNo = ins[k].Text, ins[k].NewLine
+			}
+			switch {
+			case row.LeftNo > 0 && row.RightNo > 0:
+				row.Kind = "change"
+			case row.LeftNo > 0:
+				row.Kind = "del"
+			default:
+				row.Kind = "ins"
+			}
+			rows = append(rows, row)
+		}
+		dels, ins = nil, nil
+	}
+
+	for _, op := range textdiff.Lines(a, b) {
+		switch op.Kind {
+		case textdiff.Delete:
+			dels = append(dels, op)
+		case textdiff.Insert:
+			ins = append(ins, op)
+		default:
+			flush()
+			rows = append(rows, diffRow{Left: op.Text, Right: op.Text, LeftNo: op.OldLine, RightNo: op.NewLine})
+		}
+	}
+	flush()
+	return rows
+}
+
+// sideBySideTemplate renders the rows produced by sideBySide.
+const sideBySideTemplate = `{{define "sbs"}}<table class="sbs mono">
+{{range .}}<tr>
+<td class="n">{{if .LeftNo}}{{.LeftNo}}{{end}}</td><td class="{{if or (eq .Kind "del") (eq .Kind "change")}}del{{end}}">{{.Left}}</td>
+<td class="n">{{if .RightNo}}{{.RightNo}}{{end}}</td><td class="{{if or (eq .Kind "ins") (eq .Kind "change")}}ins{{end}}">{{.Right}}</td>
+</tr>
+{{end}}</table>{{end}}`
@@ -0,0 +1,166 @@
+package htmlreport
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/contextsubstrate/ctx/internal/diff"
+	"github.com/contextsubstrate/ctx/internal/pack"
+	"github.com/contextsubstrate/ctx/internal/replay"
+)
+
+func setupTestStore(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	root := filepath.Join(dir, ".ctx")
+	os.MkdirAll(filepath.Join(root, "objects"), 0755)
+	os.MkdirAll(filepath.Join(root, "packs"), 0755)
+	os.MkdirAll(filepath.Join(root, "refs"), 0755)
+	return root
+}
+
+func createPack(t *testing.T, root string, output string) *pack.Pack {
+	t.Helper()
+	log := &pack.ExecutionLog{
+		Model:        pack.LogModel{Identifier: "test-model", Parameters: map[string]interface{}{"temperature": float64(0)}},
+		SystemPrompt: "You are <careful>.",
+		Prompts:      []pack.LogPrompt{{Role: "user", Content: "do something"}},
+		Steps: []pack.LogStep{
+			{Index: 0, Type: "tool_call", Tool: "echo", Parameters: map[string]interface{}{"text": output, "n": float64(3)}, Output: output, Deterministic: true},
+		},
+		Outputs:     []pack.LogOutput{{Name: "result.txt", Content: "line 1\n" + output + "\nline 3\n"}},
+		Environment: pack.LogEnvironment{OS: "darwin", Runtime: "go1.22", ToolVersions: map[string]string{}},
+	}
+	p, err := pack.CreatePack(root, log)
+	if err != nil {
+		t.Fatalf("CreatePack failed: %v", err)
+	}
+	if err := pack.RegisterPack(root, p.Hash); err != nil {
+		t.Fatalf("RegisterPack failed: %v", err)
+	}
+	return p
+}
+
+func TestPackHTML(t *testing.T) {
+	root := setupTestStore(t)
+	p := createPack(t, root, "hello")
+
+	data, err := Pack(root, p)
+	if err != nil {
+		t.Fatalf("Pack failed: %v", err)
+	}
+	html := string(data)
+	for _, want := range []string{
+		"<!DOCTYPE html>",
+		"<style>",
+		"You are &lt;careful&gt;.",
+		`<span class="j-key">&#34;n&#34;</span>: <span class="j-num">3</span>`,
+		"<details>",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("expected %q in pack HTML", want)
+		}
+	}
+	if strings.Contains(html, "<careful>") {
+		t.Error("expected blob content to be escaped")
+	}
+}
+
+func TestDiffHTML(t *testing.T) {
+	root := setupTestStore(t)
+	a := createPack(t, root, "alpha")
+	b := createPack(t, root, "beta")
+
+	report, err := diff.Diff(root, a.Hash, b.Hash)
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	data, err := Diff(root, report)
+	if err != nil {
+		t.Fatalf("Diff HTML failed: %v", err)
+	}
+	html := string(data)
+	for _, want := range []string{"Likely cause", "param_drift", `class="sbs mono"`, `<td class="del">alpha</td>`, `<td class="ins">beta</td>`} {
+		if !strings.Contains(html, want) {
+			t.Errorf("expected %q in diff HTML", want)
+		}
+	}
+}
+
+func TestReplayHTML(t *testing.T) {
+	root := setupTestStore(t)
+	p := createPack(t, root, "hello")
+
+	report, err := replay.Replay(root, p.Hash)
+	if err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	data, err := Replay(root, report)
+	if err != nil {
+		t.Fatalf("Replay HTML failed: %v", err)
+	}
+	if !strings.Contains(string(data), "Replay "+p.Hash[7:19]) {
+		t.Errorf("expected replay heading in HTML:\n%s", data)
+	}
+
+	report.StartTime = time.Date(2026, 1, 2, 10, 0, 0, 0, time.FixedZone("UTC+5", 5*3600))
+	data, err = Replay(root, report)
+	if err != nil {
+		t.Fatalf("Replay HTML failed: %v", err)
+	}
+	if !strings.Contains(string(data), "2026-01-02 05:00:00 UTC") {
+		t.Errorf("expected the start time converted to UTC:\n%s", data)
+	}
+}
+
+func TestSideBySide(t *testing.T) {
+	rows := sideBySide("a\nb\nc\n", "a\nB\nc\nd\n")
+	want := []diffRow{
+		{Left: "a", Right: "a", LeftNo: 1, RightNo: 1},
+		{Left: "b", Right: "B", LeftNo: 2, RightNo: 2, Kind: "change"},
+		{Left: "c", Right: "c", LeftNo: 3, RightNo: 3},
+		{Right: "d", RightNo: 4, Kind: "ins"},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("expected %d rows, got %+v", len(want), rows)
+	}
+	for i := range want {
+		if rows[i] != want[i] {
+			t.Errorf("row %d: got %+v, want %+v", i, rows[i], want[i])
+		}
+	}
+}
+
+func TestPackHTMLBinaryContent(t *testing.T) {
+	root := setupTestStore(t)
+	png := "iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mNk+M9QDwADhgGAWjR9awAAAABJRU5ErkJggg=="
+	log := &pack.ExecutionLog{
+		Model:        pack.LogModel{Identifier: "test-model"},
+		SystemPrompt: "system",
+		Inputs:       []pack.LogInput{{Name: "scan.pdf", Content: "JVBERi0xLjQK", Encoding: pack.EncodingBase64, MediaType: "application/pdf"}},
+		Outputs:      []pack.LogOutput{{Name: "dot.png", Content: png, Encoding: pack.EncodingBase64, MediaType: "image/png"}},
+		Environment:  pack.LogEnvironment{OS: "darwin", Runtime: "go1.22"},
+	}
+	p, err := pack.CreatePack(root, log)
+	if err != nil {
+		t.Fatalf("CreatePack failed: %v", err)
+	}
+
+	data, err := Pack(root, p)
+	if err != nil {
+		t.Fatalf("Pack failed: %v", err)
+	}
+	html := string(data)
+	for _, want := range []string{
+		"(binary content, application/pdf, 9 bytes)",
+		`<img src="data:image/png;base64,` + strings.ReplaceAll(png, "+", "&#43;") + `"`,
+		`<span class="badge">image/png</span>`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("expected %q in pack HTML", want)
+		}
+	}
+}
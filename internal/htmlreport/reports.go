@@ -0,0 +1,270 @@
+package htmlreport
+
+import (
+	"html/template"
+
+	"github.com/contextsubstrate/ctx/internal/diff"
+	"github.com/contextsubstrate/ctx/internal/pack"
+	"github.com/contextsubstrate/ctx/internal/replay"
+	"github.com/contextsubstrate/ctx/internal/store"
+)
+
+var packTemplate = template.Must(template.New("pack").Funcs(funcs).Parse(`
+<h1>Pack {{short .Pack.Hash}}</h1>
+<table>
+<tr><th>Created</th><td>{{.Pack.Created.UTC.Format "2006-01-02 15:04:05 UTC"}}</td></tr>
+<tr><th>Model</th><td class="mono">{{.Pack.Model.Identifier}}</td></tr>
+{{with .Pack.Parent}}<tr><th>Parent</th><td class="mono">{{short .}}</td></tr>{{end}}
+<tr><th>Environment</th><td>{{.Pack.Environment.OS}} / {{.Pack.Environment.Runtime}}</td></tr>
+{{if .Pack.HasUsage}}<tr><th>Usage</th><td>{{.Usage}}, {{.Pack.TotalLatency}}</td></tr>{{end}}
+</table>
+{{with .Pack.Model.Parameters}}<details><summary>Model parameters</summary><pre>{{json .}}</pre></details>{{end}}
+
+<h2>Prompts</h2>
+<details><summary>System prompt <span class="muted mono">{{short .Pack.SystemPrompt}}</span></summary><pre>{{.SystemPrompt}}</pre></details>
+{{range .Prompts}}<details><summary>{{.Role}} <span class="muted mono">{{short .Ref}}</span></summary><pre>{{.Text}}</pre></details>
+{{end}}
+
+{{if .Inputs}}<h2>Inputs ({{len .Inputs}})</h2>
+{{range .Inputs}}<details><summary>{{.Name}}{{with .MediaType}} <span class="badge">{{.}}</span>{{end}} <span class="muted mono">{{short .Ref}}</span></summary>{{with .Image}}<img src="{{.}}" alt="">{{end}}<pre>{{.Text}}</pre></details>
+{{end}}{{end}}
+
+{{if .Pack.Agents}}<h2>Agents ({{len .Pack.Agents}})</h2>
+<table>{{range .Pack.Agents}}<tr><td>{{.Name}}</td><td class="mono">{{.Model.Identifier}}</td></tr>{{end}}</table>{{end}}
+
+<h2>Steps ({{len .Steps}})</h2>
+{{range .Steps}}<details>
+<summary><span class="mono">[{{.Step.Index}}]</span> {{.Step.Type}} <b>{{.Step.Tool}}</b>
+{{with .Step.Agent}}<span class="badge">@{{.}}</span>{{end}}
+{{if .Step.Deterministic}}<span class="badge ok">deterministic</span>{{else}}<span class="badge warn">non-deterministic</span>{{end}}
+{{with .Step.ChildPack}}<span class="badge">sub-run {{short .}}</span>{{end}}</summary>
+<p class="muted">Parameters</p><pre>{{json .Step.Parameters}}</pre>
+{{with .Step.Inputs}}<p class="muted">Inputs</p><pre>{{json .}}</pre>{{end}}
+<p class="muted">Output <span class="mono">{{short .Step.OutputRef}}</span>{{with .Step.OutputMediaType}} <span class="badge">{{.}}</span>{{end}}</p>{{with .OutputImage}}<img src="{{.}}" alt="">{{end}}<pre>{{.Output}}</pre>
+</details>
+{{end}}
+
+{{if .Outputs}}<h2>Outputs ({{len .Outputs}})</h2>
+{{range .Outputs}}<details><summary>{{.Name}}{{with .MediaType}} <span class="badge">{{.}}</span>{{end}} <span class="muted mono">{{short .Ref}}</span></summary>{{with .Image}}<img src="{{.}}" alt="">{{end}}<pre>{{.Text}}</pre></details>
+{{end}}{{end}}
+
+{{with .Pack.Environment.ToolVersions}}<h2>Tool Versions</h2>
+<table class="mono">{{range $tool, $version := .}}<tr><td>{{$tool}}</td><td>{{$version}}</td></tr>{{end}}</table>{{end}}
+`))
+
+type namedBlob struct {
+	Name, Role, Ref, Text, MediaType string
+	Image                            template.URL
+}
+
+type packStep struct {
+	Step        pack.Step
+	Output      string
+	OutputImage template.URL
+}
+
+// Pack renders a pack with its prompts, inputs, step outputs and final
+// outputs loaded from the store.
+func Pack(storeRoot string, p *pack.Pack) ([]byte, error) {
+	body, err := PackBody(storeRoot, p)
+	if err != nil {
+		return nil, err
+	}
+	return Document("Pack "+store.ShortHash(p.Hash, 12), "", body)
+}
+
+// PackBody renders the body of a pack report, for embedding in other pages.
+func PackBody(storeRoot string, p *pack.Pack) (template.HTML, error) {
+	data := struct {
+		Pack         *pack.Pack
+		Usage        string
+		SystemPrompt string
+		Prompts      []namedBlob
+		Inputs       []namedBlob
+		Steps        []packStep
+		Outputs      []namedBlob
+	}{
+		Pack:         p,
+		Usage:        pack.FormatUsage(p.TotalUsage()),
+		SystemPrompt: blobText(storeRoot, p.SystemPrompt),
+	}
+	for _, pr := range p.Prompts {
+		data.Prompts = append(data.Prompts, namedBlob{Role: pr.Role, Ref: pr.ContentRef, Text: blobText(storeRoot, pr.ContentRef)})
+	}
+	for _, in := range p.Inputs {
+		text, image := blobContent(storeRoot, in.ContentRef, in.MediaType)
+		data.Inputs = append(data.Inputs, namedBlob{Name: in.Name, Ref: in.ContentRef, Text: text, MediaType: in.MediaType, Image: image})
+	}
+	for _, s := range p.Steps {
+		text, image := blobContent(storeRoot, s.OutputRef, s.OutputMediaType)
+		data.Steps = append(data.Steps, packStep{Step: s, Output: text, OutputImage: image})
+	}
+	for _, out := range p.Outputs {
+		text, image := blobContent(storeRoot, out.ContentRef, out.MediaType)
+		data.Outputs = append(data.Outputs, namedBlob{Name: out.Name, Ref: out.ContentRef, Text: text, MediaType: out.MediaType, Image: image})
+	}
+
+	return renderBody("pack", packTemplate, data)
+}
+
+var diffTemplate = template.Must(template.New("diff").Funcs(funcs).Parse(sideBySideTemplate + `
+<h1>Drift: {{.Report.PackHashA}} vs {{.Report.PackHashB}}</h1>
+{{if not .Report.HasDrift}}<p>No differences found.</p>{{else}}
+{{with .Report.LikelyCause}}{{if .Downstream}}<div class="cause">
+<h2>Likely cause</h2>
+<ul>{{range .Entries}}<li>{{with index $.Entries .}}<span class="badge bad">{{.Entry.Type}}</span> {{.Entry.Description}}{{end}}</li>{{end}}</ul>
+<p class="muted">{{.Downstream}} later difference(s) are likely downstream effects.</p>
+</div>{{end}}{{end}}
+
+<h2>{{len .Entries}} difference(s)</h2>
+{{range .Entries}}<details{{if not .Entry.Downstream}} open{{end}}{{if .Entry.Downstream}} class="downstream"{{end}}>
+<summary><span class="badge {{if .Entry.Downstream}}warn{{else}}bad{{end}}">{{.Entry.Type}}</span> {{.Entry.Description}}
+{{with .Entry.Alignment}}<span class="badge">{{.}}</span>{{end}}{{if .Entry.Downstream}} <span class="muted">(downstream)</span>{{end}}</summary>
+{{with .Entry.Params}}<table class="mono">{{range .}}<tr><td>{{.Path}}</td><td>{{.Op}}</td><td>{{json .Old}}</td><td>→ {{json .New}}</td></tr>{{end}}</table>
+{{else}}{{if or .Entry.PackA .Entry.PackB}}{{if not .Rows}}<table><tr><th>Pack A</th><td><pre>{{json .Entry.PackA}}</pre></td></tr><tr><th>Pack B</th><td><pre>{{json .Entry.PackB}}</pre></td></tr></table>{{end}}{{end}}{{end}}
+{{with .Entry.Content}}{{if .Binary}}<p class="muted">Binary content, {{.SizeA}} → {{.SizeB}} bytes.</p>{{end}}{{with .Omitted}}<p class="muted">Diff omitted: {{.}}</p>{{end}}{{end}}
+{{with .Rows}}{{template "sbs" .}}{{end}}
+</details>
+{{end}}{{end}}
+
+{{with .Report.Cost}}<h2>Cost</h2>
+<table>
+<tr><th></th><th>Pack A</th><th>Pack B</th></tr>
+<tr><th>Tokens</th><td>{{.TokensA}}</td><td>{{.TokensB}}</td></tr>
+<tr><th>Latency (ms)</th><td>{{.LatencyMsA}}</td><td>{{.LatencyMsB}}</td></tr>
+{{if not .Unpriced}}<tr><th>Cost (USD)</th><td>{{printf "%.4f" .CostA}}</td><td>{{printf "%.4f" .CostB}}</td></tr>{{end}}
+</table>{{end}}
+`))
+
+type diffEntry struct {
+	Entry diff.DriftEntry
+	Rows  []diffRow
+}
+
+// Diff renders a drift report, with side-by-side diffs of the blobs behind
+// content changes.
+func Diff(storeRoot string, r *diff.DriftReport) ([]byte, error) {
+	body, err := DiffBody(storeRoot, r)
+	if err != nil {
+		return nil, err
+	}
+	return Document("Drift "+r.PackHashA+" vs "+r.PackHashB, "", body)
+}
+
+// DiffBody renders the body of a drift report, for embedding in other pages.
+func DiffBody(storeRoot string, r *diff.DriftReport) (template.HTML, error) {
+	data := struct {
+		Report  *diff.DriftReport
+		Entries []diffEntry
+	}{Report: r}
+
+	for _, e := range r.Entries {
+		de := diffEntry{Entry: e}
+		if e.Content != nil && !e.Content.Binary && e.Content.Omitted == "" {
+			refA, refB := e.ContentRefs()
+			de.Rows = sideBySide(blobText(storeRoot, refA), blobText(storeRoot, refB))
+		}
+		data.Entries = append(data.Entries, de)
+	}
+
+	return renderBody("drift report", diffTemplate, data)
+}
+
+var replayTemplate = template.Must(template.New("replay").Funcs(funcs).Parse(`
+{{define "steps"}}{{range .}}<details{{if or (eq .Result.Status "failed") (and (eq .Result.Status "diverged") .Result.Deterministic)}} open{{end}}>
+<summary><span class="mono">[{{.Result.Index}}]</span> <b>{{.Result.Tool}}</b>
+<span class="badge {{.Class}}">{{.Result.Status}}</span>
+{{with .Result.Agent}}<span class="badge">@{{.}}</span>{{end}}
+{{with .Result.Reason}}<span class="muted">{{.}}</span>{{end}}</summary>
+<table class="mono">
+{{with .Result.ExpectedHash}}<tr><th>Expected</th><td>{{short .}}</td></tr>{{end}}
+{{with .Result.ActualHash}}<tr><th>Actual</th><td>{{short .}}</td></tr>{{end}}
+<tr><th>Deterministic</th><td>{{.Result.Deterministic}}</td></tr>
+</table>
+{{with .Recorded}}<p class="muted">Recorded output</p><pre>{{.}}</pre>{{end}}
+{{with .Children}}<p class="muted">Sub-run</p>{{template "steps" .}}{{end}}
+</details>
+{{end}}{{end}}
+<h1>Replay {{short .Report.PackHash}}</h1>
+<p><span class="badge {{.Class}}">{{.Report.Fidelity}}</span>
+<span class="muted">{{.Report.StartTime.UTC.Format "2006-01-02 15:04:05 UTC"}}, took {{.Duration}}</span></p>
+{{with .Report.Drift}}<h2>Drift ({{len .}})</h2>
+<ul>{{range .}}<li><span class="badge warn">{{.Type}}</span> {{.Description}}{{if or .Expected .Actual}} <span class="mono muted">({{.Expected}} → {{.Actual}})</span>{{end}}</li>{{end}}</ul>{{end}}
+<h2>Steps ({{len .Steps}})</h2>
+{{template "steps" .Steps}}
+`))
+
+type replayStep struct {
+	Result   replay.StepResult
+	Class    string
+	Recorded string
+	Children []replayStep
+}
+
+// Replay renders a replay report. Recorded outputs of steps that did not
+// match are loaded from the store for comparison.
+func Replay(storeRoot string, r *replay.ReplayReport) ([]byte, error) {
+	body, err := ReplayBody(storeRoot, r)
+	if err != nil {
+		return nil, err
+	}
+	return Document("Replay "+store.ShortHash(r.PackHash, 12), "", body)
+}
+
+// ReplayBody renders the body of a replay report, for embedding in other pages.
+func ReplayBody(storeRoot string, r *replay.ReplayReport) (template.HTML, error) {
+	data := struct {
+		Report   *replay.ReplayReport
+		Class    string
+		Duration string
+		Steps    []replayStep
+	}{
+		Report:   r,
+		Class:    fidelityClass(r.Fidelity),
+		Duration: r.EndTime.Sub(r.StartTime).String(),
+		Steps:    replaySteps(storeRoot, r.Steps),
+	}
+	return renderBody("replay report", replayTemplate, data)
+}
+
+func replaySteps(storeRoot string, results []replay.StepResult) []replayStep {
+	var steps []replayStep
+	for _, res := range results {
+		s := replayStep{Result: res, Class: statusClass(res)}
+		if res.Status == replay.StepDiverged || res.Status == replay.StepFailed {
+			s.Recorded = blobText(storeRoot, res.ExpectedHash)
+		}
+		if res.Child != nil {
+			s.Children = replaySteps(storeRoot, res.Child.Steps)
+		}
+		steps = append(steps, s)
+	}
+	return steps
+}
+
+func statusClass(res replay.StepResult) string {
+	switch res.Status {
+	case replay.StepMatched, replay.StepOverridden:
+		return "ok"
+	case replay.StepFailed:
+		return "bad"
+	case replay.StepDiverged:
+		if res.Deterministic {
+			return "bad"
+		}
+		return "warn"
+	default:
+		return ""
+	}
+}
+
+func fidelityClass(f replay.FidelityLevel) string {
+	switch f {
+	case replay.FidelityExact:
+		return "ok"
+	case replay.FidelityDegraded:
+		return "warn"
+	default:
+		return "bad"
+	}
+}
@@ -0,0 +1,177 @@
+// Package migrate upgrades a store's on-disk layout between format versions.
+package migrate
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/contextsubstrate/ctx/internal/graph"
+	"github.com/contextsubstrate/ctx/internal/pack"
+	"github.com/contextsubstrate/ctx/internal/store"
+)
+
+// BackupDir is where Migrate copies the parts of a store it rewrites,
+// relative to the store.
+const BackupDir = "backups"
+
+// Step upgrades a store from one format version to the next.
+type Step struct {
+	From        string
+	To          string
+	Description string
+	Apply       func(storeRoot string) error
+}
+
+// Steps lists every migration in order. Each step's To is the next step's
+// From, and the last step's To is store.ConfigVersion.
+var Steps = []Step{
+	{
+		From:        "0.1",
+		To:          "0.2",
+		Description: "upgrade pack manifests to format 0.2 and re-store them under their canonical hash",
+		Apply: func(storeRoot string) error {
+			_, err := pack.RehashPacks(storeRoot)
+			return err
+		},
+	},
+}
+
+// Result describes a migration.
+type Result struct {
+	From    string
+	To      string
+	Backup  string
+	Applied []Step
+}
+
+// Version returns the format version recorded in a store's config.json. A
+// store without one predates versioned configs and is treated as "0.1".
+func Version(storeRoot string) (string, error) {
+	cfg, err := store.LoadConfig(storeRoot)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(store.ConfigPath(storeRoot)); os.IsNotExist(err) || cfg.Version == "" {
+		return "0.1", nil
+	}
+	return cfg.Version, nil
+}
+
+// Plan returns the steps needed to bring a store up to store.ConfigVersion.
+func Plan(storeRoot string) ([]Step, error) {
+	version, err := Version(storeRoot)
+	if err != nil {
+		return nil, err
+	}
+	var plan []Step
+	for _, s := range Steps {
+		if s.From == version {
+			plan = append(plan, s)
+			version = s.To
+		}
+	}
+	if version != store.ConfigVersion {
+		return nil, fmt.Errorf("no migration path from store version %s to %s", version, store.ConfigVersion)
+	}
+	return plan, nil
+}
+
+// Migrate brings a store up to store.ConfigVersion. Before changing
+// anything it copies config.json, packs/ and graph/ to a timestamped
+// directory under backups/; objects are never deleted by a migration, so
+// they are not copied. The config version is written after each step, so
+// an interrupted migration resumes from the last completed step.
+func Migrate(storeRoot string) (*Result, error) {
+	from, err := Version(storeRoot)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := Plan(storeRoot)
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{From: from, To: from}
+	if len(plan) == 0 {
+		return result, nil
+	}
+
+	backup := filepath.Join(BackupDir, fmt.Sprintf("%s-%s", from, time.Now().UTC().Format("20060102T150405Z")))
+	if err := backupStore(storeRoot, backup); err != nil {
+		return nil, err
+	}
+	result.Backup = backup
+
+	cfg, err := store.LoadConfig(storeRoot)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range plan {
+		if err := s.Apply(storeRoot); err != nil {
+			return result, fmt.Errorf("migrating %s to %s: %w", s.From, s.To, err)
+		}
+		cfg.Version = s.To
+		if err := store.WriteConfig(storeRoot, cfg); err != nil {
+			return result, err
+		}
+		result.To = s.To
+		result.Applied = append(result.Applied, s)
+	}
+	return result, nil
+}
+
+// backupStore copies the parts of the store a migration may rewrite into
+// backup, given relative to the store.
+func backupStore(storeRoot, backup string) error {
+	dest := filepath.Join(storeRoot, backup)
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return fmt.Errorf("creating backup directory: %w", err)
+	}
+	for _, name := range []string{"config.json", "packs", graph.GraphDir} {
+		if err := copyTree(filepath.Join(storeRoot, name), filepath.Join(dest, name)); err != nil {
+			return fmt.Errorf("backing up %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// copyTree copies a file or directory. A missing source is skipped.
+func copyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == src {
+				return nil
+			}
+			return err
+		}
+		rel, _ := filepath.Rel(src, path)
+		target := filepath.Join(dst, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		if strings.HasSuffix(path, ".tmp") {
+			return nil
+		}
+		return copyFile(path, target)
+	})
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,208 @@
+package migrate
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/contextsubstrate/ctx/internal/pack"
+	"github.com/contextsubstrate/ctx/internal/store"
+)
+
+func setupTestStore(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	root := filepath.Join(dir, ".ctx")
+	os.MkdirAll(filepath.Join(root, "objects"), 0755)
+	os.MkdirAll(filepath.Join(root, "packs"), 0755)
+	os.MkdirAll(filepath.Join(root, "refs"), 0755)
+	return root
+}
+
+func TestStepsReachConfigVersion(t *testing.T) {
+	for i := 1; i < len(Steps); i++ {
+		if Steps[i].From != Steps[i-1].To {
+			t.Errorf("step %d starts at %s, but step %d ends at %s", i, Steps[i].From, i-1, Steps[i-1].To)
+		}
+	}
+	if last := Steps[len(Steps)-1].To; last != store.ConfigVersion {
+		t.Errorf("expected the last step to reach %s, got %s", store.ConfigVersion, last)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	root := setupTestStore(t)
+	store.WriteConfig(root, &store.Config{Version: "0.1", Remote: "/mnt/ctx"})
+
+	p, err := pack.CreatePack(root, &pack.ExecutionLog{
+		Model:        pack.LogModel{Identifier: "test-model"},
+		SystemPrompt: "You are helpful.",
+		Prompts:      []pack.LogPrompt{{Role: "user", Content: "Hello"}},
+	})
+	if err != nil {
+		t.Fatalf("CreatePack failed: %v", err)
+	}
+	// A pack stored before manifests were canonicalized
+	legacy := *p
+	legacy.Hash = ""
+	data, _ := json.Marshal(&legacy)
+	legacyHash, _ := store.WriteBlob(root, data)
+	pack.RegisterPack(root, legacyHash)
+
+	plan, err := Plan(root)
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	if len(plan) != len(Steps) {
+		t.Errorf("expected every step planned, got %d", len(plan))
+	}
+
+	result, err := Migrate(root)
+	if err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	if result.From != "0.1" || result.To != store.ConfigVersion {
+		t.Errorf("expected 0.1 -> %s, got %s -> %s", store.ConfigVersion, result.From, result.To)
+	}
+
+	cfg, err := store.LoadConfig(root)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.Version != store.ConfigVersion || cfg.Remote != "/mnt/ctx" {
+		t.Errorf("expected version bumped and settings kept, got %+v", cfg)
+	}
+
+	_, legacyHex, _ := store.ParseHash(legacyHash)
+	if _, err := os.Stat(filepath.Join(root, result.Backup, "packs", legacyHex)); err != nil {
+		t.Errorf("expected the old pack registration in the backup: %v", err)
+	}
+	backupCfg, _ := store.LoadConfig(filepath.Join(root, result.Backup))
+	if backupCfg.Version != "0.1" {
+		t.Errorf("expected the backed up config at 0.1, got %s", backupCfg.Version)
+	}
+
+	registered, _ := pack.RegisteredPacks(root)
+	for _, h := range registered {
+		loaded, err := pack.LoadPack(root, h)
+		if err != nil {
+			t.Fatalf("LoadPack failed: %v", err)
+		}
+		if c, _ := pack.CanonicalHash(loaded); c != h {
+			t.Errorf("expected %s to be registered under its canonical hash, got %s", h, c)
+		}
+	}
+
+	again, err := Migrate(root)
+	if err != nil {
+		t.Fatalf("second Migrate failed: %v", err)
+	}
+	if len(again.Applied) != 0 || again.Backup != "" {
+		t.Errorf("expected nothing to do on an up-to-date store, got %+v", again)
+	}
+}
+
+// A store written by ctx 0.1: its config, and a pack and a finalized fork
+// of it exactly as that version stored them, with manifests hashed over Go's
+// JSON encoding and null maps for missing parameters and tool versions.
+const (
+	v01Config   = "{\n  \"version\": \"0.1\"\n}"
+	v01PackHash = "sha256:24a2a1343c0fd8f7ec19c0cd4ea725aee11dffe97223e5c374bdec4a64cbdb68"
+	v01Pack     = `{"created":"2026-10-18T13:29:25.362204608Z","environment":{"os":"linux","runtime":"go1.22","tool_versions":null},"hash":"","inputs":[{"content_ref":"sha256:492d5ea496056f1a6a6592241032fab764c321596317930b4fa0e1e8bc3b7470","name":"data.csv","size":8}],"model":{"identifier":"gpt-4","parameters":{"seed":12345,"temperature":0}},"outputs":[{"content_ref":"sha256:4ae7b7f83ddb2d7770a9e83196edfee2b75de879eb822e291edbecf6c6f13b14","name":"summary.md"}],"prompts":[{"content_ref":"sha256:eaa9fae21181561b40839a042df0135c88ab2b14b6c9b758f8d972b4394be34e","role":"user"}],"steps":[{"deterministic":true,"index":0,"output_ref":"sha256:492d5ea496056f1a6a6592241032fab764c321596317930b4fa0e1e8bc3b7470","parameters":{"path":"data.csv"},"timestamp":"2025-11-02T10:00:00Z","tool":"read_file","type":"tool_call"},{"deterministic":false,"index":1,"output_ref":"","parameters":null,"timestamp":"2025-11-02T10:00:01Z","tool":"noop","type":"tool_call"}],"system_prompt":"sha256:3ee714542be3ce5e2f4ee99601fc35a62af8f7ad1f1e237175bcbb0c05c2056f","version":"0.1"}`
+	v01ForkHash = "sha256:ee53dc8581fd4564b4f530444672bef601a7ddb5e6fbcfdcea4fe960fa56a702"
+	v01Fork     = `{"version":"0.1","hash":"","created":"2026-10-18T13:29:25.362204608Z","model":{"identifier":"gpt-4","parameters":{"seed":12345,"temperature":0}},"system_prompt":"sha256:3ee714542be3ce5e2f4ee99601fc35a62af8f7ad1f1e237175bcbb0c05c2056f","prompts":[{"role":"user","content_ref":"sha256:eaa9fae21181561b40839a042df0135c88ab2b14b6c9b758f8d972b4394be34e"}],"inputs":[{"name":"data.csv","content_ref":"sha256:492d5ea496056f1a6a6592241032fab764c321596317930b4fa0e1e8bc3b7470","size":8}],"steps":[{"index":0,"type":"tool_call","tool":"read_file","parameters":{"path":"data.csv"},"output_ref":"sha256:492d5ea496056f1a6a6592241032fab764c321596317930b4fa0e1e8bc3b7470","deterministic":true,"timestamp":"2025-11-02T10:00:00Z"},{"index":1,"type":"tool_call","tool":"noop","parameters":null,"output_ref":"","deterministic":false,"timestamp":"2025-11-02T10:00:01Z"}],"outputs":[{"name":"summary.md","content_ref":"sha256:4ae7b7f83ddb2d7770a9e83196edfee2b75de879eb822e291edbecf6c6f13b14"}],"environment":{"os":"linux","runtime":"go1.22","tool_versions":null},"parent":"sha256:24a2a1343c0fd8f7ec19c0cd4ea725aee11dffe97223e5c374bdec4a64cbdb68"}`
+)
+
+func writeV01Store(t *testing.T) string {
+	t.Helper()
+	root := setupTestStore(t)
+	os.MkdirAll(filepath.Join(root, "graph", "manifests"), 0755)
+	os.MkdirAll(filepath.Join(root, "graph", "snapshots"), 0755)
+	os.WriteFile(store.ConfigPath(root), []byte(v01Config), 0644)
+	for _, content := range []string{"You are a <careful> assistant & reviewer.", "Summarize data.csv", "a,b\n1,2\n", "Two columns, one row.\n"} {
+		store.WriteBlob(root, []byte(content))
+	}
+	for hash, manifest := range map[string]string{v01PackHash: v01Pack, v01ForkHash: v01Fork} {
+		if h, _ := store.WriteBlob(root, []byte(manifest)); h != hash {
+			t.Fatalf("fixture manifest hashes to %s, expected %s", h, hash)
+		}
+		if err := pack.RegisterPack(root, hash); err != nil {
+			t.Fatalf("RegisterPack failed: %v", err)
+		}
+	}
+	return root
+}
+
+func TestMigrateV01Store(t *testing.T) {
+	root := writeV01Store(t)
+
+	// 0.1 manifests load, upgraded in memory, before the store is migrated
+	old, err := pack.LoadPack(root, v01ForkHash)
+	if err != nil {
+		t.Fatalf("LoadPack of a 0.1 manifest failed: %v", err)
+	}
+	if old.Version != pack.ManifestVersion || old.Steps[1].Parameters == nil || old.Environment.ToolVersions == nil {
+		t.Errorf("expected the manifest upgraded in memory, got %+v", old)
+	}
+
+	result, err := Migrate(root)
+	if err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	if result.From != "0.1" || result.To != store.ConfigVersion {
+		t.Errorf("expected 0.1 -> %s, got %s -> %s", store.ConfigVersion, result.From, result.To)
+	}
+
+	registered, _ := pack.RegisteredPacks(root)
+	if len(registered) != 2 {
+		t.Fatalf("expected both packs registered, got %v", registered)
+	}
+	var root01, fork *pack.Pack
+	for _, h := range registered {
+		if h == v01PackHash || h == v01ForkHash {
+			t.Errorf("expected %s re-stored under a new hash", store.ShortHash(h, 12))
+		}
+		data, err := store.ReadBlob(root, h)
+		if err != nil {
+			t.Fatalf("ReadBlob failed: %v", err)
+		}
+		var stored map[string]interface{}
+		json.Unmarshal(data, &stored)
+		if stored["version"] != pack.ManifestVersion {
+			t.Errorf("expected the stored manifest at %s, got %v", pack.ManifestVersion, stored["version"])
+		}
+		if bytes.Contains(data, []byte("null")) {
+			t.Errorf("expected no null maps in the stored manifest: %s", data)
+		}
+		p, _ := pack.LoadPack(root, h)
+		if c, _ := pack.CanonicalHash(p); c != h {
+			t.Errorf("expected %s stored under its canonical hash, got %s", h, c)
+		}
+		if p.Parent == "" {
+			root01 = p
+		} else {
+			fork = p
+		}
+	}
+	if root01 == nil || fork == nil || fork.Parent != root01.Hash {
+		t.Errorf("expected the fork to point at its parent's new hash")
+	}
+	if seed := root01.Model.Parameters["seed"]; seed != json.Number("12345") {
+		t.Errorf("expected model parameters kept, got %v", root01.Model.Parameters)
+	}
+
+	// The 0.1 manifests are kept, so their hashes still resolve
+	if _, err := pack.LoadPack(root, v01PackHash); err != nil {
+		t.Errorf("expected the old hash to still load: %v", err)
+	}
+}
+
+func TestMigrateRejectsNewerStore(t *testing.T) {
+	root := setupTestStore(t)
+	store.WriteConfig(root, &store.Config{Version: "99.0"})
+	if _, err := Migrate(root); err == nil {
+		t.Error("expected an error migrating a store from a newer ctx")
+	}
+}
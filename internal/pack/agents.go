@@ -0,0 +1,43 @@
+package pack
+
+// FindAgent returns the agent with the given name, or nil if the pack does not declare it.
+func (p *Pack) FindAgent(name string) *Agent {
+	for i := range p.Agents {
+		if p.Agents[i].Name == name {
+			return &p.Agents[i]
+		}
+	}
+	return nil
+}
+
+// StepModel returns the model that performed a step: the model recorded on
+// the step itself, else its agent's model, else the pack-level model.
+func (p *Pack) StepModel(s *Step) Model {
+	model := p.Model
+	if a := p.FindAgent(s.Agent); a != nil && a.Model.Identifier != "" {
+		model = a.Model
+	}
+	if s.Model != "" && s.Model != model.Identifier {
+		return Model{Identifier: s.Model}
+	}
+	return model
+}
+
+// AgentSystemPrompt returns the system prompt blob reference in effect for an
+// agent, falling back to the pack-level system prompt.
+func (p *Pack) AgentSystemPrompt(name string) string {
+	if a := p.FindAgent(name); a != nil && a.SystemPrompt != "" {
+		return a.SystemPrompt
+	}
+	return p.SystemPrompt
+}
+
+// AgentStepCounts returns how many steps each agent performed. Steps without
+// an agent are counted under the empty name.
+func (p *Pack) AgentStepCounts() map[string]int {
+	counts := make(map[string]int)
+	for _, s := range p.Steps {
+		counts[s.Agent]++
+	}
+	return counts
+}
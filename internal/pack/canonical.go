@@ -0,0 +1,196 @@
+package pack
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/contextsubstrate/ctx/internal/store"
+)
+
+// MarshalCanonical returns the canonical encoding of a manifest, the bytes a
+// pack's hash is computed over and stored as.
+//
+// The encoding is the manifest's JSON serialized per RFC 8785 (JSON
+// Canonicalization Scheme): object keys sorted by UTF-16 code units, no
+// insignificant whitespace, minimal string escaping and ECMAScript number
+// formatting. Integers are the one exception: they are written digit for
+// digit, so seeds and IDs beyond 2^53 keep their value where ECMAScript
+// would round them. Fields that refer back to the pack itself, the hash and each
+// output's context_pack, are cleared first; the hash key is kept with an
+// empty value.
+func MarshalCanonical(p *Pack) ([]byte, error) {
+	m := *p
+	m.Hash = ""
+	if len(p.Outputs) > 0 {
+		m.Outputs = make([]Output, len(p.Outputs))
+		copy(m.Outputs, p.Outputs)
+		for i := range m.Outputs {
+			m.Outputs[i].ContextPack = ""
+		}
+	}
+
+	data, err := json.Marshal(&m)
+	if err != nil {
+		return nil, fmt.Errorf("serializing manifest: %w", err)
+	}
+	return CanonicalizeJSON(data)
+}
+
+// CanonicalHash computes the content hash of a pack manifest from its
+// canonical encoding.
+func CanonicalHash(p *Pack) (string, error) {
+	data, err := MarshalCanonical(p)
+	if err != nil {
+		return "", err
+	}
+	return store.HashContent(data), nil
+}
+
+// DecodeJSON unmarshals data into v, keeping numbers in interface values as
+// json.Number so integers beyond float64 precision survive a round trip.
+func DecodeJSON(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return fmt.Errorf("unexpected data after JSON value")
+	}
+	return nil
+}
+
+// CanonicalizeJSON re-encodes a JSON document per RFC 8785.
+func CanonicalizeJSON(data []byte) ([]byte, error) {
+	var v interface{}
+	if err := DecodeJSON(data, &v); err != nil {
+		return nil, fmt.Errorf("parsing JSON: %w", err)
+	}
+	return appendCanonical(nil, v)
+}
+
+func appendCanonical(b []byte, v interface{}) ([]byte, error) {
+	switch x := v.(type) {
+	case nil:
+		return append(b, "null"...), nil
+	case bool:
+		return strconv.AppendBool(b, x), nil
+	case json.Number:
+		if n, ok := new(big.Int).SetString(string(x), 10); ok {
+			return n.Append(b, 10), nil
+		}
+		f, err := strconv.ParseFloat(string(x), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %s: %w", x, err)
+		}
+		return appendNumber(b, f)
+	case string:
+		return appendString(b, x), nil
+	case []interface{}:
+		b = append(b, '[')
+		for i, item := range x {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			var err error
+			if b, err = appendCanonical(b, item); err != nil {
+				return nil, err
+			}
+		}
+		return append(b, ']'), nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool { return lessUTF16(keys[i], keys[j]) })
+		b = append(b, '{')
+		for i, k := range keys {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			b = appendString(b, k)
+			b = append(b, ':')
+			var err error
+			if b, err = appendCanonical(b, x[k]); err != nil {
+				return nil, err
+			}
+		}
+		return append(b, '}'), nil
+	default:
+		return nil, fmt.Errorf("unsupported JSON value of type %T", v)
+	}
+}
+
+// appendNumber formats a number the way ECMAScript's Number.prototype.toString
+// does, as RFC 8785 requires.
+func appendNumber(b []byte, f float64) ([]byte, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, fmt.Errorf("number %v cannot be represented in JSON", f)
+	}
+	if f == 0 {
+		return append(b, '0'), nil // Also covers -0
+	}
+	abs := math.Abs(f)
+	if abs >= 1e-6 && abs < 1e21 {
+		return strconv.AppendFloat(b, f, 'f', -1, 64), nil
+	}
+	n := len(b)
+	b = strconv.AppendFloat(b, f, 'e', -1, 64)
+	// Go writes e-07 where ECMAScript writes e-7
+	if m := len(b); m-n >= 4 && b[m-4] == 'e' && b[m-3] == '-' && b[m-2] == '0' {
+		b[m-2] = b[m-1]
+		b = b[:m-1]
+	}
+	return b, nil
+}
+
+// appendString writes a JSON string with only the escapes RFC 8785 requires.
+func appendString(b []byte, s string) []byte {
+	const hex = "0123456789abcdef"
+	b = append(b, '"')
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == '"':
+			b = append(b, '\\', '"')
+		case r == '\\':
+			b = append(b, '\\', '\\')
+		case r == '\b':
+			b = append(b, '\\', 'b')
+		case r == '\f':
+			b = append(b, '\\', 'f')
+		case r == '\n':
+			b = append(b, '\\', 'n')
+		case r == '\r':
+			b = append(b, '\\', 'r')
+		case r == '\t':
+			b = append(b, '\\', 't')
+		case r < 0x20:
+			b = append(b, '\\', 'u', '0', '0', hex[r>>4], hex[r&0xf])
+		default:
+			b = utf8.AppendRune(b, r)
+		}
+		i += size
+	}
+	return append(b, '"')
+}
+
+// lessUTF16 orders strings by their UTF-16 code units.
+func lessUTF16(a, b string) bool {
+	ua, ub := utf16.Encode([]rune(a)), utf16.Encode([]rune(b))
+	for i := 0; i < len(ua) && i < len(ub); i++ {
+		if ua[i] != ub[i] {
+			return ua[i] < ub[i]
+		}
+	}
+	return len(ua) < len(ub)
+}
@@ -0,0 +1,102 @@
+package pack
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"strings"
+	"unicode/utf8"
+)
+
+// Content encodings accepted for inputs, step outputs and outputs in an
+// execution log. Content without an encoding is UTF-8 text.
+const (
+	EncodingUTF8   = "utf-8"
+	EncodingBase64 = "base64"
+)
+
+// DefaultBinaryMediaType is recorded for base64 content that declares no
+// media type.
+const DefaultBinaryMediaType = "application/octet-stream"
+
+// decodeContent returns the bytes of log content in the given encoding.
+func decodeContent(content, encoding string) ([]byte, error) {
+	switch encoding {
+	case "", EncodingUTF8:
+		return []byte(content), nil
+	case EncodingBase64:
+		data, err := base64.StdEncoding.DecodeString(content)
+		if err != nil {
+			return nil, fmt.Errorf("decoding base64 content: %w", err)
+		}
+		return data, nil
+	default:
+		return nil, fmt.Errorf("unknown content encoding %q", encoding)
+	}
+}
+
+// contentMediaType returns the media type recorded in the manifest for log
+// content.
+func contentMediaType(mediaType, encoding string) string {
+	if mediaType == "" && encoding == EncodingBase64 {
+		return DefaultBinaryMediaType
+	}
+	return mediaType
+}
+
+// IsBinaryMediaType reports whether a media type names content that is not
+// text. An empty media type means text.
+func IsBinaryMediaType(mediaType string) bool {
+	if mediaType == "" {
+		return false
+	}
+	t, _, err := mime.ParseMediaType(mediaType)
+	if err != nil {
+		return true
+	}
+	switch {
+	case strings.HasPrefix(t, "text/"),
+		strings.HasSuffix(t, "+json"), strings.HasSuffix(t, "+xml"),
+		t == "application/json", t == "application/xml",
+		t == "application/javascript", t == "application/yaml",
+		t == "application/x-ndjson":
+		return false
+	}
+	return true
+}
+
+// IsBinaryContent reports whether content should be shown as binary: its
+// media type says so, or it is not valid UTF-8 or contains a NUL byte.
+func IsBinaryContent(data []byte, mediaType string) bool {
+	return IsBinaryMediaType(mediaType) || bytes.IndexByte(data, 0) >= 0 || !utf8.Valid(data)
+}
+
+// validateEncodings checks content encodings and media types, and that
+// base64 content decodes.
+func validateEncodings(log *ExecutionLog) []string {
+	var invalid []string
+	check := func(field, content, encoding, mediaType string) {
+		if _, err := decodeContent(content, encoding); err != nil {
+			invalid = append(invalid, fmt.Sprintf("%s: %v", field, err))
+		}
+		if mediaType != "" {
+			if _, _, err := mime.ParseMediaType(mediaType); err != nil {
+				invalid = append(invalid, fmt.Sprintf("%s: invalid media type %q", field, mediaType))
+			}
+		}
+	}
+	for i, inp := range log.Inputs {
+		if inp.Path != "" && inp.Encoding != "" {
+			invalid = append(invalid, fmt.Sprintf("inputs[%d]: encoding does not apply to a path", i))
+		}
+		check(fmt.Sprintf("inputs[%d]", i), inp.Content, inp.Encoding, inp.MediaType)
+	}
+	for i, s := range log.Steps {
+		check(fmt.Sprintf("steps[%d].output", i), s.Output, s.OutputEncoding, s.OutputMediaType)
+	}
+	for i, o := range log.Outputs {
+		check(fmt.Sprintf("outputs[%d]", i), o.Content, o.Encoding, o.MediaType)
+	}
+	return invalid
+}
@@ -1,9 +1,9 @@
 package pack
 
 import (
-	"encoding/json"
 	"fmt"
-	"sort"
+	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/contextsubstrate/ctx/internal/store"
@@ -11,9 +11,34 @@ import (
 
 // CreatePack builds an immutable Context Pack from an execution log.
 // It stores all content as blobs and produces a content-addressed manifest.
+// Secrets and personal data are redacted first unless the store disables it.
+// Content is encrypted if the store sets a default encryption key.
 func CreatePack(storeRoot string, log *ExecutionLog) (*Pack, error) {
+	return CreatePackWithKey(storeRoot, log, "")
+}
+
+// CreatePackWithKey builds a pack as CreatePack does, encrypting its content
+// with the key keyID names, or with the store's default key if keyID is "".
+// Only blob content is encrypted. The manifest stays plain and records the
+// key's ID alongside everything else it holds: model and step parameters,
+// tool names, input names, sizes and blob hashes. Redaction still applies
+// to parameters, but anything it does not catch is readable without the key.
+func CreatePackWithKey(storeRoot string, log *ExecutionLog, keyID string) (*Pack, error) {
+	key, err := packKey(storeRoot, keyID)
+	if err != nil {
+		return nil, err
+	}
+	writeBlob := func(data []byte) (string, error) {
+		return store.WriteBlobWithKey(storeRoot, data, key)
+	}
+
+	log, redaction, err := redactLog(storeRoot, log)
+	if err != nil {
+		return nil, err
+	}
+
 	// Store system prompt as blob
-	sysPromptRef, err := store.WriteBlob(storeRoot, []byte(log.SystemPrompt))
+	sysPromptRef, err := writeBlob([]byte(log.SystemPrompt))
 	if err != nil {
 		return nil, fmt.Errorf("storing system prompt: %w", err)
 	}
@@ -21,7 +46,7 @@ func CreatePack(storeRoot string, log *ExecutionLog) (*Pack, error) {
 	// Store prompts
 	prompts := make([]Prompt, len(log.Prompts))
 	for i, p := range log.Prompts {
-		ref, err := store.WriteBlob(storeRoot, []byte(p.Content))
+		ref, err := writeBlob([]byte(p.Content))
 		if err != nil {
 			return nil, fmt.Errorf("storing prompt %d: %w", i, err)
 		}
@@ -31,51 +56,99 @@ func CreatePack(storeRoot string, log *ExecutionLog) (*Pack, error) {
 	// Store inputs
 	inputs := make([]Input, len(log.Inputs))
 	for i, inp := range log.Inputs {
-		data := []byte(inp.Content)
-		ref, err := store.WriteBlob(storeRoot, data)
+		ref, size, err := storeInput(storeRoot, inp, key)
 		if err != nil {
 			return nil, fmt.Errorf("storing input %d: %w", i, err)
 		}
-		inputs[i] = Input{Name: inp.Name, ContentRef: ref, Size: int64(len(data))}
+		inputs[i] = Input{Name: inp.Name, ContentRef: ref, Size: size, MediaType: contentMediaType(inp.MediaType, inp.Encoding)}
+	}
+
+	// Store agent system prompts
+	var agents []Agent
+	for i, a := range log.Agents {
+		var promptRef string
+		if a.SystemPrompt != "" {
+			ref, err := writeBlob([]byte(a.SystemPrompt))
+			if err != nil {
+				return nil, fmt.Errorf("storing agent %d system prompt: %w", i, err)
+			}
+			promptRef = ref
+		}
+		agents = append(agents, Agent{
+			Name:         a.Name,
+			Model:        Model{Identifier: a.Model.Identifier, Parameters: a.Model.Parameters},
+			SystemPrompt: promptRef,
+			Parameters:   a.Parameters,
+		})
 	}
 
 	// Store step outputs
 	steps := make([]Step, len(log.Steps))
 	for i, s := range log.Steps {
+		// Sub-runs must already be packed so the parent can reference them by hash
+		var childPack string
+		if s.ChildPack != "" {
+			ref, err := store.ResolveHash(storeRoot, s.ChildPack)
+			if err != nil {
+				return nil, fmt.Errorf("step %d child pack: %w", i, err)
+			}
+			if !store.BlobExists(storeRoot, ref) {
+				return nil, fmt.Errorf("step %d: child pack not found: %s", i, store.ShortHash(ref, 12))
+			}
+			childPack = ref
+		}
+
 		var outputRef string
 		if s.Output != "" {
-			ref, err := store.WriteBlob(storeRoot, []byte(s.Output))
+			data, err := decodeContent(s.Output, s.OutputEncoding)
+			if err != nil {
+				return nil, fmt.Errorf("step %d output: %w", i, err)
+			}
+			ref, err := writeBlob(data)
 			if err != nil {
 				return nil, fmt.Errorf("storing step %d output: %w", i, err)
 			}
 			outputRef = ref
 		}
 		steps[i] = Step{
-			Index:         s.Index,
-			Type:          s.Type,
-			Tool:          s.Tool,
-			Parameters:    s.Parameters,
-			OutputRef:     outputRef,
-			Deterministic: s.Deterministic,
-			Timestamp:     s.Timestamp,
+			Index:           s.Index,
+			Type:            s.Type,
+			Tool:            s.Tool,
+			Parameters:      emptyIfNil(s.Parameters),
+			OutputRef:       outputRef,
+			OutputMediaType: contentMediaType(s.OutputMediaType, s.OutputEncoding),
+			Deterministic:   s.Deterministic,
+			Timestamp:       s.Timestamp,
+			OutputRedacted:  redaction.redacted(fmt.Sprintf("steps[%d].output_ref", i)),
+			ParamsRedacted:  redaction.redacted(fmt.Sprintf("steps[%d].parameters", i)),
+			Inputs:          stepInputs(s.Inputs),
+			Agent:           s.Agent,
+			ChildPack:       childPack,
+			Model:           s.Model,
+			Usage:           stepUsage(s.Usage),
+			LatencyMs:       s.LatencyMs,
 		}
 	}
 
 	// Store outputs
 	outputs := make([]Output, len(log.Outputs))
 	for i, o := range log.Outputs {
-		ref, err := store.WriteBlob(storeRoot, []byte(o.Content))
+		data, err := decodeContent(o.Content, o.Encoding)
+		if err != nil {
+			return nil, fmt.Errorf("output %d: %w", i, err)
+		}
+		ref, err := writeBlob(data)
 		if err != nil {
 			return nil, fmt.Errorf("storing output %d: %w", i, err)
 		}
-		outputs[i] = Output{Name: o.Name, ContentRef: ref}
+		outputs[i] = Output{Name: o.Name, ContentRef: ref, MediaType: contentMediaType(o.MediaType, o.Encoding)}
 	}
 
 	// Build manifest (without hash — computed next)
 	p := &Pack{
-		Version:      "0.1",
+		Version:      ManifestVersion,
 		Created:      time.Now().UTC(),
-		Model:        Model{Identifier: log.Model.Identifier, Parameters: log.Model.Parameters},
+		Model:        Model{Identifier: log.Model.Identifier, Parameters: emptyIfNil(log.Model.Parameters)},
 		SystemPrompt: sysPromptRef,
 		Prompts:      prompts,
 		Inputs:       inputs,
@@ -84,14 +157,20 @@ func CreatePack(storeRoot string, log *ExecutionLog) (*Pack, error) {
 		Environment: Environment{
 			OS:           log.Environment.OS,
 			Runtime:      log.Environment.Runtime,
-			ToolVersions: log.Environment.ToolVersions,
+			ToolVersions: emptyIfNil(log.Environment.ToolVersions),
 		},
+		Agents:     agents,
+		Redactions: redaction.Redactions,
+		Unscanned:  redaction.Unscanned,
+	}
+	if key != nil {
+		p.KeyID = key.ID
 	}
 
-	// Serialize manifest without hash field (hash IS the content hash of this JSON)
-	manifestData, err := canonicalJSON(p)
+	// The canonical manifest is stored as is; its content hash is the pack hash
+	manifestData, err := MarshalCanonical(p)
 	if err != nil {
-		return nil, fmt.Errorf("serializing manifest: %w", err)
+		return nil, err
 	}
 
 	// Store manifest as blob — the blob hash becomes the pack hash
@@ -109,59 +188,43 @@ func CreatePack(storeRoot string, log *ExecutionLog) (*Pack, error) {
 	return p, nil
 }
 
-// CanonicalHash computes the content hash of a pack manifest using canonical JSON.
-func CanonicalHash(p *Pack) (string, error) {
-	saved := p.Hash
-	p.Hash = ""
-	defer func() { p.Hash = saved }()
-
-	data, err := canonicalJSON(p)
+// storeInput stores an input's decoded content, streaming it from disk when
+// the log gives a path.
+func storeInput(storeRoot string, inp LogInput, key *store.Key) (string, int64, error) {
+	if inp.Path == "" {
+		data, err := decodeContent(inp.Content, inp.Encoding)
+		if err != nil {
+			return "", 0, err
+		}
+		ref, err := store.WriteBlobWithKey(storeRoot, data, key)
+		return ref, int64(len(data)), err
+	}
+	f, err := os.Open(inp.Path)
 	if err != nil {
-		return "", err
+		return "", 0, fmt.Errorf("opening input file: %w", err)
 	}
-	return store.HashContent(data), nil
+	defer f.Close()
+	return store.WriteBlobFromWithKey(storeRoot, f, key)
 }
 
-// canonicalJSON produces deterministic JSON output with sorted keys.
-func canonicalJSON(v interface{}) ([]byte, error) {
-	// Marshal to JSON
-	data, err := json.Marshal(v)
-	if err != nil {
-		return nil, err
+// stepInputs converts execution log step references into manifest form.
+func stepInputs(refs []LogStepInput) []StepInput {
+	if len(refs) == 0 {
+		return nil
 	}
-
-	// Re-parse and re-marshal with sorted keys to ensure canonical form
-	var raw interface{}
-	if err := json.Unmarshal(data, &raw); err != nil {
-		return nil, err
+	inputs := make([]StepInput, len(refs))
+	for i, ref := range refs {
+		inputs[i] = StepInput{Step: ref.Step, Input: ref.Input}
 	}
-
-	sorted := sortKeys(raw)
-	return json.Marshal(sorted)
+	return inputs
 }
 
-// sortKeys recursively sorts map keys for canonical JSON output.
-func sortKeys(v interface{}) interface{} {
-	switch val := v.(type) {
-	case map[string]interface{}:
-		sorted := make(map[string]interface{})
-		keys := make([]string, 0, len(val))
-		for k := range val {
-			keys = append(keys, k)
-		}
-		sort.Strings(keys)
-		for _, k := range keys {
-			sorted[k] = sortKeys(val[k])
-		}
-		return sorted
-	case []interface{}:
-		for i, item := range val {
-			val[i] = sortKeys(item)
-		}
-		return val
-	default:
-		return val
+// stepUsage converts execution log token counts into manifest form.
+func stepUsage(u *LogUsage) *Usage {
+	if u == nil {
+		return nil
 	}
+	return &Usage{PromptTokens: u.PromptTokens, CompletionTokens: u.CompletionTokens, CachedTokens: u.CachedTokens}
 }
 
 // RegisterPack records a pack hash in the .ctx/packs/ index.
@@ -176,6 +239,30 @@ func RegisterPack(storeRoot string, hash string) error {
 	return writeFileIfNotExists(path, []byte(hash))
 }
 
+// RegisteredPacks returns the hashes of all packs recorded in the .ctx/packs/ index.
+// Entries that are not valid hash names are ignored.
+func RegisteredPacks(storeRoot string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(storeRoot, "packs"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading packs directory: %w", err)
+	}
+
+	var hashes []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		ref := "sha256:" + entry.Name()
+		if store.ValidateHash(ref) {
+			hashes = append(hashes, ref)
+		}
+	}
+	return hashes, nil
+}
+
 func writeFileIfNotExists(path string, data []byte) error {
 	f, err := openFileExclusive(path)
 	if err != nil {
@@ -185,3 +272,12 @@ func writeFileIfNotExists(path string, data []byte) error {
 	_, err = f.Write(data)
 	return err
 }
+
+// emptyIfNil returns m, or an empty map if m is nil, so manifests always
+// store parameters and tool versions as objects.
+func emptyIfNil[M ~map[string]V, V any](m M) M {
+	if m == nil {
+		return M{}
+	}
+	return m
+}
@@ -0,0 +1,75 @@
+package pack
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DependsOn returns the indices of the earlier steps whose outputs this step consumed.
+func (s *Step) DependsOn() []int {
+	var deps []int
+	for _, in := range s.Inputs {
+		if in.Step != nil {
+			deps = append(deps, *in.Step)
+		}
+	}
+	return deps
+}
+
+// HasStepGraph reports whether any step in the pack declares its inputs.
+func (p *Pack) HasStepGraph() bool {
+	for _, s := range p.Steps {
+		if len(s.Inputs) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// StepDepths returns each step's depth in the dependency graph, keyed by step
+// index: steps with no step dependencies have depth 0, and every other step is
+// one deeper than its deepest dependency.
+func (p *Pack) StepDepths() map[int]int {
+	depths := make(map[int]int, len(p.Steps))
+	for _, s := range p.Steps {
+		depth := 0
+		for _, dep := range s.DependsOn() {
+			if d, ok := depths[dep]; ok && d+1 > depth {
+				depth = d + 1
+			}
+		}
+		depths[s.Index] = depth
+	}
+	return depths
+}
+
+// FormatStepGraph renders the step dependency graph as an indented list in
+// which each step is nested one level below its deepest dependency and
+// annotated with everything it consumed.
+func FormatStepGraph(p *Pack) string {
+	tools := make(map[int]string, len(p.Steps))
+	for _, s := range p.Steps {
+		tools[s.Index] = s.Tool
+	}
+	depths := p.StepDepths()
+
+	var b strings.Builder
+	for _, s := range p.Steps {
+		b.WriteString(strings.Repeat("  ", depths[s.Index]+1))
+		b.WriteString(fmt.Sprintf("[%d] %s", s.Index, s.Tool))
+
+		var uses []string
+		for _, in := range s.Inputs {
+			if in.Step != nil {
+				uses = append(uses, fmt.Sprintf("[%d] %s", *in.Step, tools[*in.Step]))
+			} else {
+				uses = append(uses, fmt.Sprintf("input %s", in.Input))
+			}
+		}
+		if len(uses) > 0 {
+			b.WriteString("  ← " + strings.Join(uses, ", "))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,65 @@
+package pack
+
+import (
+	"fmt"
+
+	"github.com/contextsubstrate/ctx/internal/store"
+)
+
+// packKey loads the key a new pack is encrypted with: the one keyID names,
+// else the store's default encryption key. It returns nil when neither is set.
+func packKey(storeRoot, keyID string) (*store.Key, error) {
+	if keyID == "" {
+		cfg, err := store.LoadConfig(storeRoot)
+		if err != nil {
+			return nil, err
+		}
+		keyID = cfg.EncryptionKey
+	}
+	if keyID == "" {
+		return nil, nil
+	}
+	return store.LoadKey(storeRoot, keyID)
+}
+
+// PackKey returns the key an encrypted pack's content is sealed with, or nil
+// for an unencrypted pack. It fails with an error wrapping
+// store.ErrKeyUnavailable when this store lacks the key, so commands can
+// refuse an encrypted pack up front instead of failing on its first blob.
+func PackKey(storeRoot string, p *Pack) (*store.Key, error) {
+	if p.KeyID == "" {
+		return nil, nil
+	}
+	key, err := store.LoadKey(storeRoot, p.KeyID)
+	if err != nil {
+		return nil, fmt.Errorf("pack %s is encrypted: %w", store.ShortHash(p.Hash, 12), err)
+	}
+	return key, nil
+}
+
+// CheckKey reports an error if p, or any blob it references, is encrypted
+// with a key this store lacks. Blobs are checked as well as the pack's own
+// key because each blob is stored once: an unencrypted pack can share
+// content that an encrypted pack wrote first.
+func CheckKey(storeRoot string, p *Pack) error {
+	if _, err := PackKey(storeRoot, p); err != nil {
+		return err
+	}
+	checked := map[string]bool{p.KeyID: true}
+	for _, r := range p.BlobRefs() {
+		ids, err := store.BlobKeyIDs(storeRoot, r.Ref)
+		if err != nil {
+			continue // Missing or damaged blobs are reported when they are read
+		}
+		for _, id := range ids {
+			if checked[id] {
+				continue
+			}
+			if _, err := store.LoadKey(storeRoot, id); err != nil {
+				return fmt.Errorf("pack %s: %s is encrypted: %w", store.ShortHash(p.Hash, 12), r.Field, err)
+			}
+			checked[id] = true
+		}
+	}
+	return nil
+}
@@ -1,8 +1,8 @@
 package pack
 
 import (
-	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/contextsubstrate/ctx/internal/store"
 )
@@ -20,8 +20,13 @@ func LoadPack(storeRoot string, ref string) (*Pack, error) {
 		return nil, fmt.Errorf("pack not found: %s", store.ShortHash(normalized, 12))
 	}
 
+	data, err = upgradeManifest(data)
+	if err != nil {
+		return nil, fmt.Errorf("pack %s: %w", store.ShortHash(normalized, 12), err)
+	}
+
 	var p Pack
-	if err := json.Unmarshal(data, &p); err != nil {
+	if err := DecodeJSON(data, &p); err != nil {
 		return nil, fmt.Errorf("parsing pack manifest: %w", err)
 	}
 
@@ -40,33 +45,94 @@ func FormatPack(p *Pack) string {
 	if p.Parent != "" {
 		s += fmt.Sprintf("Parent:  %s\n", store.ShortHash(p.Parent, 12))
 	}
+	if p.KeyID != "" {
+		s += fmt.Sprintf("Key:     %s (encrypted)\n", p.KeyID)
+	}
 	s += fmt.Sprintf("\nSystem Prompt: %s\n", store.ShortHash(p.SystemPrompt, 12))
 
 	if len(p.Inputs) > 0 {
 		s += fmt.Sprintf("\nInputs (%d):\n", len(p.Inputs))
 		for _, inp := range p.Inputs {
-			s += fmt.Sprintf("  %s (%d bytes)\n", inp.Name, inp.Size)
+			if inp.MediaType != "" {
+				s += fmt.Sprintf("  %s (%s, %d bytes)\n", inp.Name, inp.MediaType, inp.Size)
+			} else {
+				s += fmt.Sprintf("  %s (%d bytes)\n", inp.Name, inp.Size)
+			}
+		}
+	}
+
+	if len(p.Agents) > 0 {
+		counts := p.AgentStepCounts()
+		s += fmt.Sprintf("\nAgents (%d):\n", len(p.Agents))
+		for _, a := range p.Agents {
+			model := a.Model.Identifier
+			if model == "" {
+				model = p.Model.Identifier + " (inherited)"
+			}
+			s += fmt.Sprintf("  %s  %s  %d step(s)", a.Name, model, counts[a.Name])
+			if a.SystemPrompt != "" {
+				s += fmt.Sprintf("  system prompt %s", store.ShortHash(a.SystemPrompt, 12))
+			}
+			s += "\n"
 		}
 	}
 
 	if len(p.Steps) > 0 {
 		s += fmt.Sprintf("\nSteps (%d):\n", len(p.Steps))
-		for _, step := range p.Steps {
+		for i, step := range p.Steps {
+			if i > 0 && step.Agent != p.Steps[i-1].Agent {
+				s += fmt.Sprintf("  ── handoff: %s → %s ──\n", agentLabel(p.Steps[i-1].Agent), agentLabel(step.Agent))
+			}
 			det := "deterministic"
 			if !step.Deterministic {
 				det = "non-deterministic"
 			}
-			s += fmt.Sprintf("  [%d] %s %s (%s)\n", step.Index, step.Type, step.Tool, det)
+			s += fmt.Sprintf("  [%d] %s %s (%s)", step.Index, step.Type, step.Tool, det)
+			if step.Agent != "" {
+				s += " @" + step.Agent
+			}
+			if step.ChildPack != "" {
+				s += fmt.Sprintf(" → sub-run %s", store.ShortHash(step.ChildPack, 12))
+			}
+			if step.OutputMediaType != "" {
+				s += fmt.Sprintf(" → %s", step.OutputMediaType)
+			}
+			s += stepUsageLabel(p, &p.Steps[i])
+			s += "\n"
 		}
 	}
 
+	if p.HasUsage() {
+		s += fmt.Sprintf("\nUsage:   %s\n", FormatUsage(p.TotalUsage()))
+		s += fmt.Sprintf("Latency: %s\n", p.TotalLatency())
+	}
+
+	if p.HasStepGraph() {
+		s += "\nStep Graph:\n"
+		s += FormatStepGraph(p)
+	}
+
 	if len(p.Outputs) > 0 {
 		s += fmt.Sprintf("\nOutputs (%d):\n", len(p.Outputs))
 		for _, out := range p.Outputs {
-			s += fmt.Sprintf("  %s\n", out.Name)
+			if out.MediaType != "" {
+				s += fmt.Sprintf("  %s (%s)\n", out.Name, out.MediaType)
+			} else {
+				s += fmt.Sprintf("  %s\n", out.Name)
+			}
 		}
 	}
 
+	if len(p.Redactions) > 0 {
+		s += fmt.Sprintf("\nRedactions (%d):\n", len(p.Redactions))
+		for _, r := range p.Redactions {
+			s += fmt.Sprintf("  %s  %s ×%d\n", r.Field, r.Detector, r.Count)
+		}
+	}
+	if len(p.Unscanned) > 0 {
+		s += fmt.Sprintf("\nNot scanned for redaction: %s\n", strings.Join(p.Unscanned, ", "))
+	}
+
 	s += fmt.Sprintf("\nEnvironment: %s / %s\n", p.Environment.OS, p.Environment.Runtime)
 	if len(p.Environment.ToolVersions) > 0 {
 		s += "Tool Versions:\n"
@@ -77,3 +143,12 @@ func FormatPack(p *Pack) string {
 
 	return s
 }
+
+// agentLabel names an agent in handoff markers; steps without an agent belong
+// to the top-level run.
+func agentLabel(name string) string {
+	if name == "" {
+		return "(run)"
+	}
+	return name
+}
@@ -17,6 +17,19 @@ type Pack struct {
 	Outputs      []Output    `json:"outputs"`
 	Environment  Environment `json:"environment"`
 	Parent       string      `json:"parent,omitempty"`
+	Agents       []Agent     `json:"agents,omitempty"`
+	Redactions   []Redaction `json:"redactions,omitempty"`
+	Unscanned    []string    `json:"unscanned,omitempty"`
+	KeyID        string      `json:"key_id,omitempty"`
+}
+
+// Agent is a named participant in a multi-agent run. SystemPrompt is a blob
+// reference; an empty model identifier or system prompt inherits the pack's.
+type Agent struct {
+	Name         string                 `json:"name"`
+	Model        Model                  `json:"model"`
+	SystemPrompt string                 `json:"system_prompt,omitempty"`
+	Parameters   map[string]interface{} `json:"parameters,omitempty"`
 }
 
 type Model struct {
@@ -33,21 +46,47 @@ type Input struct {
 	Name       string `json:"name"`
 	ContentRef string `json:"content_ref"`
 	Size       int64  `json:"size"`
+	MediaType  string `json:"media_type,omitempty"`
 }
 
 type Step struct {
-	Index         int                    `json:"index"`
-	Type          string                 `json:"type"`
-	Tool          string                 `json:"tool"`
-	Parameters    map[string]interface{} `json:"parameters"`
-	OutputRef     string                 `json:"output_ref"`
-	Deterministic bool                   `json:"deterministic"`
-	Timestamp     time.Time              `json:"timestamp"`
+	Index           int                    `json:"index"`
+	Type            string                 `json:"type"`
+	Tool            string                 `json:"tool"`
+	Parameters      map[string]interface{} `json:"parameters"`
+	OutputRef       string                 `json:"output_ref"`
+	OutputMediaType string                 `json:"output_media_type,omitempty"`
+	Deterministic   bool                   `json:"deterministic"`
+	Timestamp       time.Time              `json:"timestamp"`
+	OutputRedacted  bool                   `json:"output_redacted,omitempty"`
+	ParamsRedacted  bool                   `json:"parameters_redacted,omitempty"`
+	Inputs          []StepInput            `json:"inputs,omitempty"`
+	Agent           string                 `json:"agent,omitempty"`
+	ChildPack       string                 `json:"child_pack,omitempty"`
+	Model           string                 `json:"model,omitempty"`
+	Usage           *Usage                 `json:"usage,omitempty"`
+	LatencyMs       int64                  `json:"latency_ms,omitempty"`
+}
+
+// Usage counts the tokens consumed by a step or a whole pack. CachedTokens
+// are prompt tokens served from a cache and are not included in PromptTokens.
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	CachedTokens     int `json:"cached_tokens,omitempty"`
+}
+
+// StepInput references what a step consumed: the output of an earlier step
+// (by its index) or a pack input (by name).
+type StepInput struct {
+	Step  *int   `json:"step,omitempty"`
+	Input string `json:"input,omitempty"`
 }
 
 type Output struct {
 	Name        string `json:"name"`
 	ContentRef  string `json:"content_ref"`
+	MediaType   string `json:"media_type,omitempty"`
 	ContextPack string `json:"context_pack,omitempty"`
 }
 
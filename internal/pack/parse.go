@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"time"
 )
 
@@ -17,6 +18,16 @@ type ExecutionLog struct {
 	Steps        []LogStep      `json:"steps"`
 	Outputs      []LogOutput    `json:"outputs"`
 	Environment  LogEnvironment `json:"environment"`
+	Agents       []LogAgent     `json:"agents,omitempty"`
+}
+
+// LogAgent describes one named agent in a multi-agent run. An empty model
+// identifier or system prompt inherits the run-level value.
+type LogAgent struct {
+	Name         string                 `json:"name"`
+	Model        LogModel               `json:"model"`
+	SystemPrompt string                 `json:"system_prompt,omitempty"`
+	Parameters   map[string]interface{} `json:"parameters,omitempty"`
 }
 
 type LogModel struct {
@@ -29,24 +40,56 @@ type LogPrompt struct {
 	Content string `json:"content"`
 }
 
+// LogInput is a named input to the run. Its content is either inline or in
+// the file at Path, which is streamed into the store rather than loaded
+// whole. A relative path is resolved against the log file's directory.
+// Binary content is given inline with Encoding "base64".
 type LogInput struct {
-	Name    string `json:"name"`
-	Content string `json:"content"`
+	Name      string `json:"name"`
+	Content   string `json:"content,omitempty"`
+	Path      string `json:"path,omitempty"`
+	Encoding  string `json:"encoding,omitempty"`
+	MediaType string `json:"media_type,omitempty"`
 }
 
 type LogStep struct {
-	Index         int                    `json:"index"`
-	Type          string                 `json:"type"`
-	Tool          string                 `json:"tool"`
-	Parameters    map[string]interface{} `json:"parameters"`
-	Output        string                 `json:"output"`
-	Deterministic bool                   `json:"deterministic"`
-	Timestamp     time.Time              `json:"timestamp"`
+	Index           int                    `json:"index"`
+	Type            string                 `json:"type"`
+	Tool            string                 `json:"tool"`
+	Parameters      map[string]interface{} `json:"parameters"`
+	Output          string                 `json:"output"`
+	OutputEncoding  string                 `json:"output_encoding,omitempty"`
+	OutputMediaType string                 `json:"output_media_type,omitempty"`
+	Deterministic   bool                   `json:"deterministic"`
+	Timestamp       time.Time              `json:"timestamp"`
+	Inputs          []LogStepInput         `json:"inputs,omitempty"`
+	Agent           string                 `json:"agent,omitempty"`
+	ChildPack       string                 `json:"child_pack,omitempty"`
+	Model           string                 `json:"model,omitempty"`
+	Usage           *LogUsage              `json:"usage,omitempty"`
+	LatencyMs       int64                  `json:"latency_ms,omitempty"`
+}
+
+// LogUsage records the tokens a step consumed. CachedTokens are prompt tokens
+// served from a cache and are not included in PromptTokens.
+type LogUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	CachedTokens     int `json:"cached_tokens,omitempty"`
+}
+
+// LogStepInput names something a step consumed: the output of an earlier step
+// (by its index) or one of the log's inputs (by name). Exactly one is set.
+type LogStepInput struct {
+	Step  *int   `json:"step,omitempty"`
+	Input string `json:"input,omitempty"`
 }
 
 type LogOutput struct {
-	Name    string `json:"name"`
-	Content string `json:"content"`
+	Name      string `json:"name"`
+	Content   string `json:"content"`
+	Encoding  string `json:"encoding,omitempty"`
+	MediaType string `json:"media_type,omitempty"`
 }
 
 type LogEnvironment struct {
@@ -62,7 +105,17 @@ func ParseExecutionLog(path string) (*ExecutionLog, error) {
 		return nil, fmt.Errorf("opening log file: %w", err)
 	}
 	defer f.Close()
-	return ParseExecutionLogReader(f)
+
+	log, err := ParseExecutionLogReader(f)
+	if err != nil {
+		return nil, err
+	}
+	for i, inp := range log.Inputs {
+		if inp.Path != "" && !filepath.IsAbs(inp.Path) {
+			log.Inputs[i].Path = filepath.Join(filepath.Dir(path), inp.Path)
+		}
+	}
+	return log, nil
 }
 
 // ParseExecutionLogReader reads and parses a JSON execution log from a reader.
@@ -70,6 +123,7 @@ func ParseExecutionLogReader(r io.Reader) (*ExecutionLog, error) {
 	var log ExecutionLog
 	decoder := json.NewDecoder(r)
 	decoder.DisallowUnknownFields()
+	decoder.UseNumber()
 	if err := decoder.Decode(&log); err != nil {
 		return nil, fmt.Errorf("parsing execution log: %w", err)
 	}
@@ -106,6 +160,12 @@ func validateLog(log *ExecutionLog) error {
 		}
 	}
 
+	for i, inp := range log.Inputs {
+		if inp.Content != "" && inp.Path != "" {
+			return fmt.Errorf("invalid execution log: inputs[%d]: set either content or path, not both", i)
+		}
+	}
+
 	for i, output := range log.Outputs {
 		if output.Name == "" {
 			missing = append(missing, fmt.Sprintf("outputs[%d].name", i))
@@ -115,5 +175,92 @@ func validateLog(log *ExecutionLog) error {
 	if len(missing) > 0 {
 		return fmt.Errorf("invalid execution log: missing required fields: %v", missing)
 	}
+
+	if invalid := validateStepInputs(log); len(invalid) > 0 {
+		return fmt.Errorf("invalid execution log: bad step inputs: %v", invalid)
+	}
+	if invalid := validateAgents(log); len(invalid) > 0 {
+		return fmt.Errorf("invalid execution log: bad agents: %v", invalid)
+	}
+	if invalid := validateUsage(log); len(invalid) > 0 {
+		return fmt.Errorf("invalid execution log: bad step usage: %v", invalid)
+	}
+	if invalid := validateEncodings(log); len(invalid) > 0 {
+		return fmt.Errorf("invalid execution log: bad content encoding: %v", invalid)
+	}
 	return nil
 }
+
+// validateUsage rejects negative token counts and latencies.
+func validateUsage(log *ExecutionLog) []string {
+	var invalid []string
+	for i, step := range log.Steps {
+		if u := step.Usage; u != nil && (u.PromptTokens < 0 || u.CompletionTokens < 0 || u.CachedTokens < 0) {
+			invalid = append(invalid, fmt.Sprintf("steps[%d].usage: negative token count", i))
+		}
+		if step.LatencyMs < 0 {
+			invalid = append(invalid, fmt.Sprintf("steps[%d].latency_ms: negative latency", i))
+		}
+	}
+	return invalid
+}
+
+// validateAgents checks that agents have unique names and that every step is
+// attributed to a declared agent.
+func validateAgents(log *ExecutionLog) []string {
+	var invalid []string
+
+	names := make(map[string]bool)
+	for i, agent := range log.Agents {
+		switch {
+		case agent.Name == "":
+			invalid = append(invalid, fmt.Sprintf("agents[%d].name is required", i))
+		case names[agent.Name]:
+			invalid = append(invalid, fmt.Sprintf("agents[%d]: duplicate agent %q", i, agent.Name))
+		}
+		names[agent.Name] = true
+	}
+
+	for i, step := range log.Steps {
+		if step.Agent != "" && !names[step.Agent] {
+			invalid = append(invalid, fmt.Sprintf("steps[%d].agent: unknown agent %q", i, step.Agent))
+		}
+	}
+
+	return invalid
+}
+
+// validateStepInputs checks that every step input reference names either a
+// log input or a step that appears earlier in the log, so the references form a DAG.
+func validateStepInputs(log *ExecutionLog) []string {
+	var invalid []string
+
+	inputNames := make(map[string]bool)
+	for _, inp := range log.Inputs {
+		inputNames[inp.Name] = true
+	}
+
+	earlier := make(map[int]bool)
+	for i, step := range log.Steps {
+		for j, ref := range step.Inputs {
+			field := fmt.Sprintf("steps[%d].inputs[%d]", i, j)
+			switch {
+			case ref.Step != nil && ref.Input != "":
+				invalid = append(invalid, field+": set either step or input, not both")
+			case ref.Step != nil:
+				if !earlier[*ref.Step] {
+					invalid = append(invalid, fmt.Sprintf("%s: step %d does not precede this step", field, *ref.Step))
+				}
+			case ref.Input != "":
+				if !inputNames[ref.Input] {
+					invalid = append(invalid, fmt.Sprintf("%s: unknown input %q", field, ref.Input))
+				}
+			default:
+				invalid = append(invalid, field+": empty reference")
+			}
+		}
+		earlier[step.Index] = true
+	}
+
+	return invalid
+}
@@ -0,0 +1,207 @@
+package pack
+
+import (
+	"fmt"
+	"slices"
+	"unicode/utf8"
+
+	"github.com/contextsubstrate/ctx/internal/redact"
+	"github.com/contextsubstrate/ctx/internal/store"
+)
+
+// Redaction records that a detector replaced values in one manifest field.
+// Field uses the same names as BlobRefs, plus "parameters" fields for tool,
+// model and agent parameters.
+type Redaction struct {
+	Field    string `json:"field"`
+	Detector string `json:"detector"`
+	Count    int    `json:"count"`
+}
+
+// redactionResult records what redactLog replaced, and the fields it could
+// not scan: base64 content and inputs streamed from a path.
+type redactionResult struct {
+	Redactions []Redaction
+	Unscanned  []string
+}
+
+// redacted reports whether any values in the named field were redacted.
+func (r *redactionResult) redacted(field string) bool {
+	return hasRedaction(r.Redactions, field)
+}
+
+func hasRedaction(redactions []Redaction, field string) bool {
+	for _, r := range redactions {
+		if r.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// loadRedactor returns the store's configured Redactor, or nil if redaction
+// is disabled.
+func loadRedactor(storeRoot string) (*redact.Redactor, error) {
+	cfg, err := store.LoadConfig(storeRoot)
+	if err != nil {
+		return nil, err
+	}
+	return redact.New(storeRoot, cfg.Redaction)
+}
+
+// redactLog returns a copy of log with secrets and personal data replaced by
+// placeholders, per the store's redaction config, and a record of what was
+// replaced and what could not be scanned.
+func redactLog(storeRoot string, log *ExecutionLog) (*ExecutionLog, *redactionResult, error) {
+	r, err := loadRedactor(storeRoot)
+	if err != nil {
+		return nil, nil, err
+	}
+	result := &redactionResult{}
+	if r == nil {
+		return log, result, nil
+	}
+
+	record := func(field string, counts redact.Counts) {
+		for _, name := range counts.Names() {
+			result.Redactions = append(result.Redactions, Redaction{Field: field, Detector: name, Count: counts[name]})
+		}
+	}
+	text := func(field, s string) string {
+		counts := redact.Counts{}
+		s = r.Text(s, counts)
+		record(field, counts)
+		return s
+	}
+	params := func(field string, p map[string]interface{}) map[string]interface{} {
+		counts := redact.Counts{}
+		p = r.Parameters(p, counts)
+		record(field, counts)
+		return p
+	}
+	unscanned := func(field string) {
+		result.Unscanned = append(result.Unscanned, field)
+	}
+
+	out := *log
+	out.Model.Parameters = params("model.parameters", log.Model.Parameters)
+	out.SystemPrompt = text("system_prompt", log.SystemPrompt)
+
+	out.Prompts = make([]LogPrompt, len(log.Prompts))
+	for i, p := range log.Prompts {
+		p.Content = text(fmt.Sprintf("prompts[%d]", i), p.Content)
+		out.Prompts[i] = p
+	}
+
+	out.Inputs = make([]LogInput, len(log.Inputs))
+	for i, in := range log.Inputs {
+		field := fmt.Sprintf("inputs[%s]", in.Name)
+		if in.Path != "" || in.Encoding == EncodingBase64 {
+			unscanned(field)
+		} else {
+			in.Content = text(field, in.Content)
+		}
+		out.Inputs[i] = in
+	}
+
+	out.Steps = make([]LogStep, len(log.Steps))
+	for i, s := range log.Steps {
+		s.Parameters = params(fmt.Sprintf("steps[%d].parameters", i), s.Parameters)
+		field := fmt.Sprintf("steps[%d].output_ref", i)
+		if s.OutputEncoding == EncodingBase64 {
+			unscanned(field)
+		} else {
+			s.Output = text(field, s.Output)
+		}
+		out.Steps[i] = s
+	}
+
+	out.Outputs = make([]LogOutput, len(log.Outputs))
+	for i, o := range log.Outputs {
+		field := fmt.Sprintf("outputs[%s]", o.Name)
+		if o.Encoding == EncodingBase64 {
+			unscanned(field)
+		} else {
+			o.Content = text(field, o.Content)
+		}
+		out.Outputs[i] = o
+	}
+
+	if log.Agents != nil {
+		out.Agents = make([]LogAgent, len(log.Agents))
+		for i, a := range log.Agents {
+			a.SystemPrompt = text(fmt.Sprintf("agents[%s].system_prompt", a.Name), a.SystemPrompt)
+			a.Parameters = params(fmt.Sprintf("agents[%s].parameters", a.Name), a.Parameters)
+			a.Model.Parameters = params(fmt.Sprintf("agents[%s].model.parameters", a.Name), a.Model.Parameters)
+			out.Agents[i] = a
+		}
+	}
+	return &out, result, nil
+}
+
+// RedactContent applies the store's redaction to content that replaces the
+// named field of p, such as a draft edit, and replaces p's redaction records
+// for the field to match. Content that is not UTF-8 text is not scanned and
+// the field is listed as unscanned.
+func (p *Pack) RedactContent(storeRoot, field string, content []byte) ([]byte, error) {
+	r, err := loadRedactor(storeRoot)
+	if err != nil {
+		return nil, err
+	}
+	p.Redactions = slices.DeleteFunc(p.Redactions, func(red Redaction) bool { return red.Field == field })
+	p.Unscanned = slices.DeleteFunc(p.Unscanned, func(f string) bool { return f == field })
+	if r == nil {
+		return content, nil
+	}
+	if !utf8.Valid(content) {
+		p.Unscanned = append(p.Unscanned, field)
+		return content, nil
+	}
+	counts := redact.Counts{}
+	out := []byte(r.Text(string(content), counts))
+	p.addRedactions(field, counts)
+	return out, nil
+}
+
+// RedactParameter applies the store's redaction to a new value for one key
+// of the named parameters field of p, and adds what it replaced to p's
+// redaction records for the field.
+func (p *Pack) RedactParameter(storeRoot, field, key string, value interface{}) (interface{}, error) {
+	r, err := loadRedactor(storeRoot)
+	if err != nil || r == nil {
+		return value, err
+	}
+	counts := redact.Counts{}
+	out := r.Parameters(map[string]interface{}{key: value}, counts)
+	p.addRedactions(field, counts)
+	return out[key], nil
+}
+
+// addRedactions adds detector counts to p's redaction records for field.
+func (p *Pack) addRedactions(field string, counts redact.Counts) {
+	for _, name := range counts.Names() {
+		i := slices.IndexFunc(p.Redactions, func(red Redaction) bool { return red.Field == field && red.Detector == name })
+		if i >= 0 {
+			p.Redactions[i].Count += counts[name]
+		} else {
+			p.Redactions = append(p.Redactions, Redaction{Field: field, Detector: name, Count: counts[name]})
+		}
+	}
+}
+
+// Redacted reports whether any values in the named field were redacted.
+func (p *Pack) Redacted(field string) bool {
+	return hasRedaction(p.Redactions, field)
+}
+
+// RedactOutput applies the store's current redaction to a step output, so
+// output produced by replaying a step can be compared with a recorded
+// output that was redacted when packed. It reports false if redaction is
+// now disabled, in which case the two cannot be compared.
+func RedactOutput(storeRoot string, output []byte) ([]byte, bool, error) {
+	r, err := loadRedactor(storeRoot)
+	if err != nil || r == nil {
+		return nil, false, err
+	}
+	return []byte(r.Text(string(output), redact.Counts{})), true, nil
+}
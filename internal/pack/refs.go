@@ -0,0 +1,40 @@
+package pack
+
+import "fmt"
+
+// BlobRef is a content reference held by a manifest, with the field it came
+// from for error messages.
+type BlobRef struct {
+	Field string
+	Ref   string
+}
+
+// BlobRefs returns every blob the manifest references: prompts, inputs,
+// step outputs, outputs, agent system prompts and sub-run packs. Steps with
+// no output have no output ref and contribute none.
+func (p *Pack) BlobRefs() []BlobRef {
+	refs := []BlobRef{{"system_prompt", p.SystemPrompt}}
+	for i, pr := range p.Prompts {
+		refs = append(refs, BlobRef{fmt.Sprintf("prompts[%d]", i), pr.ContentRef})
+	}
+	for _, in := range p.Inputs {
+		refs = append(refs, BlobRef{fmt.Sprintf("inputs[%s]", in.Name), in.ContentRef})
+	}
+	for i, s := range p.Steps {
+		if s.OutputRef != "" {
+			refs = append(refs, BlobRef{fmt.Sprintf("steps[%d].output_ref", i), s.OutputRef})
+		}
+		if s.ChildPack != "" {
+			refs = append(refs, BlobRef{fmt.Sprintf("steps[%d].child_pack", i), s.ChildPack})
+		}
+	}
+	for _, out := range p.Outputs {
+		refs = append(refs, BlobRef{fmt.Sprintf("outputs[%s]", out.Name), out.ContentRef})
+	}
+	for _, a := range p.Agents {
+		if a.SystemPrompt != "" {
+			refs = append(refs, BlobRef{fmt.Sprintf("agents[%s].system_prompt", a.Name), a.SystemPrompt})
+		}
+	}
+	return refs
+}
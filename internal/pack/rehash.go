@@ -0,0 +1,102 @@
+package pack
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/contextsubstrate/ctx/internal/store"
+)
+
+// Rehash records a registered pack that was re-stored under its canonical
+// hash.
+type Rehash struct {
+	Old string
+	New string
+}
+
+// RehashPacks migrates registered packs whose hash is not the hash of their
+// canonical encoding, as happens for packs finalized before manifests were
+// canonicalized. Each is stored in canonical form and registered under its
+// new hash; the old registration is removed but the old manifest blob is
+// kept, so full old hashes still load. Packs that point at a migrated pack
+// as their parent or as a step's sub-run are rewritten to the new hash,
+// which migrates them in turn.
+func RehashPacks(storeRoot string) ([]Rehash, error) {
+	hashes, err := RegisteredPacks(storeRoot)
+	if err != nil {
+		return nil, err
+	}
+	var packs []*Pack
+	for _, h := range hashes {
+		p, err := LoadPack(storeRoot, h)
+		if err != nil {
+			continue // Unreadable manifests are left for fsck to report
+		}
+		packs = append(packs, p)
+	}
+
+	// Renaming one pack can rename the packs that refer to it, so repeat
+	// until nothing changes. References only point at older packs, so this
+	// settles after at most one pass per pack.
+	renamed := make(map[string]string)
+	for pass := 0; pass <= len(packs); pass++ {
+		changed := false
+		for _, p := range packs {
+			h, err := CanonicalHash(rewriteRefs(p, renamed))
+			if err != nil {
+				return nil, fmt.Errorf("hashing %s: %w", store.ShortHash(p.Hash, 12), err)
+			}
+			if h != p.Hash && renamed[p.Hash] != h {
+				renamed[p.Hash] = h
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+
+	var done []Rehash
+	for _, p := range packs {
+		newHash, ok := renamed[p.Hash]
+		if !ok {
+			continue
+		}
+		data, err := MarshalCanonical(rewriteRefs(p, renamed))
+		if err != nil {
+			return nil, err
+		}
+		if _, err := store.WriteBlob(storeRoot, data); err != nil {
+			return nil, fmt.Errorf("storing %s: %w", store.ShortHash(newHash, 12), err)
+		}
+		if err := RegisterPack(storeRoot, newHash); err != nil && !os.IsExist(err) {
+			return nil, fmt.Errorf("registering %s: %w", store.ShortHash(newHash, 12), err)
+		}
+		_, oldHex, _ := store.ParseHash(p.Hash)
+		if err := os.Remove(filepath.Join(storeRoot, "packs", oldHex)); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("unregistering %s: %w", store.ShortHash(p.Hash, 12), err)
+		}
+		done = append(done, Rehash{Old: p.Hash, New: newHash})
+	}
+	sort.Slice(done, func(i, j int) bool { return done[i].Old < done[j].Old })
+	return done, nil
+}
+
+// rewriteRefs returns a copy of p with its parent and sub-run references
+// replaced according to renamed.
+func rewriteRefs(p *Pack, renamed map[string]string) *Pack {
+	q := *p
+	if h, ok := renamed[q.Parent]; ok {
+		q.Parent = h
+	}
+	q.Steps = make([]Step, len(p.Steps))
+	copy(q.Steps, p.Steps)
+	for i := range q.Steps {
+		if h, ok := renamed[q.Steps[i].ChildPack]; ok {
+			q.Steps[i].ChildPack = h
+		}
+	}
+	return &q
+}
@@ -0,0 +1,76 @@
+package pack
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Total returns the total number of tokens counted.
+func (u Usage) Total() int {
+	return u.PromptTokens + u.CompletionTokens + u.CachedTokens
+}
+
+// Add accumulates another usage into u.
+func (u *Usage) Add(other Usage) {
+	u.PromptTokens += other.PromptTokens
+	u.CompletionTokens += other.CompletionTokens
+	u.CachedTokens += other.CachedTokens
+}
+
+// HasUsage reports whether any step recorded token usage or latency.
+func (p *Pack) HasUsage() bool {
+	for _, s := range p.Steps {
+		if s.Usage != nil || s.LatencyMs > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// TotalUsage sums the token usage of all steps.
+func (p *Pack) TotalUsage() Usage {
+	var total Usage
+	for _, s := range p.Steps {
+		if s.Usage != nil {
+			total.Add(*s.Usage)
+		}
+	}
+	return total
+}
+
+// TotalLatency sums the recorded latency of all steps.
+func (p *Pack) TotalLatency() time.Duration {
+	var ms int64
+	for _, s := range p.Steps {
+		ms += s.LatencyMs
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// FormatUsage renders token counts as "N tokens (P prompt, C completion, K cached)".
+func FormatUsage(u Usage) string {
+	s := fmt.Sprintf("%d tokens (%d prompt, %d completion", u.Total(), u.PromptTokens, u.CompletionTokens)
+	if u.CachedTokens > 0 {
+		s += fmt.Sprintf(", %d cached", u.CachedTokens)
+	}
+	return s + ")"
+}
+
+// stepUsageLabel renders a step's model, token counts and latency for FormatPack.
+func stepUsageLabel(p *Pack, s *Step) string {
+	var parts []string
+	if s.Model != "" {
+		parts = append(parts, p.StepModel(s).Identifier)
+	}
+	if s.Usage != nil {
+		parts = append(parts, fmt.Sprintf("%d→%d tokens", s.Usage.PromptTokens+s.Usage.CachedTokens, s.Usage.CompletionTokens))
+	}
+	if s.LatencyMs > 0 {
+		parts = append(parts, (time.Duration(s.LatencyMs) * time.Millisecond).String())
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "  [" + strings.Join(parts, ", ") + "]"
+}
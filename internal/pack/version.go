@@ -0,0 +1,92 @@
+package pack
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/contextsubstrate/ctx/internal/store"
+)
+
+// ManifestVersion is the manifest format version written by this build.
+//
+//   - 0.1: the original format. Manifests were hashed over Go's JSON encoding,
+//     and a missing parameter or tool version map was stored as null.
+//   - 0.2: manifests are stored and hashed as RFC 8785 canonical JSON, and
+//     parameters and tool versions are always objects.
+const ManifestVersion = "0.2"
+
+// manifestUpgrades maps each older manifest version to the function that
+// rewrites a raw manifest from it to the next version, returning that
+// version. LoadPack chains them until the manifest reaches ManifestVersion.
+// An upgraded manifest no longer hashes to its pack hash until it is
+// re-stored by ctx migrate or ctx fsck --rehash.
+var manifestUpgrades = map[string]func(m map[string]interface{}) (string, error){
+	"0.1": upgradeFrom01,
+}
+
+// upgradeFrom01 replaces the null parameter and tool version maps that 0.1
+// stored for steps and environments without them.
+func upgradeFrom01(m map[string]interface{}) (string, error) {
+	if model, ok := m["model"].(map[string]interface{}); ok {
+		emptyIfNull(model, "parameters")
+	}
+	if env, ok := m["environment"].(map[string]interface{}); ok {
+		emptyIfNull(env, "tool_versions")
+	}
+	steps, _ := m["steps"].([]interface{})
+	for i, s := range steps {
+		step, ok := s.(map[string]interface{})
+		if !ok {
+			return "", fmt.Errorf("steps[%d] is not an object", i)
+		}
+		emptyIfNull(step, "parameters")
+	}
+	return "0.2", nil
+}
+
+// emptyIfNull sets obj[key] to an empty object if it is missing or null.
+func emptyIfNull(obj map[string]interface{}, key string) {
+	if obj[key] == nil {
+		obj[key] = map[string]interface{}{}
+	}
+}
+
+// upgradeManifest brings raw manifest JSON up to ManifestVersion. Manifests
+// from a newer version of ctx are rejected rather than misread.
+func upgradeManifest(data []byte) ([]byte, error) {
+	var header struct {
+		Version string `json:"version"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, fmt.Errorf("parsing pack manifest: %w", err)
+	}
+	if header.Version == ManifestVersion {
+		return data, nil
+	}
+
+	cmp, err := store.CompareVersions(header.Version, ManifestVersion)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported manifest version %q", header.Version)
+	}
+	if cmp > 0 {
+		return nil, fmt.Errorf("manifest version %s is newer than this build of ctx supports (%s); upgrade ctx", header.Version, ManifestVersion)
+	}
+
+	var m map[string]interface{}
+	if err := DecodeJSON(data, &m); err != nil {
+		return nil, fmt.Errorf("parsing pack manifest: %w", err)
+	}
+	for v := header.Version; v != ManifestVersion; {
+		upgrade, ok := manifestUpgrades[v]
+		if !ok {
+			return nil, fmt.Errorf("unsupported manifest version %q", v)
+		}
+		next, err := upgrade(m)
+		if err != nil {
+			return nil, fmt.Errorf("upgrading manifest from version %s: %w", v, err)
+		}
+		m["version"] = next
+		v = next
+	}
+	return json.Marshal(m)
+}
@@ -0,0 +1,269 @@
+// Package redact replaces secrets and personal data in text with stable
+// placeholders before it is written to the store.
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/contextsubstrate/ctx/internal/store"
+)
+
+// Detector finds one kind of sensitive value.
+type Detector struct {
+	Name    string
+	Pattern *regexp.Regexp
+}
+
+// SensitiveParameter names the built-in detector that redacts the whole
+// string value of any parameter whose key looks like a credential.
+const SensitiveParameter = "sensitive_parameter"
+
+// sensitiveWords are the words that mark a parameter key as a credential.
+// Keys are matched word by word, so "access_token" and "apiKey" match but
+// "max_tokens" and "tokenizer" do not.
+var sensitiveWords = map[string]bool{
+	"password": true, "passwd": true, "secret": true, "token": true,
+	"apikey": true, "authorization": true, "credential": true, "credentials": true,
+}
+
+// sensitiveKey reports whether a parameter key names a credential.
+func sensitiveKey(key string) bool {
+	words := keyWords(key)
+	for i, w := range words {
+		if sensitiveWords[w] || (w == "api" && i+1 < len(words) && words[i+1] == "key") {
+			return true
+		}
+	}
+	return false
+}
+
+// keyWords splits a snake_case, kebab-case or camelCase key into lowercase
+// words.
+func keyWords(key string) []string {
+	var words []string
+	var word []rune
+	runes := []rune(key)
+	flush := func() {
+		if len(word) > 0 {
+			words = append(words, strings.ToLower(string(word)))
+			word = word[:0]
+		}
+	}
+	for i, r := range runes {
+		switch {
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r):
+			flush()
+			continue
+		case unicode.IsUpper(r) && i > 0:
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				flush()
+			}
+		}
+		word = append(word, r)
+	}
+	flush()
+	return words
+}
+
+// Builtin lists the built-in detectors in the order they run. Private keys
+// and tokens run before emails so a key containing an @ is taken whole.
+var Builtin = []Detector{
+	{"private_key", regexp.MustCompile(`(?s)-----BEGIN [A-Z0-9 ]*PRIVATE KEY-----.*?-----END [A-Z0-9 ]*PRIVATE KEY-----`)},
+	{"jwt", regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`)},
+	{"aws_access_key", regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"github_token", regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})`)},
+	{"slack_token", regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}`)},
+	{"stripe_key", regexp.MustCompile(`\b[rs]k_(?:live|test)_[A-Za-z0-9]{16,}`)},
+	{"google_api_key", regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}`)},
+	{"sk_api_key", regexp.MustCompile(`\bsk-[A-Za-z0-9_-]{20,}`)},
+	{"email", regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`)},
+}
+
+// Redactor replaces detected values with placeholders of the form
+// [REDACTED:<detector>:<id>], where the id is derived from the value with a
+// per-store key. The same value always gets the same placeholder in one
+// store, so redacted packs still diff meaningfully, but the placeholder
+// cannot be reversed by guessing values without the key.
+type Redactor struct {
+	detectors  []Detector
+	parameters bool
+	key        []byte
+}
+
+// Counts records how many values each detector replaced.
+type Counts map[string]int
+
+// New returns a Redactor for a store's redaction settings, or nil if
+// redaction is disabled.
+func New(storeRoot string, cfg *store.RedactionConfig) (*Redactor, error) {
+	if cfg == nil {
+		cfg = &store.RedactionConfig{}
+	}
+	if cfg.Disabled {
+		return nil, nil
+	}
+
+	skip := make(map[string]bool)
+	for _, name := range cfg.Disable {
+		if name != SensitiveParameter && !isBuiltin(name) {
+			return nil, fmt.Errorf("redaction: unknown detector %q", name)
+		}
+		skip[name] = true
+	}
+	r := &Redactor{parameters: !skip[SensitiveParameter]}
+	for _, d := range Builtin {
+		if !skip[d.Name] {
+			r.detectors = append(r.detectors, d)
+		}
+	}
+	for _, rule := range cfg.Rules {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("redaction: rule with pattern %q has no name", rule.Pattern)
+		}
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("redaction: rule %q: %w", rule.Name, err)
+		}
+		r.detectors = append(r.detectors, Detector{Name: rule.Name, Pattern: re})
+	}
+
+	key, err := storeKey(storeRoot)
+	if err != nil {
+		return nil, err
+	}
+	r.key = key
+	return r, nil
+}
+
+func isBuiltin(name string) bool {
+	for _, d := range Builtin {
+		if d.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Text redacts every detected value in s.
+func (r *Redactor) Text(s string, counts Counts) string {
+	for _, d := range r.detectors {
+		s = d.Pattern.ReplaceAllStringFunc(s, func(match string) string {
+			counts[d.Name]++
+			return r.placeholder(d.Name, match)
+		})
+	}
+	return s
+}
+
+// Parameters returns a copy of tool or model parameters with string values
+// redacted, recursively. String values under credential-like keys are
+// replaced whole.
+func (r *Redactor) Parameters(params map[string]interface{}, counts Counts) map[string]interface{} {
+	if params == nil {
+		return nil
+	}
+	out := make(map[string]interface{}, len(params))
+	for k, v := range params {
+		if s, ok := v.(string); ok && r.parameters && s != "" && sensitiveKey(k) {
+			counts[SensitiveParameter]++
+			out[k] = r.placeholder(SensitiveParameter, s)
+			continue
+		}
+		out[k] = r.value(v, counts)
+	}
+	return out
+}
+
+func (r *Redactor) value(v interface{}, counts Counts) interface{} {
+	switch v := v.(type) {
+	case string:
+		return r.Text(v, counts)
+	case map[string]interface{}:
+		return r.Parameters(v, counts)
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, e := range v {
+			out[i] = r.value(e, counts)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+func (r *Redactor) placeholder(detector, value string) string {
+	mac := hmac.New(sha256.New, r.key)
+	mac.Write([]byte(value))
+	return fmt.Sprintf("[REDACTED:%s:%s]", detector, hex.EncodeToString(mac.Sum(nil))[:8])
+}
+
+// Names returns the detectors with a nonzero count, sorted.
+func (c Counts) Names() []string {
+	var names []string
+	for name, n := range c {
+		if n > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// KeyFile is the file holding a store's redaction key, relative to the store.
+const KeyFile = "redaction.key"
+
+// storeKey returns the store's redaction key, creating it on first use.
+func storeKey(storeRoot string) ([]byte, error) {
+	path := filepath.Join(storeRoot, KeyFile)
+	data, err := os.ReadFile(path)
+	if err == nil {
+		key, err := hex.DecodeString(string(data))
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("redaction: %s is not a valid key", KeyFile)
+		}
+		return key, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("reading redaction key: %w", err)
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("generating redaction key: %w", err)
+	}
+	// Write the key in full before linking it into place, so a concurrent
+	// reader never sees a partial key and a concurrent writer cannot replace
+	// one that is already in use.
+	f, err := os.CreateTemp(storeRoot, KeyFile+".*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("writing redaction key: %w", err)
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp)
+	if _, err := f.WriteString(hex.EncodeToString(key)); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("writing redaction key: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return nil, fmt.Errorf("writing redaction key: %w", err)
+	}
+	if err := os.Link(tmp, path); err != nil {
+		if os.IsExist(err) {
+			return storeKey(storeRoot) // Created concurrently
+		}
+		return nil, fmt.Errorf("writing redaction key: %w", err)
+	}
+	return key, nil
+}
@@ -0,0 +1,271 @@
+package replay
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/contextsubstrate/ctx/internal/pack"
+	"github.com/contextsubstrate/ctx/internal/store"
+)
+
+// DefaultBatchWorkers is the number of packs replayed concurrently when no limit is given.
+const DefaultBatchWorkers = 4
+
+// PackSelector chooses which registered packs take part in a batch replay.
+// Zero-valued fields do not filter, and Since and Until are inclusive. When
+// HashFile is set, only the packs it lists are considered; otherwise every
+// registered pack is a candidate.
+type PackSelector struct {
+	Model    string
+	Since    time.Time
+	Until    time.Time
+	HashFile string
+}
+
+// BatchEntry records the outcome of replaying a single pack in a batch.
+type BatchEntry struct {
+	PackHash string        `json:"pack_hash"`
+	Model    string        `json:"model,omitempty"`
+	Fidelity FidelityLevel `json:"fidelity,omitempty"`
+	Matched  int           `json:"matched"`
+	Diverged int           `json:"diverged"`
+	Failed   int           `json:"failed"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+	steps    []StepResult
+}
+
+// ToolDivergence counts how often a tool diverged or failed across a batch.
+type ToolDivergence struct {
+	Tool     string `json:"tool"`
+	Diverged int    `json:"diverged"`
+	Failed   int    `json:"failed"`
+	Packs    int    `json:"packs"`
+}
+
+// BatchReport aggregates replay results across many packs.
+type BatchReport struct {
+	Packs     []BatchEntry     `json:"packs"`
+	Exact     int              `json:"exact"`
+	Degraded  int              `json:"degraded"`
+	Failed    int              `json:"failed"`
+	Errors    int              `json:"errors"`
+	Tools     []ToolDivergence `json:"tools,omitempty"`
+	StartTime time.Time        `json:"start_time"`
+	EndTime   time.Time        `json:"end_time"`
+}
+
+// SelectPacks resolves a selector to a sorted list of full pack hashes.
+func SelectPacks(storeRoot string, sel PackSelector) ([]string, error) {
+	var candidates []string
+	if sel.HashFile != "" {
+		refs, err := readHashFile(sel.HashFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, ref := range refs {
+			hash, err := store.ResolveHash(storeRoot, ref)
+			if err != nil {
+				return nil, fmt.Errorf("resolving %q: %w", ref, err)
+			}
+			candidates = append(candidates, hash)
+		}
+	} else {
+		registered, err := pack.RegisteredPacks(storeRoot)
+		if err != nil {
+			return nil, err
+		}
+		candidates = registered
+	}
+
+	filtering := sel.Model != "" || !sel.Since.IsZero() || !sel.Until.IsZero()
+	seen := make(map[string]bool)
+	var selected []string
+	for _, hash := range candidates {
+		if seen[hash] {
+			continue
+		}
+		seen[hash] = true
+
+		if filtering {
+			p, err := pack.LoadPack(storeRoot, hash)
+			if err != nil {
+				continue // Skip corrupted packs
+			}
+			if sel.Model != "" && p.Model.Identifier != sel.Model {
+				continue
+			}
+			if !sel.Since.IsZero() && p.Created.Before(sel.Since) {
+				continue
+			}
+			if !sel.Until.IsZero() && p.Created.After(sel.Until) {
+				continue
+			}
+		}
+		selected = append(selected, hash)
+	}
+
+	sort.Strings(selected)
+	return selected, nil
+}
+
+// readHashFile reads pack references from a file, one per line.
+// Blank lines and lines starting with '#' are ignored.
+func readHashFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening pack list: %w", err)
+	}
+	defer f.Close()
+
+	var refs []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		refs = append(refs, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading pack list: %w", err)
+	}
+	return refs, nil
+}
+
+// ReplayBatch replays the given packs concurrently, running at most workers
+// replays at a time, and aggregates their fidelity into a single report.
+// A pack that cannot be loaded is recorded with an error rather than aborting the batch.
+func ReplayBatch(storeRoot string, hashes []string, workers int) *BatchReport {
+	if workers <= 0 {
+		workers = DefaultBatchWorkers
+	}
+
+	report := &BatchReport{
+		Packs:     make([]BatchEntry, len(hashes)),
+		StartTime: time.Now(),
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				report.Packs[i] = replayEntry(storeRoot, hashes[i])
+			}
+		}()
+	}
+	for i := range hashes {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	report.aggregate()
+	report.EndTime = time.Now()
+	return report
+}
+
+func replayEntry(storeRoot string, hash string) BatchEntry {
+	start := time.Now()
+	entry := BatchEntry{PackHash: hash}
+
+	r, err := Replay(storeRoot, hash)
+	if err != nil {
+		entry.Error = err.Error()
+		entry.Duration = time.Since(start)
+		return entry
+	}
+
+	entry.Fidelity = r.Fidelity
+	entry.steps = r.Steps
+	for _, s := range r.Steps {
+		switch s.Status {
+		case StepMatched:
+			entry.Matched++
+		case StepDiverged:
+			entry.Diverged++
+		case StepFailed:
+			entry.Failed++
+		}
+	}
+	if p, err := pack.LoadPack(storeRoot, hash); err == nil {
+		entry.Model = p.Model.Identifier
+	}
+	entry.Duration = time.Since(start)
+	return entry
+}
+
+// aggregate fills in fidelity totals and the per-tool divergence ranking.
+func (r *BatchReport) aggregate() {
+	tools := make(map[string]*ToolDivergence)
+	for _, e := range r.Packs {
+		if e.Error != "" {
+			r.Errors++
+			continue
+		}
+		switch e.Fidelity {
+		case FidelityExact:
+			r.Exact++
+		case FidelityDegraded:
+			r.Degraded++
+		case FidelityFailed:
+			r.Failed++
+		}
+
+		// Only count divergence that affects fidelity: failures, and
+		// deterministic steps whose output changed.
+		inPack := make(map[string]bool)
+		for _, s := range e.steps {
+			if s.Status == StepMatched || s.Status == StepUnverifiable || (s.Status == StepDiverged && !s.Deterministic) {
+				continue
+			}
+			td, ok := tools[s.Tool]
+			if !ok {
+				td = &ToolDivergence{Tool: s.Tool}
+				tools[s.Tool] = td
+			}
+			if s.Status == StepFailed {
+				td.Failed++
+			} else {
+				td.Diverged++
+			}
+			if !inPack[s.Tool] {
+				inPack[s.Tool] = true
+				td.Packs++
+			}
+		}
+	}
+
+	r.Tools = make([]ToolDivergence, 0, len(tools))
+	for _, td := range tools {
+		r.Tools = append(r.Tools, *td)
+	}
+	sort.Slice(r.Tools, func(i, j int) bool {
+		ti, tj := r.Tools[i], r.Tools[j]
+		if ti.Diverged+ti.Failed != tj.Diverged+tj.Failed {
+			return ti.Diverged+ti.Failed > tj.Diverged+tj.Failed
+		}
+		return ti.Tool < tj.Tool
+	})
+}
+
+// Fidelity returns the worst fidelity level observed across the batch.
+// Packs that could not be replayed at all count as failed.
+func (r *BatchReport) Fidelity() FidelityLevel {
+	switch {
+	case r.Failed > 0 || r.Errors > 0:
+		return FidelityFailed
+	case r.Degraded > 0:
+		return FidelityDegraded
+	default:
+		return FidelityExact
+	}
+}
@@ -0,0 +1,180 @@
+package replay
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/contextsubstrate/ctx/internal/pack"
+)
+
+func createRegisteredPack(t *testing.T, root string, model string, steps []pack.LogStep) *pack.Pack {
+	t.Helper()
+	log := &pack.ExecutionLog{
+		Model:        pack.LogModel{Identifier: model, Parameters: map[string]interface{}{}},
+		SystemPrompt: "test prompt",
+		Prompts:      []pack.LogPrompt{{Role: "user", Content: "test"}},
+		Steps:        steps,
+		Outputs:      []pack.LogOutput{{Name: "out.txt", Content: "result"}},
+		Environment:  pack.LogEnvironment{OS: "darwin", Runtime: "go1.22", ToolVersions: map[string]string{}},
+	}
+	p, err := pack.CreatePack(root, log)
+	if err != nil {
+		t.Fatalf("CreatePack failed: %v", err)
+	}
+	if err := pack.RegisterPack(root, p.Hash); err != nil {
+		t.Fatalf("RegisterPack failed: %v", err)
+	}
+	return p
+}
+
+func readFileStep(path string, output string) pack.LogStep {
+	return pack.LogStep{
+		Index:         0,
+		Type:          "tool_call",
+		Tool:          "read_file",
+		Parameters:    map[string]interface{}{"path": path},
+		Output:        output,
+		Deterministic: true,
+	}
+}
+
+func TestSelectPacksAll(t *testing.T) {
+	root := setupTestStore(t)
+	a := createRegisteredPack(t, root, "model-a", nil)
+	b := createRegisteredPack(t, root, "model-b", nil)
+
+	hashes, err := SelectPacks(root, PackSelector{})
+	if err != nil {
+		t.Fatalf("SelectPacks failed: %v", err)
+	}
+	if len(hashes) != 2 {
+		t.Fatalf("expected 2 packs, got %d", len(hashes))
+	}
+	for _, want := range []string{a.Hash, b.Hash} {
+		found := false
+		for _, h := range hashes {
+			if h == want {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("pack %s not selected", want)
+		}
+	}
+}
+
+func TestSelectPacksByModel(t *testing.T) {
+	root := setupTestStore(t)
+	a := createRegisteredPack(t, root, "model-a", nil)
+	createRegisteredPack(t, root, "model-b", nil)
+
+	hashes, err := SelectPacks(root, PackSelector{Model: "model-a"})
+	if err != nil {
+		t.Fatalf("SelectPacks failed: %v", err)
+	}
+	if len(hashes) != 1 || hashes[0] != a.Hash {
+		t.Errorf("expected only %s, got %v", a.Hash, hashes)
+	}
+}
+
+func TestSelectPacksByDate(t *testing.T) {
+	root := setupTestStore(t)
+	p := createRegisteredPack(t, root, "model-a", nil)
+
+	hashes, err := SelectPacks(root, PackSelector{Since: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("SelectPacks failed: %v", err)
+	}
+	if len(hashes) != 0 {
+		t.Errorf("expected no packs created in the future, got %d", len(hashes))
+	}
+
+	hashes, err = SelectPacks(root, PackSelector{Since: time.Now().Add(-time.Hour), Until: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("SelectPacks failed: %v", err)
+	}
+	if len(hashes) != 1 {
+		t.Errorf("expected 1 pack in range, got %d", len(hashes))
+	}
+
+	// Both bounds are inclusive
+	hashes, _ = SelectPacks(root, PackSelector{Since: p.Created, Until: p.Created})
+	if len(hashes) != 1 {
+		t.Errorf("expected a pack created exactly at both bounds to be selected, got %d", len(hashes))
+	}
+}
+
+func TestSelectPacksFromFile(t *testing.T) {
+	root := setupTestStore(t)
+	a := createRegisteredPack(t, root, "model-a", nil)
+	createRegisteredPack(t, root, "model-b", nil)
+
+	listPath := filepath.Join(t.TempDir(), "golden.txt")
+	content := "# golden packs\n\n" + a.Hash[len("sha256:"):len("sha256:")+12] + "\n"
+	os.WriteFile(listPath, []byte(content), 0644)
+
+	hashes, err := SelectPacks(root, PackSelector{HashFile: listPath})
+	if err != nil {
+		t.Fatalf("SelectPacks failed: %v", err)
+	}
+	if len(hashes) != 1 || hashes[0] != a.Hash {
+		t.Errorf("expected only %s, got %v", a.Hash, hashes)
+	}
+}
+
+func TestReplayBatchAggregates(t *testing.T) {
+	root := setupTestStore(t)
+
+	testFile := filepath.Join(t.TempDir(), "test.txt")
+	os.WriteFile(testFile, []byte("hello"), 0644)
+
+	exact := createRegisteredPack(t, root, "model-a", []pack.LogStep{readFileStep(testFile, "hello")})
+	degraded := createRegisteredPack(t, root, "model-a", []pack.LogStep{readFileStep(testFile, "stale")})
+	failed := createRegisteredPack(t, root, "model-b", []pack.LogStep{
+		{Index: 0, Type: "tool_call", Tool: "web_search", Parameters: map[string]interface{}{}, Output: "x", Deterministic: true},
+	})
+
+	report := ReplayBatch(root, []string{exact.Hash, degraded.Hash, failed.Hash}, 2)
+
+	if len(report.Packs) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(report.Packs))
+	}
+	if report.Packs[0].PackHash != exact.Hash || report.Packs[0].Fidelity != FidelityExact {
+		t.Errorf("expected first entry exact for %s, got %+v", exact.Hash, report.Packs[0])
+	}
+	if report.Exact != 1 || report.Degraded != 1 || report.Failed != 1 {
+		t.Errorf("unexpected totals: exact=%d degraded=%d failed=%d", report.Exact, report.Degraded, report.Failed)
+	}
+	if report.Fidelity() != FidelityFailed {
+		t.Errorf("expected overall fidelity failed, got %s", report.Fidelity())
+	}
+	if len(report.Tools) != 2 {
+		t.Fatalf("expected 2 divergent tools, got %d", len(report.Tools))
+	}
+	if report.Tools[0].Tool != "read_file" || report.Tools[0].Diverged != 1 {
+		t.Errorf("unexpected tool ranking: %+v", report.Tools)
+	}
+	if report.Tools[1].Tool != "web_search" || report.Tools[1].Failed != 1 {
+		t.Errorf("unexpected tool ranking: %+v", report.Tools)
+	}
+}
+
+func TestReplayBatchMissingPack(t *testing.T) {
+	root := setupTestStore(t)
+	missing := "sha256:0000000000000000000000000000000000000000000000000000000000000000"
+
+	report := ReplayBatch(root, []string{missing}, 0)
+
+	if report.Errors != 1 {
+		t.Errorf("expected 1 error, got %d", report.Errors)
+	}
+	if report.Fidelity() != FidelityFailed {
+		t.Errorf("expected failed fidelity, got %s", report.Fidelity())
+	}
+	if !strings.Contains(report.Summary(), "error") {
+		t.Error("expected summary to mention the error")
+	}
+}
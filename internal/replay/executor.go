@@ -25,26 +25,65 @@ func DefaultExecutors() map[string]ToolExecutor {
 }
 
 // ExecuteStep re-executes a single tool call and compares the output.
+// Steps that delegated to a sub-run are replayed by replaying the child pack.
 func ExecuteStep(storeRoot string, step *pack.Step, executors map[string]ToolExecutor) *StepResult {
+	result, _ := runStep(storeRoot, step, executors)
+	return result
+}
+
+// runStep executes a step and returns both the comparison result and the
+// output as compared, which is nil when the tool could not be run or the
+// step was a sub-run. Output is redacted if the recorded output was.
+func runStep(storeRoot string, step *pack.Step, executors map[string]ToolExecutor) (*StepResult, []byte) {
 	result := &StepResult{
 		Index:         step.Index,
 		Tool:          step.Tool,
+		Agent:         step.Agent,
 		Deterministic: step.Deterministic,
 		ExpectedHash:  step.OutputRef,
 	}
 
+	if step.ChildPack != "" {
+		replayChild(storeRoot, step.ChildPack, result)
+		return result, nil
+	}
+
+	if step.ParamsRedacted {
+		result.Status = StepUnverifiable
+		result.Reason = "parameters were redacted when packed"
+		return result, nil
+	}
+
 	executor, ok := executors[step.Tool]
 	if !ok {
 		result.Status = StepFailed
 		result.Reason = fmt.Sprintf("tool not available: %s", step.Tool)
-		return result
+		return result, nil
 	}
 
 	output, err := executor(step.Tool, step.Parameters)
 	if err != nil {
 		result.Status = StepFailed
 		result.Reason = fmt.Sprintf("execution error: %v", err)
-		return result
+		return result, nil
+	}
+
+	// A recorded output redacted at pack time is compared with the actual
+	// output redacted the same way
+	if step.OutputRedacted {
+		redacted, ok, err := pack.RedactOutput(storeRoot, output)
+		if err != nil {
+			result.Status = StepFailed
+			result.Reason = fmt.Sprintf("redacting output: %v", err)
+			return result, nil
+		}
+		if !ok {
+			result.Status = StepUnverifiable
+			result.Reason = "output was redacted when packed and redaction is now disabled"
+			return result, output
+		}
+		output = redacted
+		result.Reason = "compared after redaction"
 	}
 
 	actualHash := store.HashContent(output)
@@ -56,5 +95,29 @@ func ExecuteStep(storeRoot string, step *pack.Step, executors map[string]ToolExe
 		result.Status = StepDiverged
 	}
 
-	return result
+	return result, output
+}
+
+// replayChild replays a sub-run pack and folds its fidelity into the parent
+// step's result. The child report already discounts non-deterministic
+// divergence, so a degraded child always counts against the parent.
+func replayChild(storeRoot string, childPack string, result *StepResult) {
+	child, err := Replay(storeRoot, childPack)
+	if err != nil {
+		result.Status = StepFailed
+		result.Reason = fmt.Sprintf("sub-run: %v", err)
+		return
+	}
+
+	result.Child = child
+	result.Reason = fmt.Sprintf("sub-run %s %s", store.ShortHash(childPack, 12), child.Fidelity)
+	switch child.Fidelity {
+	case FidelityExact:
+		result.Status = StepMatched
+	case FidelityDegraded:
+		result.Status = StepDiverged
+		result.Deterministic = true
+	default:
+		result.Status = StepFailed
+	}
 }
@@ -0,0 +1,238 @@
+package replay
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/contextsubstrate/ctx/internal/diff"
+	"github.com/contextsubstrate/ctx/internal/pack"
+	"github.com/contextsubstrate/ctx/internal/store"
+	"github.com/contextsubstrate/ctx/internal/textdiff"
+)
+
+// previewLines caps how much of a recorded output is printed before each step.
+const previewLines = 20
+
+// ReplayInteractive replays a pack one step at a time, pausing before each step
+// to let the user run it, skip it, accept the recorded output, or edit its
+// parameters. Prompts are written to out and commands read from in; reaching
+// the end of in stops the replay as if the user had quit.
+func ReplayInteractive(storeRoot string, packHash string, in io.Reader, out io.Writer) (*ReplayReport, error) {
+	p, err := pack.LoadPack(storeRoot, packHash)
+	if err != nil {
+		return nil, err
+	}
+	if err := pack.CheckKey(storeRoot, p); err != nil {
+		return nil, err
+	}
+
+	report := newReport(storeRoot, p)
+	executors := DefaultExecutors()
+	reader := bufio.NewReader(in)
+
+	fmt.Fprintf(out, "Interactive replay: %s (%d steps)\n", store.ShortHash(p.Hash, 12), len(p.Steps))
+	for _, d := range report.Drift {
+		fmt.Fprintf(out, "  drift: %s: %s\n", d.Type, d.Description)
+	}
+
+	failed := make(map[int]bool)
+	for i := range p.Steps {
+		step := p.Steps[i]
+		if dep, ok := blockedBy(&step, failed); ok {
+			failed[step.Index] = true
+			fmt.Fprintf(out, "\nStep %d/%d [%d] %s skipped: depends on failed step %d\n", i+1, len(p.Steps), step.Index, step.Tool, dep)
+			report.Steps = append(report.Steps, skippedResult(&step, fmt.Sprintf("depends on failed step %d", dep)))
+			continue
+		}
+
+		recorded, recordedErr := readRecordedOutput(storeRoot, &step)
+		printStep(out, i, len(p.Steps), &step, recorded, recordedErr)
+
+		result, quit := promptStep(storeRoot, reader, out, &step, executors, recorded)
+		if quit {
+			for j := range p.Steps[i:] {
+				report.Steps = append(report.Steps, skippedResult(&p.Steps[i+j], "replay stopped"))
+			}
+			break
+		}
+		if result.Status == StepFailed {
+			failed[step.Index] = true
+		}
+		report.Steps = append(report.Steps, *result)
+	}
+
+	report.finish()
+	return report, nil
+}
+
+// promptStep reads commands until the user decides what to do with a step.
+// It returns the step result, or quit=true if the user stopped the replay.
+func promptStep(storeRoot string, reader *bufio.Reader, out io.Writer, step *pack.Step, executors map[string]ToolExecutor, recorded []byte) (*StepResult, bool) {
+	edited := false
+	for {
+		fmt.Fprint(out, "[r]un  [s]kip  [o]verride with recorded output  [e]dit parameters  [q]uit > ")
+		line, err := reader.ReadString('\n')
+		if err != nil && line == "" {
+			fmt.Fprintln(out)
+			return nil, true
+		}
+
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "r", "run", "":
+			result, output := runStep(storeRoot, step, executors)
+			if edited {
+				result.Reason = "parameters edited"
+			}
+			printOutcome(out, result, recorded, output)
+			return result, false
+
+		case "s", "skip":
+			result := skippedResult(step, "")
+			return &result, false
+
+		case "o", "override":
+			return &StepResult{
+				Index:         step.Index,
+				Tool:          step.Tool,
+				Agent:         step.Agent,
+				Status:        StepOverridden,
+				ExpectedHash:  step.OutputRef,
+				ActualHash:    step.OutputRef,
+				Deterministic: step.Deterministic,
+				Reason:        "recorded output used",
+			}, false
+
+		case "e", "edit":
+			params, ok := readParameters(reader, out)
+			if ok {
+				step.Parameters = params
+				step.ParamsRedacted = false // The user supplied real values
+				edited = true
+				fmt.Fprintf(out, "Parameters:\n%s\n", indent(formatParameters(step.Parameters)))
+			}
+
+		case "q", "quit":
+			return nil, true
+
+		default:
+			fmt.Fprintf(out, "unknown command %q\n", strings.TrimSpace(line))
+		}
+	}
+}
+
+// readParameters prompts for a replacement parameter object as a single line of JSON.
+func readParameters(reader *bufio.Reader, out io.Writer) (map[string]interface{}, bool) {
+	fmt.Fprint(out, "New parameters as a JSON object (blank to keep): ")
+	line, err := reader.ReadString('\n')
+	line = strings.TrimSpace(line)
+	if line == "" {
+		if err != nil {
+			fmt.Fprintln(out)
+		}
+		return nil, false
+	}
+
+	var params map[string]interface{}
+	if err := json.Unmarshal([]byte(line), &params); err != nil {
+		fmt.Fprintf(out, "invalid parameters: %v\n", err)
+		return nil, false
+	}
+	return params, true
+}
+
+func readRecordedOutput(storeRoot string, step *pack.Step) ([]byte, error) {
+	if step.OutputRef == "" {
+		return nil, nil
+	}
+	return store.ReadBlob(storeRoot, step.OutputRef)
+}
+
+func printStep(out io.Writer, i, total int, step *pack.Step, recorded []byte, recordedErr error) {
+	det := "deterministic"
+	if !step.Deterministic {
+		det = "non-deterministic"
+	}
+
+	fmt.Fprintf(out, "\nStep %d/%d [%d] %s %s (%s)", i+1, total, step.Index, step.Type, step.Tool, det)
+	if step.Agent != "" {
+		fmt.Fprintf(out, " @%s", step.Agent)
+	}
+	fmt.Fprintln(out)
+	if step.ChildPack != "" {
+		fmt.Fprintf(out, "Sub-run: %s (replayed as a whole when run)\n", store.ShortHash(step.ChildPack, 12))
+	}
+	fmt.Fprintf(out, "Parameters:\n%s\n", indent(formatParameters(step.Parameters)))
+
+	switch {
+	case step.OutputRef == "":
+		fmt.Fprintln(out, "Recorded output: (none)")
+	case recordedErr != nil:
+		fmt.Fprintf(out, "Recorded output: unavailable (%v)\n", recordedErr)
+	case pack.IsBinaryContent(recorded, step.OutputMediaType):
+		mediaType := step.OutputMediaType
+		if mediaType == "" {
+			mediaType = "binary"
+		}
+		fmt.Fprintf(out, "Recorded output (%s, %d bytes): %s content not shown\n",
+			store.ShortHash(step.OutputRef, 12), len(recorded), mediaType)
+	default:
+		fmt.Fprintf(out, "Recorded output (%s, %d bytes):\n%s\n",
+			store.ShortHash(step.OutputRef, 12), len(recorded), indent(preview(string(recorded))))
+	}
+}
+
+func printOutcome(out io.Writer, result *StepResult, recorded, actual []byte) {
+	switch result.Status {
+	case StepMatched:
+		fmt.Fprintln(out, "✓ matched recorded output")
+	case StepFailed:
+		fmt.Fprintf(out, "✗ failed: %s\n", result.Reason)
+	case StepUnverifiable:
+		fmt.Fprintf(out, "? unverifiable: %s\n", result.Reason)
+	case StepDiverged:
+		if result.Child != nil {
+			fmt.Fprintf(out, "≠ diverged: %s\n", result.Reason)
+			return
+		}
+		fmt.Fprintf(out, "≠ diverged (expected %s, actual %s)\n",
+			store.ShortHash(result.ExpectedHash, 12), store.ShortHash(result.ActualHash, 12))
+		// Use the same size limits as pack diffs so a large output cannot
+		// blow up the line diff; the hashes above still identify both sides.
+		d := diff.CompareContent(recorded, actual)
+		switch {
+		case d.Binary:
+		case d.Omitted != "":
+			fmt.Fprintf(out, "diff omitted: %s\n", d.Omitted)
+		case d.Unified != "":
+			fmt.Fprintf(out, "--- recorded\n+++ actual\n%s", d.Unified)
+		}
+	}
+}
+
+func formatParameters(params map[string]interface{}) string {
+	if len(params) == 0 {
+		return "{}"
+	}
+	data, err := json.MarshalIndent(params, "", "  ")
+	if err != nil {
+		return fmt.Sprintf("%v", params)
+	}
+	return string(data)
+}
+
+// preview truncates text to the first previewLines lines.
+func preview(s string) string {
+	lines := textdiff.SplitLines(s)
+	if len(lines) <= previewLines {
+		return strings.Join(lines, "\n")
+	}
+	return strings.Join(lines[:previewLines], "\n") +
+		fmt.Sprintf("\n… (%d more lines)", len(lines)-previewLines)
+}
+
+func indent(s string) string {
+	return "  " + strings.ReplaceAll(s, "\n", "\n  ")
+}
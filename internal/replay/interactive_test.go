@@ -0,0 +1,142 @@
+package replay
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/contextsubstrate/ctx/internal/pack"
+)
+
+func TestReplayInteractiveRunShowsDiff(t *testing.T) {
+	root := setupTestStore(t)
+
+	testFile := filepath.Join(t.TempDir(), "test.txt")
+	os.WriteFile(testFile, []byte("line one\nline two changed\n"), 0644)
+
+	p := createTestPack(t, root, []pack.LogStep{readFileStep(testFile, "line one\nline two\n")})
+
+	var out bytes.Buffer
+	report, err := ReplayInteractive(root, p.Hash, strings.NewReader("r\n"), &out)
+	if err != nil {
+		t.Fatalf("ReplayInteractive failed: %v", err)
+	}
+
+	if report.Steps[0].Status != StepDiverged {
+		t.Errorf("expected diverged, got %s", report.Steps[0].Status)
+	}
+	if report.Fidelity != FidelityDegraded {
+		t.Errorf("expected degraded fidelity, got %s", report.Fidelity)
+	}
+	printed := out.String()
+	if !strings.Contains(printed, "Recorded output") {
+		t.Error("expected recorded output to be shown before the step")
+	}
+	if !strings.Contains(printed, "-line two\n+line two changed\n") {
+		t.Errorf("expected line diff in output, got:\n%s", printed)
+	}
+}
+
+func TestReplayInteractiveLargeDiffOmitted(t *testing.T) {
+	root := setupTestStore(t)
+
+	var recorded, actual strings.Builder
+	for i := 0; i < 20000; i++ {
+		fmt.Fprintf(&recorded, "r%d\n", i)
+		fmt.Fprintf(&actual, "a%d\n", i)
+	}
+	testFile := filepath.Join(t.TempDir(), "test.txt")
+	os.WriteFile(testFile, []byte(actual.String()), 0644)
+
+	p := createTestPack(t, root, []pack.LogStep{readFileStep(testFile, recorded.String())})
+
+	var out bytes.Buffer
+	if _, err := ReplayInteractive(root, p.Hash, strings.NewReader("r\n"), &out); err != nil {
+		t.Fatalf("ReplayInteractive failed: %v", err)
+	}
+	printed := out.String()
+	if !strings.Contains(printed, "diff omitted: too many changed lines") {
+		t.Errorf("expected diff to be omitted, got:\n%s", printed[max(0, len(printed)-500):])
+	}
+	if strings.Contains(printed, "+++ actual") {
+		t.Error("expected no line diff for oversized change")
+	}
+}
+
+func TestReplayInteractiveSkipAndOverride(t *testing.T) {
+	root := setupTestStore(t)
+
+	p := createTestPack(t, root, []pack.LogStep{
+		{Index: 0, Type: "tool_call", Tool: "web_search", Parameters: map[string]interface{}{}, Output: "a", Deterministic: true},
+		{Index: 1, Type: "tool_call", Tool: "web_search", Parameters: map[string]interface{}{}, Output: "b", Deterministic: true},
+	})
+
+	var out bytes.Buffer
+	report, err := ReplayInteractive(root, p.Hash, strings.NewReader("s\no\n"), &out)
+	if err != nil {
+		t.Fatalf("ReplayInteractive failed: %v", err)
+	}
+
+	if report.Steps[0].Status != StepSkipped {
+		t.Errorf("expected skipped, got %s", report.Steps[0].Status)
+	}
+	if report.Steps[1].Status != StepOverridden {
+		t.Errorf("expected overridden, got %s", report.Steps[1].Status)
+	}
+	if report.Fidelity != FidelityExact {
+		t.Errorf("skipped and overridden steps should not degrade fidelity, got %s", report.Fidelity)
+	}
+}
+
+func TestReplayInteractiveEditParameters(t *testing.T) {
+	root := setupTestStore(t)
+
+	dir := t.TempDir()
+	wrong := filepath.Join(dir, "wrong.txt")
+	right := filepath.Join(dir, "right.txt")
+	os.WriteFile(wrong, []byte("wrong"), 0644)
+	os.WriteFile(right, []byte("expected"), 0644)
+
+	p := createTestPack(t, root, []pack.LogStep{readFileStep(wrong, "expected")})
+
+	input := "e\n{\"path\": \"" + filepath.ToSlash(right) + "\"}\nr\n"
+	var out bytes.Buffer
+	report, err := ReplayInteractive(root, p.Hash, strings.NewReader(input), &out)
+	if err != nil {
+		t.Fatalf("ReplayInteractive failed: %v", err)
+	}
+
+	if report.Steps[0].Status != StepMatched {
+		t.Errorf("expected matched after editing parameters, got %s", report.Steps[0].Status)
+	}
+	if report.Steps[0].Reason != "parameters edited" {
+		t.Errorf("expected edited reason, got %q", report.Steps[0].Reason)
+	}
+}
+
+func TestReplayInteractiveQuit(t *testing.T) {
+	root := setupTestStore(t)
+
+	p := createTestPack(t, root, []pack.LogStep{
+		{Index: 0, Type: "tool_call", Tool: "web_search", Parameters: map[string]interface{}{}, Output: "a", Deterministic: true},
+		{Index: 1, Type: "tool_call", Tool: "web_search", Parameters: map[string]interface{}{}, Output: "b", Deterministic: true},
+	})
+
+	var out bytes.Buffer
+	report, err := ReplayInteractive(root, p.Hash, strings.NewReader("q\n"), &out)
+	if err != nil {
+		t.Fatalf("ReplayInteractive failed: %v", err)
+	}
+
+	if len(report.Steps) != 2 {
+		t.Fatalf("expected all steps reported, got %d", len(report.Steps))
+	}
+	for _, s := range report.Steps {
+		if s.Status != StepSkipped {
+			t.Errorf("expected remaining steps skipped, got %s", s.Status)
+		}
+	}
+}
@@ -15,14 +15,42 @@ func Replay(storeRoot string, packHash string) (*ReplayReport, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err := pack.CheckKey(storeRoot, p); err != nil {
+		return nil, err
+	}
+
+	report := newReport(storeRoot, p)
+	executors := DefaultExecutors()
 
+	// Execute steps, skipping any whose declared inputs come from a failed step
+	failed := make(map[int]bool)
+	for i := range p.Steps {
+		step := &p.Steps[i]
+		if dep, ok := blockedBy(step, failed); ok {
+			failed[step.Index] = true
+			report.Steps = append(report.Steps, skippedResult(step, fmt.Sprintf("depends on failed step %d", dep)))
+			continue
+		}
+
+		result := ExecuteStep(storeRoot, step, executors)
+		if result.Status == StepFailed {
+			failed[step.Index] = true
+		}
+		report.Steps = append(report.Steps, *result)
+	}
+
+	report.finish()
+	return report, nil
+}
+
+// newReport starts a replay report for a pack, recording environment drift
+// and inputs missing from the store before any step runs.
+func newReport(storeRoot string, p *pack.Pack) *ReplayReport {
 	report := &ReplayReport{
 		PackHash:  p.Hash,
 		StartTime: time.Now(),
 	}
 
-	executors := DefaultExecutors()
-
 	// Check environment drift
 	report.Drift = checkEnvironmentDrift(p)
 
@@ -37,14 +65,15 @@ func Replay(storeRoot string, packHash string) (*ReplayReport, error) {
 		}
 	}
 
-	// Execute steps
+	return report
+}
+
+// finish computes the overall fidelity from the step results and stamps the end time.
+func (r *ReplayReport) finish() {
 	hasFailed := false
 	hasDiverged := false
 
-	for i := range p.Steps {
-		result := ExecuteStep(storeRoot, &p.Steps[i], executors)
-		report.Steps = append(report.Steps, *result)
-
+	for _, result := range r.Steps {
 		switch result.Status {
 		case StepFailed:
 			hasFailed = true
@@ -58,15 +87,37 @@ func Replay(storeRoot string, packHash string) (*ReplayReport, error) {
 	// Compute fidelity
 	switch {
 	case hasFailed:
-		report.Fidelity = FidelityFailed
+		r.Fidelity = FidelityFailed
 	case hasDiverged:
-		report.Fidelity = FidelityDegraded
+		r.Fidelity = FidelityDegraded
 	default:
-		report.Fidelity = FidelityExact
+		r.Fidelity = FidelityExact
 	}
 
-	report.EndTime = time.Now()
-	return report, nil
+	r.EndTime = time.Now()
+}
+
+// blockedBy returns the first step this step depends on that failed, or was
+// itself skipped because of a failure upstream.
+func blockedBy(step *pack.Step, failed map[int]bool) (int, bool) {
+	for _, dep := range step.DependsOn() {
+		if failed[dep] {
+			return dep, true
+		}
+	}
+	return 0, false
+}
+
+func skippedResult(step *pack.Step, reason string) StepResult {
+	return StepResult{
+		Index:         step.Index,
+		Tool:          step.Tool,
+		Agent:         step.Agent,
+		Status:        StepSkipped,
+		ExpectedHash:  step.OutputRef,
+		Deterministic: step.Deterministic,
+		Reason:        reason,
+	}
 }
 
 func checkEnvironmentDrift(p *pack.Pack) []DriftEntry {
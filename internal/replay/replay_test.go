@@ -3,6 +3,7 @@ package replay
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -244,3 +245,121 @@ func TestReplayReportJSON(t *testing.T) {
 		t.Error("expected non-empty JSON")
 	}
 }
+
+func TestReplaySkipsDependentsOfFailedStep(t *testing.T) {
+	root := setupTestStore(t)
+
+	testFile := filepath.Join(t.TempDir(), "test.txt")
+	os.WriteFile(testFile, []byte("hello"), 0644)
+
+	zero, one := 0, 1
+	p := createTestPack(t, root, []pack.LogStep{
+		{Index: 0, Type: "tool_call", Tool: "web_search", Parameters: map[string]interface{}{}, Output: "results", Deterministic: true},
+		{Index: 1, Type: "tool_call", Tool: "read_file", Parameters: map[string]interface{}{"path": testFile}, Output: "hello", Deterministic: true,
+			Inputs: []pack.LogStepInput{{Step: &zero}}},
+		{Index: 2, Type: "tool_call", Tool: "read_file", Parameters: map[string]interface{}{"path": testFile}, Output: "hello", Deterministic: true,
+			Inputs: []pack.LogStepInput{{Step: &one}}},
+		{Index: 3, Type: "tool_call", Tool: "read_file", Parameters: map[string]interface{}{"path": testFile}, Output: "hello", Deterministic: true},
+	})
+
+	report, err := Replay(root, p.Hash)
+	if err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+
+	want := []StepStatus{StepFailed, StepSkipped, StepSkipped, StepMatched}
+	for i, status := range want {
+		if report.Steps[i].Status != status {
+			t.Errorf("step %d: expected %s, got %s", i, status, report.Steps[i].Status)
+		}
+	}
+	if report.Steps[2].Reason != "depends on failed step 1" {
+		t.Errorf("expected transitive skip reason, got %q", report.Steps[2].Reason)
+	}
+}
+
+func TestReplaySubRun(t *testing.T) {
+	root := setupTestStore(t)
+
+	testFile := filepath.Join(t.TempDir(), "test.txt")
+	os.WriteFile(testFile, []byte("hello"), 0644)
+
+	child := createTestPack(t, root, []pack.LogStep{
+		{Index: 0, Type: "tool_call", Tool: "read_file", Parameters: map[string]interface{}{"path": testFile}, Output: "stale", Deterministic: true},
+	})
+
+	log := &pack.ExecutionLog{
+		Model:        pack.LogModel{Identifier: "test-model", Parameters: map[string]interface{}{}},
+		SystemPrompt: "test prompt",
+		Agents:       []pack.LogAgent{{Name: "Lead"}},
+		Steps: []pack.LogStep{
+			{Index: 0, Type: "sub_run", Tool: "delegate", Output: "done", Deterministic: false, Agent: "Lead", ChildPack: child.Hash},
+		},
+		Environment: pack.LogEnvironment{OS: "darwin", Runtime: "go1.22", ToolVersions: map[string]string{}},
+	}
+	parent, err := pack.CreatePack(root, log)
+	if err != nil {
+		t.Fatalf("CreatePack failed: %v", err)
+	}
+
+	report, err := Replay(root, parent.Hash)
+	if err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+
+	step := report.Steps[0]
+	if step.Child == nil {
+		t.Fatal("expected child replay report")
+	}
+	if step.Status != StepDiverged || step.Agent != "Lead" {
+		t.Errorf("expected diverged step by Lead, got %s by %q", step.Status, step.Agent)
+	}
+	if report.Fidelity != FidelityDegraded {
+		t.Errorf("degraded sub-run should degrade the parent, got %s", report.Fidelity)
+	}
+
+	summary := report.Summary()
+	if !strings.Contains(summary, "      ≠ [0] read_file diverged") {
+		t.Errorf("expected nested sub-run steps in summary, got:\n%s", summary)
+	}
+	if !strings.Contains(summary, "Lead: 1 diverged") {
+		t.Errorf("expected per-agent tally in summary, got:\n%s", summary)
+	}
+}
+
+func TestReplayRedactedOutput(t *testing.T) {
+	root := setupTestStore(t)
+	testFile := filepath.Join(t.TempDir(), "contacts.txt")
+	content := "owner: alice@example.com\n"
+	os.WriteFile(testFile, []byte(content), 0644)
+
+	p := createTestPack(t, root, []pack.LogStep{
+		{Index: 0, Type: "tool_call", Tool: "read_file", Parameters: map[string]interface{}{"path": testFile}, Output: content, Deterministic: true},
+		{Index: 1, Type: "tool_call", Tool: "read_file", Parameters: map[string]interface{}{"path": testFile, "api_key": "k"}, Output: content, Deterministic: true},
+	})
+	if !p.Steps[0].OutputRedacted || !p.Steps[1].ParamsRedacted {
+		t.Fatalf("expected the redacted steps recorded, got %+v", p.Steps)
+	}
+
+	report, err := Replay(root, p.Hash)
+	if err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if report.Steps[0].Status != StepMatched {
+		t.Errorf("expected the redacted output to match, got %s (%s)", report.Steps[0].Status, report.Steps[0].Reason)
+	}
+	if report.Steps[1].Status != StepUnverifiable {
+		t.Errorf("expected a step with redacted parameters to be unverifiable, got %s", report.Steps[1].Status)
+	}
+	if report.Fidelity != FidelityExact {
+		t.Errorf("expected exact fidelity, got %s", report.Fidelity)
+	}
+
+	cfg := store.DefaultConfig()
+	cfg.Redaction = &store.RedactionConfig{Disabled: true}
+	store.WriteConfig(root, cfg)
+	report, _ = Replay(root, p.Hash)
+	if report.Steps[0].Status != StepUnverifiable {
+		t.Errorf("expected unverifiable once redaction is disabled, got %s", report.Steps[0].Status)
+	}
+}
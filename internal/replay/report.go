@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"strings"
 	"time"
+
+	"github.com/contextsubstrate/ctx/internal/store"
 )
 
 type FidelityLevel string
@@ -21,16 +23,30 @@ const (
 	StepMatched  StepStatus = "matched"
 	StepDiverged StepStatus = "diverged"
 	StepFailed   StepStatus = "failed"
+
+	// A step is skipped when a step it depends on failed, or when the user
+	// chooses not to run it during interactive replay.
+	StepSkipped StepStatus = "skipped"
+
+	// Produced only by interactive replay, when the user accepts the
+	// recorded output instead of executing the step.
+	StepOverridden StepStatus = "overridden"
+
+	// A step is unverifiable when redaction at pack time removed what replay
+	// needs to run or compare it. It does not count against fidelity.
+	StepUnverifiable StepStatus = "unverifiable"
 )
 
 type StepResult struct {
-	Index         int        `json:"index"`
-	Tool          string     `json:"tool"`
-	Status        StepStatus `json:"status"`
-	ExpectedHash  string     `json:"expected_hash,omitempty"`
-	ActualHash    string     `json:"actual_hash,omitempty"`
-	Deterministic bool       `json:"deterministic"`
-	Reason        string     `json:"reason,omitempty"`
+	Index         int           `json:"index"`
+	Tool          string        `json:"tool"`
+	Agent         string        `json:"agent,omitempty"`
+	Status        StepStatus    `json:"status"`
+	ExpectedHash  string        `json:"expected_hash,omitempty"`
+	ActualHash    string        `json:"actual_hash,omitempty"`
+	Deterministic bool          `json:"deterministic"`
+	Reason        string        `json:"reason,omitempty"`
+	Child         *ReplayReport `json:"child,omitempty"`
 }
 
 type DriftEntry struct {
@@ -58,7 +74,91 @@ func (r *ReplayReport) Summary() string {
 	b.WriteString(fmt.Sprintf("Duration: %s\n\n", r.EndTime.Sub(r.StartTime)))
 
 	b.WriteString(fmt.Sprintf("Steps (%d):\n", len(r.Steps)))
-	for _, s := range r.Steps {
+	writeSteps(&b, r.Steps, "  ")
+
+	if agents := agentTallies(r.Steps); len(agents) > 0 {
+		b.WriteString("\nAgents:\n")
+		for _, a := range agents {
+			b.WriteString(fmt.Sprintf("  %s: %s\n", a.name, a.counts))
+		}
+	}
+
+	if len(r.Drift) > 0 {
+		b.WriteString(fmt.Sprintf("\nDrift (%d):\n", len(r.Drift)))
+		for _, d := range r.Drift {
+			b.WriteString(fmt.Sprintf("  %s: %s\n", d.Type, d.Description))
+		}
+	}
+
+	return b.String()
+}
+
+// JSON returns the report as JSON bytes.
+func (r *ReplayReport) JSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
+
+// Summary returns a human-readable summary of a batch replay.
+func (r *BatchReport) Summary() string {
+	var b strings.Builder
+
+	b.WriteString(fmt.Sprintf("Batch replay: %d pack(s)\n", len(r.Packs)))
+	b.WriteString(fmt.Sprintf("Fidelity: %s\n", r.Fidelity()))
+	b.WriteString(fmt.Sprintf("Duration: %s\n\n", r.EndTime.Sub(r.StartTime)))
+
+	b.WriteString("Packs:\n")
+	for _, e := range r.Packs {
+		if e.Error != "" {
+			b.WriteString(fmt.Sprintf("  ✗ %s  error (%s)\n", store.ShortHash(e.PackHash, 12), e.Error))
+			continue
+		}
+		b.WriteString(fmt.Sprintf("  %s %s  %-8s  %d matched, %d diverged, %d failed  %s\n",
+			fidelityIcon(e.Fidelity),
+			store.ShortHash(e.PackHash, 12),
+			e.Fidelity,
+			e.Matched, e.Diverged, e.Failed,
+			e.Model,
+		))
+	}
+
+	b.WriteString(fmt.Sprintf("\nTotals: %d exact, %d degraded, %d failed, %d error(s)\n",
+		r.Exact, r.Degraded, r.Failed, r.Errors))
+
+	if len(r.Tools) > 0 {
+		b.WriteString("\nMost divergent tools:\n")
+		for _, t := range r.Tools {
+			b.WriteString(fmt.Sprintf("  %-20s %d diverged, %d failed across %d pack(s)\n",
+				t.Tool, t.Diverged, t.Failed, t.Packs))
+		}
+	}
+
+	return b.String()
+}
+
+// JSON returns the batch report as JSON bytes.
+func (r *BatchReport) JSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
+
+func fidelityIcon(f FidelityLevel) string {
+	switch f {
+	case FidelityExact:
+		return "✓"
+	case FidelityDegraded:
+		return "≠"
+	default:
+		return "✗"
+	}
+}
+
+// writeSteps renders step results one per line, marking handoffs between
+// agents and nesting the steps of replayed sub-runs beneath their parent step.
+func writeSteps(b *strings.Builder, steps []StepResult, indent string) {
+	for i, s := range steps {
+		if i > 0 && s.Agent != steps[i-1].Agent {
+			b.WriteString(fmt.Sprintf("%s── handoff: %s → %s ──\n", indent, agentLabel(steps[i-1].Agent), agentLabel(s.Agent)))
+		}
+
 		var icon string
 		switch s.Status {
 		case StepMatched:
@@ -71,6 +171,12 @@ func (r *ReplayReport) Summary() string {
 			}
 		case StepFailed:
 			icon = "✗"
+		case StepSkipped:
+			icon = "-"
+		case StepOverridden:
+			icon = "→"
+		case StepUnverifiable:
+			icon = "?"
 		}
 
 		detail := ""
@@ -80,21 +186,55 @@ func (r *ReplayReport) Summary() string {
 		if s.Reason != "" {
 			detail = fmt.Sprintf(" (%s)", s.Reason)
 		}
+		agent := ""
+		if s.Agent != "" {
+			agent = " @" + s.Agent
+		}
 
-		b.WriteString(fmt.Sprintf("  %s [%d] %s %s%s\n", icon, s.Index, s.Tool, s.Status, detail))
+		b.WriteString(fmt.Sprintf("%s%s [%d] %s %s%s%s\n", indent, icon, s.Index, s.Tool, s.Status, agent, detail))
+		if s.Child != nil {
+			writeSteps(b, s.Child.Steps, indent+"    ")
+		}
 	}
+}
 
-	if len(r.Drift) > 0 {
-		b.WriteString(fmt.Sprintf("\nDrift (%d):\n", len(r.Drift)))
-		for _, d := range r.Drift {
-			b.WriteString(fmt.Sprintf("  %s: %s\n", d.Type, d.Description))
-		}
+func agentLabel(name string) string {
+	if name == "" {
+		return "(run)"
 	}
+	return name
+}
 
-	return b.String()
+type agentTally struct {
+	name   string
+	counts string
 }
 
-// JSON returns the report as JSON bytes.
-func (r *ReplayReport) JSON() ([]byte, error) {
-	return json.MarshalIndent(r, "", "  ")
+// agentTallies summarizes step outcomes per agent, in order of first appearance.
+// Returns nil when no step is attributed to an agent.
+func agentTallies(steps []StepResult) []agentTally {
+	var order []string
+	counts := make(map[string]map[StepStatus]int)
+	for _, s := range steps {
+		if s.Agent == "" {
+			continue
+		}
+		if counts[s.Agent] == nil {
+			counts[s.Agent] = make(map[StepStatus]int)
+			order = append(order, s.Agent)
+		}
+		counts[s.Agent][s.Status]++
+	}
+
+	var tallies []agentTally
+	for _, name := range order {
+		var parts []string
+		for _, status := range []StepStatus{StepMatched, StepDiverged, StepFailed, StepSkipped, StepOverridden, StepUnverifiable} {
+			if n := counts[name][status]; n > 0 {
+				parts = append(parts, fmt.Sprintf("%d %s", n, status))
+			}
+		}
+		tallies = append(tallies, agentTally{name: name, counts: strings.Join(parts, ", ")})
+	}
+	return tallies
 }
@@ -0,0 +1,324 @@
+package sharing
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/contextsubstrate/ctx/internal/pack"
+	"github.com/contextsubstrate/ctx/internal/store"
+)
+
+const draftSuffix = ".draft.json"
+
+// DraftSummary describes a draft waiting to be finalized.
+type DraftSummary struct {
+	Path     string
+	Parent   string
+	Modified time.Time
+	Steps    int
+}
+
+// DraftsDir returns the directory holding a store's drafts.
+func DraftsDir(storeRoot string) string {
+	return filepath.Join(storeRoot, "drafts")
+}
+
+// ListDrafts lists the drafts in the store, most recently modified first.
+func ListDrafts(storeRoot string) ([]DraftSummary, error) {
+	entries, err := os.ReadDir(DraftsDir(storeRoot))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading drafts directory: %w", err)
+	}
+
+	var drafts []DraftSummary
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), draftSuffix) {
+			continue
+		}
+		path := filepath.Join(DraftsDir(storeRoot), entry.Name())
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		summary := DraftSummary{Path: path, Modified: info.ModTime()}
+		if p, err := LoadDraft(path); err == nil {
+			summary.Parent = p.Parent
+			summary.Steps = len(p.Steps)
+		}
+		drafts = append(drafts, summary)
+	}
+	sort.Slice(drafts, func(i, j int) bool { return drafts[i].Modified.After(drafts[j].Modified) })
+	return drafts, nil
+}
+
+// FormatDraftList produces human-readable output for a list of drafts.
+func FormatDraftList(drafts []DraftSummary) string {
+	if len(drafts) == 0 {
+		return "No drafts found.\n"
+	}
+	var b strings.Builder
+	for _, d := range drafts {
+		parent := "(unreadable)"
+		if d.Parent != "" {
+			parent = "forked from " + store.ShortHash(d.Parent, 12)
+		}
+		fmt.Fprintf(&b, "%s  %s  %d steps  %s\n", d.Path, d.Modified.Format("2006-01-02 15:04:05"), d.Steps, parent)
+	}
+	return b.String()
+}
+
+// ResolveDraft accepts a draft path, a draft name, or the parent hash prefix
+// a draft is named after, and returns the draft's path.
+func ResolveDraft(storeRoot string, ref string) (string, error) {
+	if info, err := os.Stat(ref); err == nil && !info.IsDir() {
+		return ref, nil
+	}
+
+	name := strings.TrimSuffix(filepath.Base(ref), draftSuffix)
+	name = strings.TrimPrefix(name, "sha256:")
+	drafts, err := ListDrafts(storeRoot)
+	if err != nil {
+		return "", err
+	}
+	var matches []string
+	for _, d := range drafts {
+		if strings.HasPrefix(strings.TrimSuffix(filepath.Base(d.Path), draftSuffix), name) {
+			matches = append(matches, d.Path)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("draft not found: %s", ref)
+	case 1:
+		return matches[0], nil
+	default:
+		return "", fmt.Errorf("ambiguous draft %q matches %d drafts", ref, len(matches))
+	}
+}
+
+// LoadDraft reads a draft manifest.
+func LoadDraft(path string) (*pack.Pack, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading draft: %w", err)
+	}
+	var p pack.Pack
+	if err := pack.DecodeJSON(data, &p); err != nil {
+		return nil, fmt.Errorf("parsing draft: %w", err)
+	}
+	return &p, nil
+}
+
+// SaveDraft writes a draft manifest back to its file.
+func SaveDraft(path string, p *pack.Pack) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return fmt.Errorf("serializing draft: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("writing draft: %w", err)
+	}
+	return nil
+}
+
+// CheckDraft reports why a draft cannot be finalized: missing required
+// fields, malformed or missing blob references, or step inputs that point at
+// steps that no longer exist.
+func CheckDraft(storeRoot string, p *pack.Pack) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	for _, r := range p.BlobRefs() {
+		if !store.ValidateHash(r.Ref) {
+			return fmt.Errorf("%s: invalid blob reference %q", r.Field, r.Ref)
+		}
+		if !store.BlobExists(storeRoot, r.Ref) {
+			return fmt.Errorf("%s: blob %s not found in store", r.Field, store.ShortHash(r.Ref, 12))
+		}
+	}
+	// Step inputs name steps by Index, which need not match slice position.
+	earlier := make(map[int]bool, len(p.Steps))
+	for i, s := range p.Steps {
+		for _, dep := range s.DependsOn() {
+			if !earlier[dep] {
+				return fmt.Errorf("steps[%d]: input refers to step %d, which does not precede it", i, dep)
+			}
+		}
+		earlier[s.Index] = true
+	}
+	return nil
+}
+
+// stepPosition returns the position in p.Steps of the step with the given
+// Index, the number ctx show prints for it.
+func stepPosition(p *pack.Pack, index int) (int, error) {
+	for i, s := range p.Steps {
+		if s.Index == index {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("draft has no step %d", index)
+}
+
+// writeContent stores new content for the named field of a draft, redacted
+// per the store's config and encrypted with the draft's key if it is
+// encrypted. It returns the content as stored.
+func writeContent(storeRoot string, p *pack.Pack, field string, content []byte) (string, []byte, error) {
+	content, err := p.RedactContent(storeRoot, field, content)
+	if err != nil {
+		return "", nil, err
+	}
+	key, err := pack.PackKey(storeRoot, p)
+	if err != nil {
+		return "", nil, err
+	}
+	ref, err := store.WriteBlobWithKey(storeRoot, content, key)
+	return ref, content, err
+}
+
+// SetSystemPrompt stores new system prompt content and points the draft at it.
+func SetSystemPrompt(storeRoot string, p *pack.Pack, content []byte) error {
+	ref, _, err := writeContent(storeRoot, p, "system_prompt", content)
+	if err != nil {
+		return fmt.Errorf("storing system prompt: %w", err)
+	}
+	p.SystemPrompt = ref
+	return nil
+}
+
+// SetPrompt stores new content for the prompt at index i.
+func SetPrompt(storeRoot string, p *pack.Pack, i int, content []byte) error {
+	if i < 0 || i >= len(p.Prompts) {
+		return fmt.Errorf("prompt %d out of range (draft has %d prompts)", i, len(p.Prompts))
+	}
+	ref, _, err := writeContent(storeRoot, p, fmt.Sprintf("prompts[%d]", i), content)
+	if err != nil {
+		return fmt.Errorf("storing prompt %d: %w", i, err)
+	}
+	p.Prompts[i].ContentRef = ref
+	return nil
+}
+
+// ReplaceInput stores new content for the named input and updates its size.
+func ReplaceInput(storeRoot string, p *pack.Pack, name string, content []byte) error {
+	for i := range p.Inputs {
+		if p.Inputs[i].Name != name {
+			continue
+		}
+		ref, stored, err := writeContent(storeRoot, p, fmt.Sprintf("inputs[%s]", name), content)
+		if err != nil {
+			return fmt.Errorf("storing input %s: %w", name, err)
+		}
+		p.Inputs[i].ContentRef = ref
+		p.Inputs[i].Size = int64(len(stored))
+		return nil
+	}
+	return fmt.Errorf("input %q not found in draft", name)
+}
+
+// DropStep removes the step with the given Index. Each step after it takes
+// the Index of the step before it, and inputs that refer to them follow. It
+// refuses to drop a step whose output a later step consumed.
+func DropStep(p *pack.Pack, index int) error {
+	pos, err := stepPosition(p, index)
+	if err != nil {
+		return err
+	}
+	for _, s := range p.Steps {
+		for _, dep := range s.DependsOn() {
+			if dep == index {
+				return fmt.Errorf("cannot drop step %d: step %d consumes its output", index, s.Index)
+			}
+		}
+	}
+
+	renumber := make(map[int]int, len(p.Steps)-pos-1)
+	for j := pos + 1; j < len(p.Steps); j++ {
+		renumber[p.Steps[j].Index] = p.Steps[j-1].Index
+	}
+	p.Steps = append(p.Steps[:pos], p.Steps[pos+1:]...)
+	var redactions []pack.Redaction
+	for _, r := range p.Redactions {
+		if field, ok := shiftStepField(r.Field, pos); ok {
+			r.Field = field
+			redactions = append(redactions, r)
+		}
+	}
+	p.Redactions = redactions
+	var unscanned []string
+	for _, f := range p.Unscanned {
+		if field, ok := shiftStepField(f, pos); ok {
+			unscanned = append(unscanned, field)
+		}
+	}
+	p.Unscanned = unscanned
+	for j := range p.Steps {
+		s := &p.Steps[j]
+		if n, ok := renumber[s.Index]; ok {
+			s.Index = n
+		}
+		for k := range s.Inputs {
+			if dep := s.Inputs[k].Step; dep != nil {
+				if n, ok := renumber[*dep]; ok {
+					s.Inputs[k].Step = &n
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// shiftStepField renames a manifest field after the step at position pos is
+// dropped: fields of later steps move back one position, and fields of the
+// dropped step itself are reported as gone.
+func shiftStepField(field string, pos int) (string, bool) {
+	rest, ok := strings.CutPrefix(field, "steps[")
+	if !ok {
+		return field, true
+	}
+	n, suffix, ok := strings.Cut(rest, "]")
+	i, err := strconv.Atoi(n)
+	if !ok || err != nil {
+		return field, true
+	}
+	switch {
+	case i == pos:
+		return "", false
+	case i > pos:
+		return fmt.Sprintf("steps[%d]%s", i-1, suffix), true
+	}
+	return field, true
+}
+
+// SetParam sets one parameter of the step with the given Index. The value is
+// parsed as JSON, falling back to a plain string, so `5`, `true` and `"5"`
+// all work. It is redacted per the store's config like recorded parameters.
+func SetParam(storeRoot string, p *pack.Pack, index int, key string, value string) error {
+	i, err := stepPosition(p, index)
+	if err != nil {
+		return err
+	}
+	var v interface{}
+	if err := pack.DecodeJSON([]byte(value), &v); err != nil {
+		v = value
+	}
+	field := fmt.Sprintf("steps[%d].parameters", i)
+	if v, err = p.RedactParameter(storeRoot, field, key, v); err != nil {
+		return err
+	}
+	if p.Steps[i].Parameters == nil {
+		p.Steps[i].Parameters = make(map[string]interface{})
+	}
+	p.Steps[i].Parameters[key] = v
+	p.Steps[i].ParamsRedacted = p.Redacted(field)
+	return nil
+}
@@ -18,7 +18,7 @@ func Fork(storeRoot string, sourceHash string) (string, error) {
 	}
 
 	// Create drafts directory if needed
-	draftsDir := filepath.Join(storeRoot, "drafts")
+	draftsDir := DraftsDir(storeRoot)
 	if err := os.MkdirAll(draftsDir, 0755); err != nil {
 		return "", fmt.Errorf("creating drafts directory: %w", err)
 	}
@@ -33,7 +33,7 @@ func Fork(storeRoot string, sourceHash string) (string, error) {
 	}
 
 	// Use parent short hash as draft name
-	draftName := store.ShortHash(p.Parent, 12) + ".draft.json"
+	draftName := store.ShortHash(p.Parent, 12) + draftSuffix
 	draftPath := filepath.Join(draftsDir, draftName)
 
 	if err := os.WriteFile(draftPath, data, 0644); err != nil {
@@ -43,29 +43,25 @@ func Fork(storeRoot string, sourceHash string) (string, error) {
 	return draftPath, nil
 }
 
-// FinalizeDraft converts a mutable draft into an immutable pack.
+// FinalizeDraft converts a mutable draft into an immutable pack. The draft
+// must pass CheckDraft; it is left in place if it does not.
 func FinalizeDraft(storeRoot string, draftPath string) (*pack.Pack, error) {
-	data, err := os.ReadFile(draftPath)
+	p, err := LoadDraft(draftPath)
 	if err != nil {
-		return nil, fmt.Errorf("reading draft: %w", err)
-	}
-
-	var p pack.Pack
-	if err := json.Unmarshal(data, &p); err != nil {
-		return nil, fmt.Errorf("parsing draft: %w", err)
+		return nil, err
 	}
 
 	if p.Parent == "" {
 		return nil, fmt.Errorf("draft has no parent reference")
 	}
+	if err := CheckDraft(storeRoot, p); err != nil {
+		return nil, fmt.Errorf("invalid draft: %w", err)
+	}
 
-	// Clear hash so canonical serialization is clean
-	p.Hash = ""
-
-	// Re-serialize as canonical JSON and store as blob
-	canonicalData, err := json.Marshal(&p)
+	// Store the canonical manifest, exactly as CreatePack does
+	canonicalData, err := pack.MarshalCanonical(p)
 	if err != nil {
-		return nil, fmt.Errorf("serializing pack: %w", err)
+		return nil, err
 	}
 
 	hash, err := store.WriteBlob(storeRoot, canonicalData)
@@ -73,6 +69,9 @@ func FinalizeDraft(storeRoot string, draftPath string) (*pack.Pack, error) {
 		return nil, fmt.Errorf("storing pack: %w", err)
 	}
 	p.Hash = hash
+	for i := range p.Outputs {
+		p.Outputs[i].ContextPack = hash
+	}
 
 	// Register
 	if err := pack.RegisterPack(storeRoot, hash); err != nil {
@@ -85,5 +84,5 @@ func FinalizeDraft(storeRoot string, draftPath string) (*pack.Pack, error) {
 	// Remove draft
 	os.Remove(draftPath)
 
-	return &p, nil
+	return p, nil
 }
@@ -0,0 +1,241 @@
+package sharing
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/contextsubstrate/ctx/internal/diff"
+	"github.com/contextsubstrate/ctx/internal/pack"
+	"github.com/contextsubstrate/ctx/internal/store"
+)
+
+// LineageNode is one pack in a fork family tree. Change summarizes how the
+// pack differs from its parent; Missing marks an ancestor that is referenced
+// but not present in this store.
+type LineageNode struct {
+	Hash     string
+	Created  string
+	Model    string
+	Change   string
+	Missing  bool
+	Children []*LineageNode
+}
+
+// Lineage is the fork family of a pack: its chain of ancestors down to the
+// pack itself, and every pack forked from it, directly or indirectly.
+type Lineage struct {
+	Root      *LineageNode
+	Target    *LineageNode
+	Ancestors []*LineageNode // Oldest first, excluding the target
+}
+
+// BuildLineage walks a pack's parents and scans the store for its forks.
+func BuildLineage(storeRoot string, hash string) (*Lineage, error) {
+	target, err := pack.LoadPack(storeRoot, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	children, err := forkIndex(storeRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &Lineage{}
+	l.Target = newLineageNode(target)
+	addDescendants(storeRoot, l.Target, target, children, map[string]bool{target.Hash: true})
+
+	// Walk up the parent chain, stopping at a pack outside the store or a cycle
+	seen := map[string]bool{target.Hash: true}
+	node, p := l.Target, target
+	for p != nil && p.Parent != "" && !seen[p.Parent] {
+		seen[p.Parent] = true
+		parent, err := pack.LoadPack(storeRoot, p.Parent)
+		var up *LineageNode
+		if err != nil {
+			up = &LineageNode{Hash: p.Parent, Missing: true}
+			parent = nil
+		} else {
+			up = newLineageNode(parent)
+			node.Change = changeSummary(parent, p)
+		}
+		up.Children = []*LineageNode{node}
+		l.Ancestors = append([]*LineageNode{up}, l.Ancestors...)
+		node, p = up, parent
+	}
+	l.Root = node
+	return l, nil
+}
+
+// forkIndex maps each parent hash to the packs forked from it, oldest first.
+func forkIndex(storeRoot string) (map[string][]string, error) {
+	summaries, err := ListPacks(storeRoot, 0)
+	if err != nil {
+		return nil, err
+	}
+	// Forks keep their parent's creation time, so break ties by hash
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].Created != summaries[j].Created {
+			return summaries[i].Created < summaries[j].Created
+		}
+		return summaries[i].Hash < summaries[j].Hash
+	})
+	children := make(map[string][]string)
+	for _, s := range summaries {
+		if s.Parent != "" {
+			children[s.Parent] = append(children[s.Parent], s.Hash)
+		}
+	}
+	return children, nil
+}
+
+func addDescendants(storeRoot string, node *LineageNode, p *pack.Pack, children map[string][]string, seen map[string]bool) {
+	for _, h := range children[p.Hash] {
+		if seen[h] {
+			continue
+		}
+		seen[h] = true
+		child, err := pack.LoadPack(storeRoot, h)
+		if err != nil {
+			continue
+		}
+		n := newLineageNode(child)
+		n.Change = changeSummary(p, child)
+		node.Children = append(node.Children, n)
+		addDescendants(storeRoot, n, child, children, seen)
+	}
+}
+
+func newLineageNode(p *pack.Pack) *LineageNode {
+	return &LineageNode{
+		Hash:    p.Hash,
+		Created: p.Created.Format("2006-01-02 15:04:05"),
+		Model:   p.Model.Identifier,
+	}
+}
+
+// changeSummary counts the drift between a parent and a fork by kind, for
+// example "2 step, 1 output".
+func changeSummary(parent, child *pack.Pack) string {
+	entries := diff.ComparePacks(parent, child)
+	if len(entries) == 0 {
+		return "no changes"
+	}
+	var kinds []diff.DriftType
+	counts := make(map[diff.DriftType]int)
+	for _, e := range entries {
+		if counts[e.Type] == 0 {
+			kinds = append(kinds, e.Type)
+		}
+		counts[e.Type]++
+	}
+	parts := make([]string, len(kinds))
+	for i, k := range kinds {
+		parts[i] = fmt.Sprintf("%d %s", counts[k], strings.TrimSuffix(string(k), "_drift"))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Text renders the lineage as an indented tree, marking the target pack.
+func (l *Lineage) Text() string {
+	var b strings.Builder
+	var walk func(n *LineageNode, prefix, branch string)
+	walk = func(n *LineageNode, prefix, branch string) {
+		b.WriteString(prefix + branch + l.label(n))
+		if n.Change != "" && n != l.Root {
+			b.WriteString("  [" + n.Change + "]")
+		}
+		b.WriteString("\n")
+
+		switch branch {
+		case "├─ ":
+			prefix += "│  "
+		case "└─ ":
+			prefix += "   "
+		}
+		for i, c := range n.Children {
+			if i == len(n.Children)-1 {
+				walk(c, prefix, "└─ ")
+			} else {
+				walk(c, prefix, "├─ ")
+			}
+		}
+	}
+	walk(l.Root, "", "")
+	return b.String()
+}
+
+func (l *Lineage) label(n *LineageNode) string {
+	s := store.ShortHash(n.Hash, 12)
+	if n.Missing {
+		return s + "  (not in this store)"
+	}
+	s += "  " + n.Created + "  " + n.Model
+	if n == l.Target {
+		s += "  ← this pack"
+	}
+	return s
+}
+
+// DOT renders the lineage as a Graphviz digraph with change summaries on the
+// edges.
+func (l *Lineage) DOT() string {
+	var b strings.Builder
+	b.WriteString("digraph lineage {\n  rankdir=TB;\n  node [shape=box, fontname=monospace];\n")
+	l.walk(func(n *LineageNode) {
+		attrs := fmt.Sprintf("label=%q", store.ShortHash(n.Hash, 12)+"\n"+n.Created)
+		switch {
+		case n == l.Target:
+			attrs += ", style=bold"
+		case n.Missing:
+			attrs = fmt.Sprintf("label=%q, style=dashed", store.ShortHash(n.Hash, 12))
+		}
+		fmt.Fprintf(&b, "  %q [%s];\n", store.ShortHash(n.Hash, 12), attrs)
+	}, func(parent, child *LineageNode) {
+		fmt.Fprintf(&b, "  %q -> %q [label=%q];\n", store.ShortHash(parent.Hash, 12), store.ShortHash(child.Hash, 12), child.Change)
+	})
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// Mermaid renders the lineage as a Mermaid flowchart.
+func (l *Lineage) Mermaid() string {
+	var b strings.Builder
+	b.WriteString("graph TD\n")
+	id := func(n *LineageNode) string { return "p" + store.ShortHash(n.Hash, 12) }
+	l.walk(func(n *LineageNode) {
+		label := store.ShortHash(n.Hash, 12)
+		if !n.Missing {
+			label += "<br/>" + n.Created
+		}
+		fmt.Fprintf(&b, "  %s[\"%s\"]\n", id(n), label)
+		if n == l.Target {
+			fmt.Fprintf(&b, "  style %s stroke-width:3px\n", id(n))
+		}
+	}, func(parent, child *LineageNode) {
+		fmt.Fprintf(&b, "  %s -->|\"%s\"| %s\n", id(parent), child.Change, id(child))
+	})
+	return b.String()
+}
+
+// walk visits every node, then every parent-child edge, in tree order.
+func (l *Lineage) walk(node func(*LineageNode), edge func(parent, child *LineageNode)) {
+	var nodes []*LineageNode
+	var collect func(*LineageNode)
+	collect = func(n *LineageNode) {
+		nodes = append(nodes, n)
+		for _, c := range n.Children {
+			collect(c)
+		}
+	}
+	collect(l.Root)
+	for _, n := range nodes {
+		node(n)
+	}
+	for _, n := range nodes {
+		for _, c := range n.Children {
+			edge(n, c)
+		}
+	}
+}
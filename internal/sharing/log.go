@@ -2,9 +2,8 @@ package sharing
 
 import (
 	"fmt"
-	"os"
-	"path/filepath"
 	"sort"
+	"time"
 
 	"github.com/contextsubstrate/ctx/internal/pack"
 	"github.com/contextsubstrate/ctx/internal/store"
@@ -16,29 +15,19 @@ type PackSummary struct {
 	Model   string
 	Steps   int
 	Parent  string
+	Tokens  int
+	Latency time.Duration
 }
 
 // ListPacks lists all finalized packs in the store, sorted by creation date (newest first).
 func ListPacks(storeRoot string, limit int) ([]PackSummary, error) {
-	packsDir := filepath.Join(storeRoot, "packs")
-	entries, err := os.ReadDir(packsDir)
+	hashes, err := pack.RegisteredPacks(storeRoot)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, nil
-		}
-		return nil, fmt.Errorf("reading packs directory: %w", err)
+		return nil, err
 	}
 
 	var summaries []PackSummary
-	for _, entry := range entries {
-		if entry.IsDir() {
-			continue
-		}
-		ref := "sha256:" + entry.Name()
-		if !store.ValidateHash(ref) {
-			continue
-		}
-
+	for _, ref := range hashes {
 		p, err := pack.LoadPack(storeRoot, ref)
 		if err != nil {
 			continue // Skip corrupted packs
@@ -50,6 +39,8 @@ func ListPacks(storeRoot string, limit int) ([]PackSummary, error) {
 			Model:   p.Model.Identifier,
 			Steps:   len(p.Steps),
 			Parent:  p.Parent,
+			Tokens:  p.TotalUsage().Total(),
+			Latency: p.TotalLatency(),
 		})
 	}
 
@@ -77,11 +68,19 @@ func FormatPackList(summaries []PackSummary) string {
 		if p.Parent != "" {
 			parent = fmt.Sprintf(" (forked from %s)", store.ShortHash(p.Parent, 12))
 		}
-		s += fmt.Sprintf("%s  %s  %s  %d steps%s\n",
+		usage := ""
+		if p.Tokens > 0 {
+			usage += fmt.Sprintf("  %d tokens", p.Tokens)
+		}
+		if p.Latency > 0 {
+			usage += fmt.Sprintf("  %s", p.Latency)
+		}
+		s += fmt.Sprintf("%s  %s  %s  %d steps%s%s\n",
 			store.ShortHash(p.Hash, 12),
 			p.Created,
 			p.Model,
 			p.Steps,
+			usage,
 			parent,
 		)
 	}
@@ -2,11 +2,15 @@ package sharing
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/contextsubstrate/ctx/internal/pack"
+	"github.com/contextsubstrate/ctx/internal/store"
 )
 
 func setupTestStore(t *testing.T) string {
@@ -178,3 +182,386 @@ func TestManifestNoAbsolutePaths(t *testing.T) {
 		}
 	}
 }
+
+// forkWithTool forks a pack, renames its first step's tool and finalizes it.
+func forkWithTool(t *testing.T, root string, parent string, tool string) *pack.Pack {
+	t.Helper()
+	draftPath, err := Fork(root, parent)
+	if err != nil {
+		t.Fatalf("Fork failed: %v", err)
+	}
+	data, _ := os.ReadFile(draftPath)
+	var draft pack.Pack
+	json.Unmarshal(data, &draft)
+	draft.Steps[0].Tool = tool
+	data, _ = json.Marshal(&draft)
+	os.WriteFile(draftPath, data, 0644)
+
+	p, err := FinalizeDraft(root, draftPath)
+	if err != nil {
+		t.Fatalf("FinalizeDraft failed: %v", err)
+	}
+	return p
+}
+
+func TestBuildLineage(t *testing.T) {
+	root := setupTestStore(t)
+	base := createTestPack(t, root, "original")
+	mid := forkWithTool(t, root, base.Hash, "tool_b")
+	leafA := forkWithTool(t, root, mid.Hash, "tool_c")
+	leafB := forkWithTool(t, root, mid.Hash, "tool_d")
+	createTestPack(t, root, "unrelated")
+
+	l, err := BuildLineage(root, mid.Hash)
+	if err != nil {
+		t.Fatalf("BuildLineage failed: %v", err)
+	}
+	if l.Root.Hash != base.Hash || len(l.Ancestors) != 1 {
+		t.Fatalf("expected the base pack as the only ancestor, got %+v", l.Ancestors)
+	}
+	if l.Target.Hash != mid.Hash || len(l.Target.Children) != 2 {
+		t.Fatalf("expected 2 forks of the target, got %d", len(l.Target.Children))
+	}
+	if l.Target.Change != "1 tool" {
+		t.Errorf("expected the tool change on the edge, got %q", l.Target.Change)
+	}
+
+	first, last := leafA, leafB
+	if first.Hash > last.Hash {
+		first, last = last, first
+	}
+	text := l.Text()
+	for _, want := range []string{
+		"├─ " + store.ShortHash(first.Hash, 12),
+		"└─ " + store.ShortHash(last.Hash, 12),
+		"← this pack",
+		"[1 tool]",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("expected %q in text lineage:\n%s", want, text)
+		}
+	}
+
+	dot := l.DOT()
+	edge := fmt.Sprintf("%q -> %q [label=\"1 tool\"]", store.ShortHash(base.Hash, 12), store.ShortHash(mid.Hash, 12))
+	if !strings.HasPrefix(dot, "digraph lineage {") || !strings.Contains(dot, edge) {
+		t.Errorf("expected edge %s in DOT output:\n%s", edge, dot)
+	}
+
+	mermaid := l.Mermaid()
+	if !strings.HasPrefix(mermaid, "graph TD\n") || !strings.Contains(mermaid, "-->|\"1 tool\"|") {
+		t.Errorf("unexpected Mermaid output:\n%s", mermaid)
+	}
+}
+
+func TestBuildLineageMissingParent(t *testing.T) {
+	root := setupTestStore(t)
+	base := createTestPack(t, root, "original")
+	child := forkWithTool(t, root, base.Hash, "tool_b")
+
+	// Drop the parent from the store, as if the child had been pulled alone
+	hex := store.ShortHash(base.Hash, 64)
+	if err := os.Remove(filepath.Join(root, "objects", hex[:2], hex[2:])); err != nil {
+		t.Fatalf("removing parent blob: %v", err)
+	}
+
+	l, err := BuildLineage(root, child.Hash)
+	if err != nil {
+		t.Fatalf("BuildLineage failed: %v", err)
+	}
+	if !l.Root.Missing || l.Target.Change != "" {
+		t.Errorf("expected a missing root with no change summary, got %+v", l.Root)
+	}
+	if !strings.Contains(l.Text(), "(not in this store)") {
+		t.Errorf("expected the missing parent to be noted:\n%s", l.Text())
+	}
+}
+
+func TestDraftEditAndFinalize(t *testing.T) {
+	root := setupTestStore(t)
+	zero := 0
+	log := &pack.ExecutionLog{
+		Model:        pack.LogModel{Identifier: "test-model", Parameters: map[string]interface{}{}},
+		SystemPrompt: "original",
+		Prompts:      []pack.LogPrompt{{Role: "user", Content: "question"}},
+		Inputs:       []pack.LogInput{{Name: "data.csv", Content: "a,b"}},
+		Steps: []pack.LogStep{
+			{Index: 0, Type: "tool_call", Tool: "search", Parameters: map[string]interface{}{}, Output: "x"},
+			{Index: 1, Type: "tool_call", Tool: "noise", Parameters: map[string]interface{}{}, Output: "y"},
+			{Index: 2, Type: "tool_call", Tool: "read", Parameters: map[string]interface{}{"limit": float64(1)}, Output: "z", Inputs: []pack.LogStepInput{{Step: &zero}}},
+		},
+		Environment: pack.LogEnvironment{OS: "linux", Runtime: "go1.22", ToolVersions: map[string]string{}},
+	}
+	p, err := pack.CreatePack(root, log)
+	if err != nil {
+		t.Fatalf("CreatePack failed: %v", err)
+	}
+	pack.RegisterPack(root, p.Hash)
+
+	draftPath, err := Fork(root, p.Hash)
+	if err != nil {
+		t.Fatalf("Fork failed: %v", err)
+	}
+	resolved, err := ResolveDraft(root, store.ShortHash(p.Hash, 6))
+	if err != nil || resolved != draftPath {
+		t.Fatalf("expected the parent hash prefix to resolve to %s, got %s (%v)", draftPath, resolved, err)
+	}
+
+	draft, err := LoadDraft(draftPath)
+	if err != nil {
+		t.Fatalf("LoadDraft failed: %v", err)
+	}
+	if err := SetSystemPrompt(root, draft, []byte("revised")); err != nil {
+		t.Fatalf("SetSystemPrompt failed: %v", err)
+	}
+	if err := SetPrompt(root, draft, 0, []byte("better question")); err != nil {
+		t.Fatalf("SetPrompt failed: %v", err)
+	}
+	if err := ReplaceInput(root, draft, "data.csv", []byte("a,b,c")); err != nil {
+		t.Fatalf("ReplaceInput failed: %v", err)
+	}
+	if err := SetParam(root, draft, 2, "limit", "5"); err != nil {
+		t.Fatalf("SetParam failed: %v", err)
+	}
+	if err := DropStep(draft, 0); err == nil {
+		t.Error("expected dropping a consumed step to fail")
+	}
+	if err := DropStep(draft, 1); err != nil {
+		t.Fatalf("DropStep failed: %v", err)
+	}
+	if err := SaveDraft(draftPath, draft); err != nil {
+		t.Fatalf("SaveDraft failed: %v", err)
+	}
+
+	drafts, err := ListDrafts(root)
+	if err != nil || len(drafts) != 1 || drafts[0].Steps != 2 {
+		t.Fatalf("expected one draft with 2 steps, got %+v (%v)", drafts, err)
+	}
+
+	final, err := FinalizeDraft(root, draftPath)
+	if err != nil {
+		t.Fatalf("FinalizeDraft failed: %v", err)
+	}
+	loaded, err := pack.LoadPack(root, final.Hash)
+	if err != nil {
+		t.Fatalf("LoadPack failed: %v", err)
+	}
+
+	sys, _ := store.ReadBlob(root, loaded.SystemPrompt)
+	prompt, _ := store.ReadBlob(root, loaded.Prompts[0].ContentRef)
+	if string(sys) != "revised" || string(prompt) != "better question" {
+		t.Errorf("expected edited prompts, got %q and %q", sys, prompt)
+	}
+	if loaded.Inputs[0].Size != 5 {
+		t.Errorf("expected the replaced input's size to be updated, got %d", loaded.Inputs[0].Size)
+	}
+	if len(loaded.Steps) != 2 || loaded.Steps[1].Tool != "read" || loaded.Steps[1].Index != 1 {
+		t.Fatalf("expected the read step renumbered to 1, got %+v", loaded.Steps)
+	}
+	if deps := loaded.Steps[1].DependsOn(); len(deps) != 1 || deps[0] != 0 {
+		t.Errorf("expected the read step to still consume step 0, got %v", deps)
+	}
+	if loaded.Steps[1].Parameters["limit"] != json.Number("5") {
+		t.Errorf("expected limit parsed as a number, got %#v", loaded.Steps[1].Parameters["limit"])
+	}
+}
+
+func TestDraftOneBasedStepIndices(t *testing.T) {
+	root := setupTestStore(t)
+	one, three := 1, 3
+	log := &pack.ExecutionLog{
+		Model:   pack.LogModel{Identifier: "test-model", Parameters: map[string]interface{}{}},
+		Prompts: []pack.LogPrompt{{Role: "user", Content: "question"}},
+		Steps: []pack.LogStep{
+			{Index: 1, Type: "tool_call", Tool: "search", Parameters: map[string]interface{}{}, Output: "x"},
+			{Index: 2, Type: "tool_call", Tool: "noise", Parameters: map[string]interface{}{}, Output: "y"},
+			{Index: 3, Type: "tool_call", Tool: "read", Parameters: map[string]interface{}{}, Output: "z", Inputs: []pack.LogStepInput{{Step: &one}}},
+			{Index: 4, Type: "tool_call", Tool: "write", Parameters: map[string]interface{}{}, Output: "w", Inputs: []pack.LogStepInput{{Step: &three}}},
+		},
+		Environment: pack.LogEnvironment{OS: "linux", Runtime: "go1.22", ToolVersions: map[string]string{}},
+	}
+	p, err := pack.CreatePack(root, log)
+	if err != nil {
+		t.Fatalf("CreatePack failed: %v", err)
+	}
+	draftPath, err := Fork(root, p.Hash)
+	if err != nil {
+		t.Fatalf("Fork failed: %v", err)
+	}
+	draft, _ := LoadDraft(draftPath)
+	if err := CheckDraft(root, draft); err != nil {
+		t.Fatalf("expected a 1-based draft to pass checks, got %v", err)
+	}
+
+	if err := DropStep(draft, 1); err == nil {
+		t.Error("expected dropping step 1, which step 3 consumes, to fail")
+	}
+	if err := DropStep(draft, 0); err == nil {
+		t.Error("expected dropping a nonexistent index to fail")
+	}
+	if err := DropStep(draft, 2); err != nil {
+		t.Fatalf("DropStep failed: %v", err)
+	}
+	if err := SetParam(root, draft, 3, "path", "out.txt"); err != nil {
+		t.Fatalf("SetParam failed: %v", err)
+	}
+	if err := CheckDraft(root, draft); err != nil {
+		t.Fatalf("expected the edited draft to pass checks, got %v", err)
+	}
+
+	var got []string
+	for _, s := range draft.Steps {
+		got = append(got, fmt.Sprintf("%d:%s%v", s.Index, s.Tool, s.DependsOn()))
+	}
+	if want := "1:search[] 2:read[1] 3:write[2]"; strings.Join(got, " ") != want {
+		t.Errorf("expected %s, got %s", want, strings.Join(got, " "))
+	}
+	if draft.Steps[2].Parameters["path"] != "out.txt" {
+		t.Errorf("expected set-param to address step 3 by index, got %v", draft.Steps[2].Parameters)
+	}
+}
+
+func TestFinalizeDraftStepWithoutOutput(t *testing.T) {
+	root := setupTestStore(t)
+	log := &pack.ExecutionLog{
+		Model:   pack.LogModel{Identifier: "test-model", Parameters: map[string]interface{}{}},
+		Prompts: []pack.LogPrompt{{Role: "user", Content: "question"}},
+		Steps: []pack.LogStep{
+			{Index: 0, Type: "tool_call", Tool: "touch", Parameters: map[string]interface{}{}, Output: ""},
+		},
+		Environment: pack.LogEnvironment{OS: "linux", Runtime: "go1.22", ToolVersions: map[string]string{}},
+	}
+	p, err := pack.CreatePack(root, log)
+	if err != nil {
+		t.Fatalf("CreatePack failed: %v", err)
+	}
+	if p.Steps[0].OutputRef != "" {
+		t.Fatalf("expected no output ref for an empty output, got %q", p.Steps[0].OutputRef)
+	}
+	draftPath, err := Fork(root, p.Hash)
+	if err != nil {
+		t.Fatalf("Fork failed: %v", err)
+	}
+	if _, err := FinalizeDraft(root, draftPath); err != nil {
+		t.Fatalf("expected a step without output to finalize, got %v", err)
+	}
+}
+
+func TestDraftEditsRedacted(t *testing.T) {
+	root := setupTestStore(t)
+	log := &pack.ExecutionLog{
+		Model:        pack.LogModel{Identifier: "test-model", Parameters: map[string]interface{}{}},
+		SystemPrompt: "mail alice@example.com",
+		Prompts:      []pack.LogPrompt{{Role: "user", Content: "question"}},
+		Steps: []pack.LogStep{
+			{Index: 0, Type: "tool_call", Tool: "login", Parameters: map[string]interface{}{"token": "s3cr3t"}, Output: "ok"},
+			{Index: 1, Type: "tool_call", Tool: "fetch", Parameters: map[string]interface{}{}, Output: "data"},
+		},
+		Environment: pack.LogEnvironment{OS: "linux", Runtime: "go1.22", ToolVersions: map[string]string{}},
+	}
+	p, err := pack.CreatePack(root, log)
+	if err != nil {
+		t.Fatalf("CreatePack failed: %v", err)
+	}
+	draftPath, err := Fork(root, p.Hash)
+	if err != nil {
+		t.Fatalf("Fork failed: %v", err)
+	}
+	draft, _ := LoadDraft(draftPath)
+
+	if err := SetSystemPrompt(root, draft, []byte("no secrets here")); err != nil {
+		t.Fatalf("SetSystemPrompt failed: %v", err)
+	}
+	if err := SetPrompt(root, draft, 0, []byte("ask bob@example.com")); err != nil {
+		t.Fatalf("SetPrompt failed: %v", err)
+	}
+	if err := SetParam(root, draft, 1, "api_key", "hunter2"); err != nil {
+		t.Fatalf("SetParam failed: %v", err)
+	}
+	if err := DropStep(draft, 0); err != nil {
+		t.Fatalf("DropStep failed: %v", err)
+	}
+	SaveDraft(draftPath, draft)
+	final, err := FinalizeDraft(root, draftPath)
+	if err != nil {
+		t.Fatalf("FinalizeDraft failed: %v", err)
+	}
+
+	prompt, _ := store.ReadBlob(root, final.Prompts[0].ContentRef)
+	if strings.Contains(string(prompt), "bob@") {
+		t.Errorf("expected the edited prompt redacted, got %q", prompt)
+	}
+	if v := final.Steps[0].Parameters["api_key"]; v == "hunter2" || !final.Steps[0].ParamsRedacted {
+		t.Errorf("expected the new parameter redacted and marked, got %v", final.Steps[0])
+	}
+	want := []pack.Redaction{
+		{Field: "prompts[0]", Detector: "email", Count: 1},
+		{Field: "steps[0].parameters", Detector: "sensitive_parameter", Count: 1},
+	}
+	if !reflect.DeepEqual(final.Redactions, want) {
+		t.Errorf("expected redactions %v, got %v", want, final.Redactions)
+	}
+}
+
+func TestFinalizeDraftRejectsMissingBlob(t *testing.T) {
+	root := setupTestStore(t)
+	p := createTestPack(t, root, "original")
+
+	draftPath, _ := Fork(root, p.Hash)
+	draft, _ := LoadDraft(draftPath)
+	draft.Steps[0].OutputRef = "sha256:" + strings.Repeat("0", 64)
+	SaveDraft(draftPath, draft)
+
+	_, err := FinalizeDraft(root, draftPath)
+	if err == nil || !strings.Contains(err.Error(), "steps[0].output_ref") {
+		t.Fatalf("expected a missing blob error, got %v", err)
+	}
+	if _, err := os.Stat(draftPath); err != nil {
+		t.Error("expected the rejected draft to be kept")
+	}
+}
+
+func TestFinalizeDraftHashIsCanonical(t *testing.T) {
+	root := setupTestStore(t)
+	p := forkWithTool(t, root, createTestPack(t, root, "a <b> & c").Hash, "tool_b")
+
+	loaded, err := pack.LoadPack(root, p.Hash)
+	if err != nil {
+		t.Fatalf("LoadPack failed: %v", err)
+	}
+	h, err := pack.CanonicalHash(loaded)
+	if err != nil {
+		t.Fatalf("CanonicalHash failed: %v", err)
+	}
+	if h != p.Hash {
+		t.Errorf("expected finalized hash %s to match the canonical hash %s", p.Hash, h)
+	}
+}
+
+func TestDraftEditsEncryptedPack(t *testing.T) {
+	root := setupTestStore(t)
+	key, _ := store.GenerateKey(root)
+	log := &pack.ExecutionLog{
+		Model:        pack.LogModel{Identifier: "test-model"},
+		SystemPrompt: "test prompt",
+		Prompts:      []pack.LogPrompt{{Role: "user", Content: "test"}},
+		Environment:  pack.LogEnvironment{OS: "darwin", Runtime: "go1.22"},
+	}
+	p, err := pack.CreatePackWithKey(root, log, key.ID)
+	if err != nil {
+		t.Fatalf("CreatePackWithKey failed: %v", err)
+	}
+	pack.RegisterPack(root, p.Hash)
+
+	draftPath, err := Fork(root, p.Hash)
+	if err != nil {
+		t.Fatalf("Fork failed: %v", err)
+	}
+	draft, _ := LoadDraft(draftPath)
+	if err := SetPrompt(root, draft, 0, []byte("edited prompt")); err != nil {
+		t.Fatalf("SetPrompt failed: %v", err)
+	}
+	if id, _ := store.ObjectKeyID(root, draft.Prompts[0].ContentRef); id != key.ID {
+		t.Errorf("expected the edit encrypted with %s, got %q", key.ID, id)
+	}
+}
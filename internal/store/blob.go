@@ -1,6 +1,7 @@
 package store
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -22,17 +23,37 @@ func blobPath(root string, ref string) (string, error) {
 // WriteBlob stores content in the object store. Returns the content hash.
 // Deduplication: if a blob with the same hash already exists, the write is skipped.
 // Immutability: existing blobs are never overwritten.
+// Content larger than ChunkThreshold is stored in chunks.
 func WriteBlob(root string, data []byte) (string, error) {
-	ref := HashContent(data)
+	return WriteBlobWithKey(root, data, nil)
+}
+
+// WriteBlobWithKey stores content as WriteBlob does, encrypting it with key
+// unless key is nil. The hash is of the plaintext, so it is the same
+// whether or not the content is encrypted. Content the store already holds
+// is not rewritten, encrypted or not.
+func WriteBlobWithKey(root string, data []byte, key *Key) (string, error) {
+	if len(data) > ChunkThreshold {
+		ref, _, err := writeChunked(root, bytes.NewReader(data), key)
+		return ref, err
+	}
+	return writeObject(root, HashContent(data), data, key)
+}
 
+// writeObject stores data as the object for ref, compressing it if the
+// store is configured to and then encrypting it with key if one is given.
+// An object that already exists is kept, except that one stored in plain is
+// re-encrypted when key is given, so encrypted content never stays on disk
+// in plain.
+func writeObject(root, ref string, data []byte, key *Key) (string, error) {
 	path, err := blobPath(root, ref)
 	if err != nil {
 		return "", fmt.Errorf("computing blob path: %w", err)
 	}
 
-	// Deduplication: skip if already exists
-	if _, err := os.Stat(path); err == nil {
-		return ref, nil
+	// Deduplication: skip if already exists, loose or in a packfile
+	if BlobExists(root, ref) {
+		return ref, reuseObject(root, ref, path, data, key)
 	}
 
 	// Create parent directory
@@ -41,6 +62,11 @@ func WriteBlob(root string, data []byte) (string, error) {
 		return "", fmt.Errorf("creating blob directory: %w", err)
 	}
 
+	data, err = encodeObject(root, ref, data, key)
+	if err != nil {
+		return "", err
+	}
+
 	// Write atomically: write to temp file then rename
 	tmp := path + ".tmp"
 	if err := os.WriteFile(tmp, data, 0444); err != nil {
@@ -54,36 +80,165 @@ func WriteBlob(root string, data []byte) (string, error) {
 	return ref, nil
 }
 
+// encodeObject compresses data if the store is configured to, then encrypts
+// it with key unless key is nil.
+func encodeObject(root, ref string, data []byte, key *Key) ([]byte, error) {
+	codec, err := configuredCompression(root)
+	if err != nil {
+		return nil, err
+	}
+	compressed, err := compressObject(data, codec)
+	if err != nil {
+		return nil, fmt.Errorf("compressing blob: %w", err)
+	}
+	if compressed != nil {
+		data = compressed
+	}
+	if key != nil {
+		if data, err = encryptObject(key, ref, data); err != nil {
+			return nil, fmt.Errorf("encrypting blob: %w", err)
+		}
+	}
+	return data, nil
+}
+
+// reuseObject reconciles an existing object with a write of the same
+// content. A plain object written again with a key is replaced by an
+// encrypted loose copy, which shadows a packed one until ctx repack --all
+// drops it. An encrypted object
+// written again without a key stays encrypted, as other packs rely on that,
+// but the write is refused if this store cannot decrypt it.
+func reuseObject(root, ref, path string, data []byte, key *Key) error {
+	stored, err := readStored(root, ref)
+	if err != nil {
+		return err
+	}
+	id := storedKeyID(ref, stored)
+	switch {
+	case key != nil && id == "":
+		encoded, err := encodeObject(root, ref, data, key)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("creating blob directory: %w", err)
+		}
+		if err := replaceFile(path, encoded); err != nil {
+			return fmt.Errorf("encrypting existing blob %s: %w", ShortHash(ref, 12), err)
+		}
+	case key == nil && id != "":
+		if _, err := LoadKey(root, id); err != nil {
+			return fmt.Errorf("blob %s is already stored encrypted: %w", ShortHash(ref, 12), err)
+		}
+	}
+	return nil
+}
+
 // ReadBlob reads a blob from the object store and verifies its integrity.
+// Compressed objects are decompressed and chunked blobs are reassembled.
 func ReadBlob(root string, ref string) ([]byte, error) {
-	path, err := blobPath(root, ref)
+	data, err := ReadObject(root, ref)
 	if err != nil {
 		return nil, err
 	}
 
-	data, err := os.ReadFile(path)
+	// Verify integrity
+	actual := HashContent(data)
+	if actual == ref {
+		return data, nil
+	}
+	if list, ok := ParseChunkList(data); ok {
+		return readChunked(root, ref, list)
+	}
+	return nil, fmt.Errorf("blob integrity check failed: expected %s, got %s", ShortHash(ref, 12), ShortHash(actual, 12))
+}
+
+// ReadObject reads the object stored for ref, loose or else from a
+// packfile, decrypting and decompressing it but not verifying it or
+// reassembling chunks.
+func ReadObject(root, ref string) ([]byte, error) {
+	data, err := readStored(root, ref)
+	if err != nil {
+		return nil, err
+	}
+	return decodeStored(root, ref, data)
+}
+
+// readStored reads the bytes stored for ref, loose or else from a packfile.
+func readStored(root, ref string) ([]byte, error) {
+	path, err := blobPath(root, ref)
 	if err != nil {
-		if os.IsNotExist(err) {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		var found bool
+		data, found, err = readPacked(root, ref)
+		if err == nil && !found {
 			return nil, fmt.Errorf("blob not found: %s", ShortHash(ref, 12))
 		}
+	}
+	if err != nil {
 		return nil, fmt.Errorf("reading blob: %w", err)
 	}
+	return data, nil
+}
 
-	// Verify integrity
-	actual := HashContent(data)
-	if actual != ref {
-		return nil, fmt.Errorf("blob integrity check failed: expected %s, got %s", ShortHash(ref, 12), ShortHash(actual, 12))
+// decodeStored decrypts and decompresses the bytes stored for ref. Content
+// that already matches ref is returned as is at each layer, since plain
+// content may start with the same bytes as an object header.
+func decodeStored(root, ref string, data []byte) ([]byte, error) {
+	if HashContent(data) == ref {
+		return data, nil
+	}
+	if isEncrypted(data) {
+		var err error
+		if data, err = decryptObject(root, ref, data); err != nil {
+			return nil, err
+		}
+		if HashContent(data) == ref {
+			return data, nil
+		}
+	}
+	if isCompressed(data) {
+		return decompressObject(data)
 	}
-
 	return data, nil
 }
 
 // BlobExists checks whether a blob exists in the object store without reading it.
+// Loose objects are checked first, then packfiles.
 func BlobExists(root string, ref string) bool {
 	path, err := blobPath(root, ref)
 	if err != nil {
 		return false
 	}
-	_, err = os.Stat(path)
-	return err == nil
+	if _, err := os.Stat(path); err == nil {
+		return true
+	}
+	return isPacked(root, ref)
+}
+
+// RestoreBlob stores data as a loose object even if a packfile already holds
+// it. Loose objects are read first, so a good copy restored this way
+// shadows a damaged packed one until the next ctx repack --all. The copy is
+// encrypted with key unless key is nil.
+func RestoreBlob(root string, data []byte, key *Key) (string, error) {
+	ref := HashContent(data)
+	path, err := blobPath(root, ref)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("creating blob directory: %w", err)
+	}
+	if key != nil {
+		if data, err = encryptObject(key, ref, data); err != nil {
+			return "", fmt.Errorf("encrypting blob: %w", err)
+		}
+	}
+	if err := replaceFile(path, data); err != nil {
+		return "", fmt.Errorf("writing blob: %w", err)
+	}
+	return ref, nil
 }
@@ -2,9 +2,15 @@ package store
 
 import (
 	"bytes"
+	"encoding/binary"
+	"errors"
+	"math/rand"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/klauspost/compress/zstd"
 )
 
 func setupTestStore(t *testing.T) string {
@@ -116,3 +122,212 @@ func TestBlobExists(t *testing.T) {
 		t.Error("expected blob not to exist")
 	}
 }
+
+// randomBytes returns n bytes from a fixed seed, so chunk boundaries are
+// the same on every run.
+func randomBytes(n int, seed int64) []byte {
+	data := make([]byte, n)
+	rand.New(rand.NewSource(seed)).Read(data)
+	return data
+}
+
+func TestWriteBlobChunksLargeContent(t *testing.T) {
+	root := setupTestStore(t)
+	data := randomBytes(3*ChunkThreshold, 1)
+
+	ref, err := WriteBlob(root, data)
+	if err != nil {
+		t.Fatalf("WriteBlob failed: %v", err)
+	}
+	if ref != HashContent(data) {
+		t.Errorf("expected the hash of the whole content, got %s", ref)
+	}
+
+	raw, _ := ReadObject(root, ref)
+	list, ok := ParseChunkList(raw)
+	if !ok {
+		t.Fatal("expected a chunk list object")
+	}
+	if list.Size != int64(len(data)) || len(list.Chunks) < 3 {
+		t.Errorf("expected at least 3 chunks totalling %d bytes, got %d chunks of %d", len(data), len(list.Chunks), list.Size)
+	}
+	for _, c := range list.Chunks {
+		if c.Size < MinChunkSize && c != list.Chunks[len(list.Chunks)-1] || c.Size > MaxChunkSize {
+			t.Errorf("chunk size %d out of range", c.Size)
+		}
+	}
+
+	got, err := ReadBlob(root, ref)
+	if err != nil {
+		t.Fatalf("ReadBlob failed: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("reassembled content differs")
+	}
+}
+
+func TestChunkedBlobsShareChunks(t *testing.T) {
+	root := setupTestStore(t)
+	data := randomBytes(4*ChunkThreshold, 2)
+	ref1, _ := WriteBlob(root, data)
+
+	// The same content with a few bytes inserted near the start
+	edited := append(append(append([]byte{}, data[:100000]...), "inserted"...), data[100000:]...)
+	ref2, _, err := WriteBlobFrom(root, bytes.NewReader(edited))
+	if err != nil {
+		t.Fatalf("WriteBlobFrom failed: %v", err)
+	}
+
+	chunks := func(ref string) map[string]bool {
+		raw, _ := ReadObject(root, ref)
+		list, _ := ParseChunkList(raw)
+		set := map[string]bool{}
+		for _, c := range list.Chunks {
+			set[c.Ref] = true
+		}
+		return set
+	}
+	a, b := chunks(ref1), chunks(ref2)
+	shared := 0
+	for ref := range b {
+		if a[ref] {
+			shared++
+		}
+	}
+	if shared < len(b)-2 {
+		t.Errorf("expected all but the edited chunks to be shared, got %d of %d", shared, len(b))
+	}
+
+	got, err := ReadBlob(root, ref2)
+	if err != nil || !bytes.Equal(got, edited) {
+		t.Errorf("expected the edited content back, got error %v", err)
+	}
+}
+
+func TestWriteBlobFromSmallContent(t *testing.T) {
+	root := setupTestStore(t)
+	ref, size, err := WriteBlobFrom(root, strings.NewReader("small"))
+	if err != nil {
+		t.Fatalf("WriteBlobFrom failed: %v", err)
+	}
+	if ref != HashContent([]byte("small")) || size != 5 {
+		t.Errorf("unexpected ref %s size %d", ref, size)
+	}
+	raw, _ := ReadObject(root, ref)
+	if string(raw) != "small" {
+		t.Errorf("expected small content stored whole, got %q", raw)
+	}
+}
+
+func TestReadBlobMissingChunk(t *testing.T) {
+	root := setupTestStore(t)
+	ref, _ := WriteBlob(root, randomBytes(2*ChunkThreshold, 3))
+	raw, _ := ReadObject(root, ref)
+	list, _ := ParseChunkList(raw)
+	path, _ := blobPath(root, list.Chunks[0].Ref)
+	os.Remove(path)
+
+	if _, err := ReadBlob(root, ref); err == nil || !strings.Contains(err.Error(), "blob not found") {
+		t.Errorf("expected a missing chunk error, got %v", err)
+	}
+}
+
+func TestCompressedBlobs(t *testing.T) {
+	root := setupTestStore(t)
+	if err := WriteConfig(root, &Config{Version: ConfigVersion, Compression: CompressionZstd}); err != nil {
+		t.Fatalf("WriteConfig failed: %v", err)
+	}
+	data := []byte(strings.Repeat("the same tool output, over and over\n", 200))
+
+	ref, err := WriteBlob(root, data)
+	if err != nil {
+		t.Fatalf("WriteBlob failed: %v", err)
+	}
+	if ref != HashContent(data) {
+		t.Errorf("expected the hash of the uncompressed content, got %s", ref)
+	}
+	path, _ := blobPath(root, ref)
+	raw, _ := os.ReadFile(path)
+	if !isCompressed(raw) || len(raw) >= len(data) {
+		t.Errorf("expected a compressed object, got %d bytes", len(raw))
+	}
+
+	got, err := ReadBlob(root, ref)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Errorf("expected the original content back, got error %v", err)
+	}
+
+	// Incompressible content is stored as is
+	random := randomBytes(4096, 4)
+	ref, _ = WriteBlob(root, random)
+	path, _ = blobPath(root, ref)
+	if raw, _ := os.ReadFile(path); !bytes.Equal(raw, random) {
+		t.Error("expected incompressible content stored uncompressed")
+	}
+}
+
+func TestHeaderLikeContent(t *testing.T) {
+	root := setupTestStore(t)
+	for _, data := range [][]byte{
+		[]byte(zstdHeader + "not a zstd frame"),
+		[]byte(encryptHeader + "not an encrypted object"),
+	} {
+		ref, err := WriteBlob(root, data)
+		if err != nil {
+			t.Fatalf("WriteBlob failed: %v", err)
+		}
+		got, err := ReadBlob(root, ref)
+		if err != nil || !bytes.Equal(got, data) {
+			t.Errorf("expected %q read back as is, got %q (%v)", data, got, err)
+		}
+	}
+}
+
+func TestCompressedObjectSizeLimit(t *testing.T) {
+	root := setupTestStore(t)
+	ref := HashContent([]byte("claimed content"))
+	path, _ := blobPath(root, ref)
+	os.MkdirAll(filepath.Dir(path), 0755)
+
+	// A zstd frame header claiming far more content than the decoder allows
+	frame := []byte{0x28, 0xb5, 0x2f, 0xfd, 0xe0}
+	frame = binary.LittleEndian.AppendUint64(frame, 4*maxDecompressedSize)
+	if err := os.WriteFile(path, append([]byte(zstdHeader), frame...), 0444); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ReadBlob(root, ref); !errors.Is(err, zstd.ErrDecoderSizeExceeded) {
+		t.Errorf("expected an oversized object to be rejected, got %v", err)
+	}
+}
+
+func TestCompressObjects(t *testing.T) {
+	root := setupTestStore(t)
+	text := []byte(strings.Repeat("compress me\n", 500))
+	textRef, _ := WriteBlob(root, text)
+	chunked := bytes.Repeat(randomBytes(64<<10, 5), 40)
+	chunkedRef, _ := WriteBlob(root, chunked)
+	randomRef, _ := WriteBlob(root, randomBytes(1024, 6))
+
+	stats, err := CompressObjects(root, CompressionZstd)
+	if err != nil {
+		t.Fatalf("CompressObjects failed: %v", err)
+	}
+	if stats.Compressed == 0 || stats.BytesAfter >= stats.BytesBefore {
+		t.Errorf("expected objects compressed, got %+v", stats)
+	}
+	for ref, want := range map[string][]byte{textRef: text, chunkedRef: chunked} {
+		got, err := ReadBlob(root, ref)
+		if err != nil || !bytes.Equal(got, want) {
+			t.Errorf("expected %s readable after compression, got error %v", ShortHash(ref, 12), err)
+		}
+	}
+	if _, err := ReadBlob(root, randomRef); err != nil {
+		t.Errorf("expected the uncompressed blob still readable: %v", err)
+	}
+
+	again, _ := CompressObjects(root, CompressionZstd)
+	if again.Compressed != 0 {
+		t.Errorf("expected nothing left to compress, got %+v", again)
+	}
+}
@@ -0,0 +1,198 @@
+package store
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Blobs larger than ChunkThreshold are split into content-defined chunks of
+// between MinChunkSize and MaxChunkSize bytes, averaging about 256 KiB. Each
+// chunk is stored as an ordinary blob, and the blob's own object holds a
+// chunk list instead of the content, so a blob's hash is the same whether or
+// not it is chunked. Chunk boundaries depend only on nearby content, so
+// nearly identical large files share most of their chunks.
+const (
+	ChunkThreshold = 1 << 20
+	MinChunkSize   = 64 << 10
+	MaxChunkSize   = 1 << 20
+	chunkMask      = 1<<18 - 1
+)
+
+// chunkListHeader starts every chunk list object.
+const chunkListHeader = "ctx-chunks v1\n"
+
+// ChunkList is the content of a chunked blob's object: the parts that
+// concatenate to the blob, in order.
+type ChunkList struct {
+	Size   int64   `json:"size"`
+	Chunks []Chunk `json:"chunks"`
+}
+
+// Chunk is one part of a chunked blob.
+type Chunk struct {
+	Ref  string `json:"ref"`
+	Size int64  `json:"size"`
+}
+
+// ParseChunkList decodes a chunk list object, reporting false if data is not
+// one.
+func ParseChunkList(data []byte) (*ChunkList, bool) {
+	if !bytes.HasPrefix(data, []byte(chunkListHeader)) {
+		return nil, false
+	}
+	var list ChunkList
+	if err := json.Unmarshal(data[len(chunkListHeader):], &list); err != nil {
+		return nil, false
+	}
+	var total int64
+	for _, c := range list.Chunks {
+		if !ValidateHash(c.Ref) || c.Size <= 0 {
+			return nil, false
+		}
+		total += c.Size
+	}
+	if total != list.Size {
+		return nil, false
+	}
+	return &list, true
+}
+
+// WriteBlobFrom stores everything read from r, chunking it if it is larger
+// than ChunkThreshold, without holding more than a chunk in memory. Returns
+// the content hash and size.
+func WriteBlobFrom(root string, r io.Reader) (string, int64, error) {
+	return WriteBlobFromWithKey(root, r, nil)
+}
+
+// WriteBlobFromWithKey stores everything read from r as WriteBlobFrom does,
+// encrypting it with key unless key is nil.
+func WriteBlobFromWithKey(root string, r io.Reader, key *Key) (string, int64, error) {
+	head := make([]byte, ChunkThreshold+1)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", 0, fmt.Errorf("reading blob content: %w", err)
+	}
+	if n <= ChunkThreshold {
+		ref, err := WriteBlobWithKey(root, head[:n], key)
+		return ref, int64(n), err
+	}
+	return writeChunked(root, io.MultiReader(bytes.NewReader(head[:n]), r), key)
+}
+
+// writeChunked stores r as chunks plus a chunk list under the hash of the
+// whole content, encrypting each with key unless key is nil.
+func writeChunked(root string, r io.Reader, key *Key) (string, int64, error) {
+	hasher := sha256.New()
+	list := &ChunkList{}
+	c := &chunker{r: r, buf: make([]byte, MaxChunkSize)}
+	for {
+		chunk, err := c.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", 0, fmt.Errorf("reading blob content: %w", err)
+		}
+		hasher.Write(chunk)
+		ref, err := writeObject(root, HashContent(chunk), chunk, key)
+		if err != nil {
+			return "", 0, err
+		}
+		list.Chunks = append(list.Chunks, Chunk{Ref: ref, Size: int64(len(chunk))})
+		list.Size += int64(len(chunk))
+	}
+
+	data, err := json.Marshal(list)
+	if err != nil {
+		return "", 0, fmt.Errorf("encoding chunk list: %w", err)
+	}
+	ref := hashPrefix + hex.EncodeToString(hasher.Sum(nil))
+	if _, err := writeObject(root, ref, append([]byte(chunkListHeader), data...), key); err != nil {
+		return "", 0, err
+	}
+	return ref, list.Size, nil
+}
+
+// readChunked reassembles a chunked blob and verifies it against ref.
+func readChunked(root, ref string, list *ChunkList) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, c := range list.Chunks {
+		data, err := ReadObject(root, c.Ref)
+		if err != nil {
+			return nil, fmt.Errorf("reading chunk of %s: %w", ShortHash(ref, 12), err)
+		}
+		if actual := HashContent(data); actual != c.Ref {
+			return nil, fmt.Errorf("blob integrity check failed: chunk %s of %s hashes to %s", ShortHash(c.Ref, 12), ShortHash(ref, 12), ShortHash(actual, 12))
+		}
+		buf.Write(data)
+	}
+	data := buf.Bytes()
+	if actual := HashContent(data); actual != ref {
+		return nil, fmt.Errorf("blob integrity check failed: expected %s, got %s", ShortHash(ref, 12), ShortHash(actual, 12))
+	}
+	return data, nil
+}
+
+// chunker splits a stream at content-defined boundaries using a gear hash,
+// which only depends on the last 64 bytes read.
+type chunker struct {
+	r   io.Reader
+	buf []byte
+	n   int
+	eof bool
+}
+
+func (c *chunker) next() ([]byte, error) {
+	if !c.eof && c.n < len(c.buf) {
+		m, err := io.ReadFull(c.r, c.buf[c.n:])
+		c.n += m
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			c.eof = true
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	if c.n == 0 {
+		return nil, io.EOF
+	}
+
+	cut := cutPoint(c.buf[:c.n])
+	chunk := make([]byte, cut)
+	copy(chunk, c.buf[:cut])
+	c.n = copy(c.buf, c.buf[cut:c.n])
+	return chunk, nil
+}
+
+// cutPoint returns the length of the first chunk of data.
+func cutPoint(data []byte) int {
+	if len(data) <= MinChunkSize {
+		return len(data)
+	}
+	var h uint64
+	for i := MinChunkSize; i < len(data); i++ {
+		h = h<<1 + gearTable[data[i]]
+		if h&chunkMask == 0 {
+			return i + 1
+		}
+	}
+	return len(data)
+}
+
+// gearTable holds fixed pseudo-random values, generated with splitmix64 so
+// that chunk boundaries are the same in every build.
+var gearTable = func() [256]uint64 {
+	var t [256]uint64
+	x := uint64(0x6374782d63686e6b)
+	for i := range t {
+		x += 0x9e3779b97f4a7c15
+		z := x
+		z = (z ^ z>>30) * 0xbf58476d1ce4e5b9
+		z = (z ^ z>>27) * 0x94d049bb133111eb
+		t[i] = z ^ z>>31
+	}
+	return t
+}()
@@ -0,0 +1,189 @@
+package store
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression codecs for objects on disk, set by "compression" in
+// config.json.
+const (
+	CompressionNone = "none"
+	CompressionZstd = "zstd"
+)
+
+// zstdHeader starts every zstd-compressed object, ahead of a standard zstd
+// frame.
+const zstdHeader = "ctx-zstd v1\n"
+
+// maxDecompressedSize caps the content a compressed object may expand to, so
+// a corrupt or hostile object fails to decode instead of exhausting memory.
+// Chunking keeps new objects far smaller; the headroom is for large blobs
+// stored before chunking and for the chunk lists of very large blobs.
+const maxDecompressedSize = 1 << 30
+
+var (
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+	zstdErr     error
+)
+
+// zstdCodec returns the shared encoder and decoder, which are safe for
+// concurrent EncodeAll and DecodeAll calls.
+func zstdCodec() (*zstd.Encoder, *zstd.Decoder, error) {
+	zstdOnce.Do(func() {
+		if zstdEncoder, zstdErr = zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1)); zstdErr != nil {
+			return
+		}
+		zstdDecoder, zstdErr = zstd.NewReader(nil, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxMemory(maxDecompressedSize))
+	})
+	return zstdEncoder, zstdDecoder, zstdErr
+}
+
+// compressObject returns data compressed with codec, or nil if compressing
+// would not make it smaller.
+func compressObject(data []byte, codec string) ([]byte, error) {
+	switch codec {
+	case "", CompressionNone:
+		return nil, nil
+	case CompressionZstd:
+	default:
+		return nil, fmt.Errorf("unknown compression %q", codec)
+	}
+	enc, _, err := zstdCodec()
+	if err != nil {
+		return nil, err
+	}
+	out := enc.EncodeAll(data, []byte(zstdHeader))
+	if len(out) >= len(data) {
+		return nil, nil
+	}
+	return out, nil
+}
+
+// isCompressed reports whether object data carries a compression header.
+// Plain content can start with the same bytes, so callers check the data
+// against its hash first.
+func isCompressed(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(zstdHeader))
+}
+
+// decompressObject returns the stored content of a compressed object.
+func decompressObject(data []byte) ([]byte, error) {
+	_, dec, err := zstdCodec()
+	if err != nil {
+		return nil, err
+	}
+	out, err := dec.DecodeAll(data[len(zstdHeader):], nil)
+	if err != nil {
+		return nil, fmt.Errorf("decompressing blob: %w", err)
+	}
+	return out, nil
+}
+
+// compressionSetting caches a store's configured codec, keyed by the
+// config file's modification time so edits are picked up.
+type compressionSetting struct {
+	modTime time.Time
+	codec   string
+}
+
+var compressionCache sync.Map
+
+// configuredCompression returns the codec new objects are written with.
+func configuredCompression(root string) (string, error) {
+	info, err := os.Stat(ConfigPath(root))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("reading config: %w", err)
+	}
+	if c, ok := compressionCache.Load(root); ok && c.(compressionSetting).modTime.Equal(info.ModTime()) {
+		return c.(compressionSetting).codec, nil
+	}
+	cfg, err := LoadConfig(root)
+	if err != nil {
+		return "", err
+	}
+	compressionCache.Store(root, compressionSetting{modTime: info.ModTime(), codec: cfg.Compression})
+	return cfg.Compression, nil
+}
+
+// CompressStats summarizes a CompressObjects run.
+type CompressStats struct {
+	Objects     int   `json:"objects"`
+	Compressed  int   `json:"compressed"`
+	BytesBefore int64 `json:"bytes_before"`
+	BytesAfter  int64 `json:"bytes_after"`
+}
+
+// CompressObjects rewrites every uncompressed loose object with codec where
+// that makes it smaller. Each object is verified before it is replaced.
+func CompressObjects(root, codec string) (*CompressStats, error) {
+	stats := &CompressStats{}
+	objects := filepath.Join(root, "objects")
+	err := filepath.WalkDir(objects, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == objects {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasSuffix(path, ".tmp") {
+			return nil
+		}
+		ref := hashPrefix + filepath.Base(filepath.Dir(path)) + d.Name()
+		if !ValidateHash(ref) {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", ShortHash(ref, 12), err)
+		}
+		stats.Objects++
+		stats.BytesBefore += int64(len(data))
+		stats.BytesAfter += int64(len(data))
+		if HashContent(data) != ref {
+			if _, ok := ParseChunkList(data); !ok {
+				return nil // Already compressed, encrypted, or corrupt and left for fsck
+			}
+		}
+
+		compressed, err := compressObject(data, codec)
+		if err != nil || compressed == nil {
+			return err
+		}
+		if err := replaceFile(path, compressed); err != nil {
+			return fmt.Errorf("compressing %s: %w", ShortHash(ref, 12), err)
+		}
+		stats.Compressed++
+		stats.BytesAfter -= int64(len(data) - len(compressed))
+		return nil
+	})
+	if err != nil {
+		return stats, fmt.Errorf("compressing objects: %w", err)
+	}
+	return stats, nil
+}
+
+// replaceFile atomically replaces a read-only object file.
+func replaceFile(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0444); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ConfigVersion is the store format version written by this build. Older
+// stores are brought up to it by ctx migrate.
+const ConfigVersion = "0.2"
+
+// Config is the store-wide configuration kept in .ctx/config.json.
+type Config struct {
+	Version string `json:"version"`
+
+	// Prices maps model identifiers to token prices, used for cost reporting.
+	// A key ending in "*" matches any model identifier with that prefix.
+	Prices map[string]ModelPrice `json:"prices,omitempty"`
+
+	// Compression is the codec new objects are written with: "zstd", or
+	// "none" (the default). Objects are readable whatever the setting.
+	Compression string `json:"compression,omitempty"`
+
+	// EncryptionKey is the ID of the key in .ctx/keys that new packs are
+	// encrypted with. Packs are not encrypted when it is empty.
+	EncryptionKey string `json:"encryption_key,omitempty"`
+
+	// Redaction configures the secret and personal data redaction applied
+	// when packs are created. It is on by default.
+	Redaction *RedactionConfig `json:"redaction,omitempty"`
+
+	// Remote is another store's .ctx directory that ctx fsck --fetch copies
+	// missing blobs from, such as a shared drive or a mirror.
+	Remote string `json:"remote,omitempty"`
+}
+
+// RedactionConfig turns redaction off, skips built-in detectors by name, or
+// adds detectors of its own.
+type RedactionConfig struct {
+	Disabled bool            `json:"disabled,omitempty"`
+	Disable  []string        `json:"disable,omitempty"`
+	Rules    []RedactionRule `json:"rules,omitempty"`
+}
+
+// RedactionRule is a user-defined detector: matches of the regular
+// expression Pattern are redacted under Name.
+type RedactionRule struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+}
+
+// ModelPrice is the price of a model's tokens in USD per million tokens.
+// Cached tokens are counted separately from prompt tokens and billed at the
+// Cached rate, or at the Prompt rate when Cached is zero.
+type ModelPrice struct {
+	Prompt     float64 `json:"prompt"`
+	Completion float64 `json:"completion"`
+	Cached     float64 `json:"cached,omitempty"`
+}
+
+// DefaultConfig returns the configuration written for a new store.
+func DefaultConfig() *Config {
+	return &Config{Version: ConfigVersion}
+}
+
+// ConfigPath returns the path to a store's config.json.
+func ConfigPath(root string) string {
+	return filepath.Join(root, "config.json")
+}
+
+// LoadConfig reads a store's config.json. A missing file yields the default config.
+func LoadConfig(root string) (*Config, error) {
+	data, err := os.ReadFile(ConfigPath(root))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return DefaultConfig(), nil
+		}
+		return nil, fmt.Errorf("reading config: %w", err)
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing config: %w", err)
+	}
+	if cmp, err := CompareVersions(cfg.Version, ConfigVersion); err == nil && cmp > 0 {
+		return nil, fmt.Errorf("store version %s is newer than this build of ctx supports (%s); upgrade ctx", cfg.Version, ConfigVersion)
+	}
+	return &cfg, nil
+}
+
+// WriteConfig writes a store's config.json.
+func WriteConfig(root string, cfg *Config) error {
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling config: %w", err)
+	}
+	if err := os.WriteFile(ConfigPath(root), data, 0644); err != nil {
+		return fmt.Errorf("writing config: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,267 @@
+package store
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// KeyDir holds encryption keys, relative to the store. Each key is a file
+// named <id>.key holding 32 hex-encoded random bytes.
+const KeyDir = "keys"
+
+// encryptHeader starts every encrypted object. It is followed by the key ID
+// and a newline, the data key sealed with that key, and the object sealed
+// with the data key.
+const encryptHeader = "ctx-aesgcm v1\n"
+
+// ErrKeyUnavailable is returned when content is encrypted with a key the
+// store does not hold.
+var ErrKeyUnavailable = errors.New("encryption key not available")
+
+// Key is an AES-256 key used to seal the data keys of encrypted objects.
+// Its ID is derived from the key, so it can be recorded without revealing it.
+type Key struct {
+	ID     string
+	secret []byte
+}
+
+func newKey(secret []byte) *Key {
+	sum := sha256.Sum256(secret)
+	return &Key{ID: hex.EncodeToString(sum[:8]), secret: secret}
+}
+
+func keyPath(root, id string) string {
+	return filepath.Join(root, KeyDir, id+".key")
+}
+
+// GenerateKey creates a random key and saves it in the store's key directory.
+func GenerateKey(root string) (*Key, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("generating key: %w", err)
+	}
+	key := newKey(secret)
+	if err := os.MkdirAll(filepath.Join(root, KeyDir), 0700); err != nil {
+		return nil, fmt.Errorf("creating key directory: %w", err)
+	}
+	if err := os.WriteFile(keyPath(root, key.ID), []byte(hex.EncodeToString(secret)+"\n"), 0600); err != nil {
+		return nil, fmt.Errorf("writing key: %w", err)
+	}
+	return key, nil
+}
+
+// LoadKey reads a key from the store's key directory. A key that is not
+// there yields an error wrapping ErrKeyUnavailable.
+func LoadKey(root, id string) (*Key, error) {
+	data, err := os.ReadFile(keyPath(root, id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: %s (expected in %s)", ErrKeyUnavailable, id, filepath.Join(root, KeyDir))
+		}
+		return nil, fmt.Errorf("reading key: %w", err)
+	}
+	secret, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(secret) != 32 {
+		return nil, fmt.Errorf("key %s is not 32 hex-encoded bytes", id)
+	}
+	key := newKey(secret)
+	if key.ID != id {
+		return nil, fmt.Errorf("key file %s.key holds key %s", id, key.ID)
+	}
+	return key, nil
+}
+
+// ListKeys returns the IDs of the keys in the store's key directory, sorted.
+func ListKeys(root string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(root, KeyDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading key directory: %w", err)
+	}
+	var ids []string
+	for _, e := range entries {
+		if id, ok := strings.CutSuffix(e.Name(), ".key"); ok && !e.IsDir() {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// encryptObject seals stored object data for ref under a fresh data key,
+// which is itself sealed with key. The ref is authenticated with the
+// content, so an encrypted object cannot be moved to another name.
+func encryptObject(key *Key, ref string, data []byte) ([]byte, error) {
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+	sealedKey, err := seal(key.secret, dataKey, []byte(key.ID))
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := seal(dataKey, data, []byte(ref))
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	buf.WriteString(encryptHeader)
+	buf.WriteString(key.ID + "\n")
+	buf.Write(sealedKey)
+	buf.Write(sealed)
+	return buf.Bytes(), nil
+}
+
+// isEncrypted reports whether object data carries an encryption header.
+// Plain content can start with the same bytes, so callers check the data
+// against its hash first.
+func isEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(encryptHeader))
+}
+
+// objectKeyID returns the ID of the key an encrypted object is sealed with.
+func objectKeyID(data []byte) (string, bool) {
+	if !isEncrypted(data) {
+		return "", false
+	}
+	rest := data[len(encryptHeader):]
+	i := bytes.IndexByte(rest, '\n')
+	if i < 0 {
+		return "", false
+	}
+	return string(rest[:i]), true
+}
+
+// decryptObject returns the stored data of an encrypted object, using the
+// key it names from the store's key directory.
+func decryptObject(root, ref string, data []byte) ([]byte, error) {
+	id, ok := objectKeyID(data)
+	if !ok {
+		return nil, fmt.Errorf("malformed encrypted object %s", ShortHash(ref, 12))
+	}
+	key, err := LoadKey(root, id)
+	if err != nil {
+		return nil, fmt.Errorf("blob %s is encrypted: %w", ShortHash(ref, 12), err)
+	}
+	rest := data[len(encryptHeader)+len(id)+1:]
+	sealedKeySize := sealedSize(32)
+	if len(rest) < sealedKeySize {
+		return nil, fmt.Errorf("malformed encrypted object %s", ShortHash(ref, 12))
+	}
+	dataKey, err := open(key.secret, rest[:sealedKeySize], []byte(key.ID))
+	if err != nil {
+		return nil, fmt.Errorf("decrypting blob %s: %w", ShortHash(ref, 12), err)
+	}
+	out, err := open(dataKey, rest[sealedKeySize:], []byte(ref))
+	if err != nil {
+		return nil, fmt.Errorf("decrypting blob %s: %w", ShortHash(ref, 12), err)
+	}
+	return out, nil
+}
+
+// storedKeyID returns the ID of the key the stored bytes of ref are
+// encrypted with, or "" if they are the plain content.
+func storedKeyID(ref string, stored []byte) string {
+	if HashContent(stored) == ref {
+		return ""
+	}
+	id, _ := objectKeyID(stored)
+	return id
+}
+
+// ObjectKeyID returns the ID of the key a stored blob is encrypted with, or
+// "" if it is stored unencrypted.
+func ObjectKeyID(root, ref string) (string, error) {
+	data, err := readStored(root, ref)
+	if err != nil {
+		return "", err
+	}
+	return storedKeyID(ref, data), nil
+}
+
+// BlobKeyIDs returns the IDs of the keys the objects holding a blob are
+// encrypted with: the blob's own object and, for a chunked blob whose chunk
+// list can be read, each of its chunks. IDs may repeat.
+func BlobKeyIDs(root, ref string) ([]string, error) {
+	stored, err := readStored(root, ref)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	if id := storedKeyID(ref, stored); id != "" {
+		ids = append(ids, id)
+	}
+	data, err := decodeStored(root, ref, stored)
+	if errors.Is(err, ErrKeyUnavailable) {
+		return ids, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	list, ok := ParseChunkList(data)
+	if !ok || HashContent(data) == ref {
+		return ids, nil
+	}
+	for _, c := range list.Chunks {
+		chunk, err := readStored(root, c.Ref)
+		if err != nil {
+			return nil, err
+		}
+		if id := storedKeyID(c.Ref, chunk); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// seal encrypts plaintext with AES-256-GCM under a random nonce, returning
+// the nonce followed by the ciphertext.
+func seal(secret, plaintext, additional []byte) ([]byte, error) {
+	gcm, err := newGCM(secret)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, additional), nil
+}
+
+// open reverses seal.
+func open(secret, sealed, additional []byte) ([]byte, error) {
+	gcm, err := newGCM(secret)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	n := gcm.NonceSize()
+	return gcm.Open(nil, sealed[:n], sealed[n:], additional)
+}
+
+// sealedSize is the length seal produces for n bytes of plaintext.
+func sealedSize(n int) int {
+	return 12 + n + 16
+}
+
+func newGCM(secret []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(secret)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,201 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEncryptedBlobs(t *testing.T) {
+	root := setupTestStore(t)
+	key, err := GenerateKey(root)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+
+	content := []byte("patient record 1234")
+	ref, err := WriteBlobWithKey(root, content, key)
+	if err != nil {
+		t.Fatalf("WriteBlobWithKey failed: %v", err)
+	}
+	if ref != HashContent(content) {
+		t.Errorf("expected the hash of the plaintext, got %s", ref)
+	}
+
+	path, _ := blobPath(root, ref)
+	raw, _ := os.ReadFile(path)
+	if bytes.Contains(raw, content) || !isEncrypted(raw) {
+		t.Errorf("expected the object encrypted on disk, got %q", raw)
+	}
+	if id, err := ObjectKeyID(root, ref); err != nil || id != key.ID {
+		t.Errorf("expected key ID %s, got %q, %v", key.ID, id, err)
+	}
+
+	got, err := ReadBlob(root, ref)
+	if err != nil {
+		t.Fatalf("ReadBlob failed: %v", err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Errorf("expected %q, got %q", content, got)
+	}
+
+	// Without the key the content is refused, not misreported as corrupt
+	os.Rename(keyPath(root, key.ID), filepath.Join(t.TempDir(), "moved.key"))
+	if _, err := ReadBlob(root, ref); !errors.Is(err, ErrKeyUnavailable) {
+		t.Errorf("expected ErrKeyUnavailable, got %v", err)
+	}
+}
+
+func TestEncryptedObjectTampering(t *testing.T) {
+	root := setupTestStore(t)
+	key, _ := GenerateKey(root)
+	ref, _ := WriteBlobWithKey(root, []byte("sealed content"), key)
+
+	path, _ := blobPath(root, ref)
+	raw, _ := os.ReadFile(path)
+	raw[len(raw)-1] ^= 0xff
+	os.Chmod(path, 0644)
+	os.WriteFile(path, raw, 0644)
+
+	_, err := ReadBlob(root, ref)
+	if err == nil || errors.Is(err, ErrKeyUnavailable) {
+		t.Errorf("expected a decryption error, got %v", err)
+	}
+}
+
+func TestEncryptedChunkedAndPackedBlobs(t *testing.T) {
+	root := setupTestStore(t)
+	WriteConfig(root, &Config{Version: ConfigVersion, Compression: CompressionZstd})
+	key, _ := GenerateKey(root)
+
+	big := randomBytes(3*ChunkThreshold, 11)
+	bigRef, _, err := WriteBlobFromWithKey(root, bytes.NewReader(big), key)
+	if err != nil {
+		t.Fatalf("WriteBlobFromWithKey failed: %v", err)
+	}
+	small := bytes.Repeat([]byte("compressible\n"), 200)
+	smallRef, _ := WriteBlobWithKey(root, small, key)
+
+	if _, err := PackObjects(root, false); err != nil {
+		t.Fatalf("PackObjects failed: %v", err)
+	}
+	for ref, want := range map[string][]byte{bigRef: big, smallRef: small} {
+		got, err := ReadBlob(root, ref)
+		if err != nil {
+			t.Fatalf("ReadBlob(%s) failed: %v", ShortHash(ref, 12), err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("content of %s changed", ShortHash(ref, 12))
+		}
+	}
+
+	// Packing without the key moves encrypted objects as they are
+	os.Rename(keyPath(root, key.ID), filepath.Join(t.TempDir(), "moved.key"))
+	if _, err := PackObjects(root, true); err != nil {
+		t.Fatalf("PackObjects --all without the key failed: %v", err)
+	}
+	if !BlobExists(root, smallRef) {
+		t.Error("expected the encrypted object to survive repacking")
+	}
+}
+
+func TestEncryptExistingPlainObject(t *testing.T) {
+	root := setupTestStore(t)
+	key, _ := GenerateKey(root)
+	content := []byte("shared system prompt")
+
+	ref, _ := WriteBlob(root, content)
+	if _, err := PackObjects(root, false); err != nil {
+		t.Fatalf("PackObjects failed: %v", err)
+	}
+	if _, err := WriteBlobWithKey(root, content, key); err != nil {
+		t.Fatalf("WriteBlobWithKey failed: %v", err)
+	}
+	if id, _ := ObjectKeyID(root, ref); id != key.ID {
+		t.Fatalf("expected the plain object re-encrypted with %s, got %q", key.ID, id)
+	}
+
+	// Repacking keeps the encrypted copy, not the packed plain one
+	if _, err := PackObjects(root, false); err != nil {
+		t.Fatalf("PackObjects failed: %v", err)
+	}
+	if _, err := PackObjects(root, true); err != nil {
+		t.Fatalf("PackObjects --all failed: %v", err)
+	}
+	if id, _ := ObjectKeyID(root, ref); id != key.ID {
+		t.Errorf("expected the object still encrypted after repacking, got %q", id)
+	}
+	if got, err := ReadBlob(root, ref); err != nil || !bytes.Equal(got, content) {
+		t.Errorf("expected content readable with the key, got %q (%v)", got, err)
+	}
+}
+
+func TestPlainWriteOfEncryptedObject(t *testing.T) {
+	root := setupTestStore(t)
+	key, _ := GenerateKey(root)
+	content := []byte("shared tool output")
+	ref, _ := WriteBlobWithKey(root, content, key)
+
+	// An unencrypted write leaves the object encrypted
+	if _, err := WriteBlob(root, content); err != nil {
+		t.Fatalf("WriteBlob failed: %v", err)
+	}
+	if ids, err := BlobKeyIDs(root, ref); err != nil || len(ids) != 1 || ids[0] != key.ID {
+		t.Errorf("expected key %s reported for the blob, got %v (%v)", key.ID, ids, err)
+	}
+
+	// ...and is refused when the store cannot decrypt it
+	os.Rename(keyPath(root, key.ID), filepath.Join(t.TempDir(), "moved.key"))
+	if _, err := WriteBlob(root, content); !errors.Is(err, ErrKeyUnavailable) {
+		t.Errorf("expected ErrKeyUnavailable, got %v", err)
+	}
+}
+
+func TestEncryptedHeaderLikeContent(t *testing.T) {
+	root := setupTestStore(t)
+	key, _ := GenerateKey(root)
+	content := []byte(zstdHeader + "plain text")
+	ref, _ := WriteBlobWithKey(root, content, key)
+	if got, err := ReadBlob(root, ref); err != nil || !bytes.Equal(got, content) {
+		t.Errorf("expected %q, got %q (%v)", content, got, err)
+	}
+
+	plain := []byte(encryptHeader + "0123456789abcdef\nnot sealed")
+	ref, _ = WriteBlob(root, plain)
+	if id, err := ObjectKeyID(root, ref); err != nil || id != "" {
+		t.Errorf("expected a plain object to report no key, got %q (%v)", id, err)
+	}
+}
+
+func TestLoadKey(t *testing.T) {
+	root := setupTestStore(t)
+	key, _ := GenerateKey(root)
+
+	loaded, err := LoadKey(root, key.ID)
+	if err != nil {
+		t.Fatalf("LoadKey failed: %v", err)
+	}
+	if !bytes.Equal(loaded.secret, key.secret) {
+		t.Error("expected the same key back")
+	}
+	info, _ := os.Stat(keyPath(root, key.ID))
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected key file mode 0600, got %v", info.Mode().Perm())
+	}
+
+	other, _ := GenerateKey(root)
+	os.Rename(keyPath(root, other.ID), keyPath(root, "0000000000000000"))
+	if _, err := LoadKey(root, "0000000000000000"); err == nil {
+		t.Error("expected an error for a key file under the wrong ID")
+	}
+	if _, err := LoadKey(root, "ffffffffffffffff"); !errors.Is(err, ErrKeyUnavailable) {
+		t.Errorf("expected ErrKeyUnavailable, got %v", err)
+	}
+
+	ids, _ := ListKeys(root)
+	if len(ids) != 2 {
+		t.Errorf("expected 2 keys, got %v", ids)
+	}
+}
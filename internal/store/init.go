@@ -1,7 +1,6 @@
 package store
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -11,10 +10,6 @@ import (
 
 const StoreDirName = ".ctx"
 
-type Config struct {
-	Version string `json:"version"`
-}
-
 // InitStore creates a .ctx/ directory with the required subdirectory structure.
 // Returns the path to the created store root.
 func InitStore(dir string) (string, error) {
@@ -39,14 +34,8 @@ func InitStore(dir string) (string, error) {
 	}
 
 	// Create config.json
-	cfg := Config{Version: "0.1"}
-	data, err := json.MarshalIndent(cfg, "", "  ")
-	if err != nil {
-		return "", fmt.Errorf("marshaling config: %w", err)
-	}
-	configPath := filepath.Join(root, "config.json")
-	if err := os.WriteFile(configPath, data, 0644); err != nil {
-		return "", fmt.Errorf("writing config: %w", err)
+	if err := WriteConfig(root, DefaultConfig()); err != nil {
+		return "", err
 	}
 
 	// Initialize context graph directories
@@ -3,6 +3,7 @@ package store
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -45,3 +46,79 @@ func TestInitStoreAlreadyExists(t *testing.T) {
 		t.Error("expected error for already initialized store")
 	}
 }
+
+func TestLoadConfigRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+
+	root, err := InitStore(dir)
+	if err != nil {
+		t.Fatalf("InitStore failed: %v", err)
+	}
+
+	cfg, err := LoadConfig(root)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.Version != ConfigVersion {
+		t.Errorf("expected version %s, got %s", ConfigVersion, cfg.Version)
+	}
+
+	cfg.Prices = map[string]ModelPrice{"gpt-4o*": {Prompt: 2.5, Completion: 10}}
+	if err := WriteConfig(root, cfg); err != nil {
+		t.Fatalf("WriteConfig failed: %v", err)
+	}
+
+	cfg, err = LoadConfig(root)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.Prices["gpt-4o*"].Completion != 10 {
+		t.Errorf("expected prices to round-trip, got %+v", cfg.Prices)
+	}
+}
+
+func TestLoadConfigMissing(t *testing.T) {
+	cfg, err := LoadConfig(t.TempDir())
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.Version != ConfigVersion || cfg.Prices != nil {
+		t.Errorf("expected default config, got %+v", cfg)
+	}
+}
+
+func TestLoadConfigRejectsNewerVersion(t *testing.T) {
+	root := t.TempDir()
+	if err := WriteConfig(root, &Config{Version: "99.0"}); err != nil {
+		t.Fatalf("WriteConfig failed: %v", err)
+	}
+	if _, err := LoadConfig(root); err == nil || !strings.Contains(err.Error(), "newer") {
+		t.Errorf("expected a newer-version error, got %v", err)
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"0.1", "0.1", 0},
+		{"0.1", "0.2", -1},
+		{"0.10", "0.9", 1},
+		{"1", "1.0", 0},
+	}
+	for _, tt := range tests {
+		got, err := CompareVersions(tt.a, tt.b)
+		if err != nil {
+			t.Fatalf("CompareVersions(%q, %q) failed: %v", tt.a, tt.b, err)
+		}
+		if got != tt.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+	for _, bad := range []string{"", "1.x", "-1"} {
+		if _, err := CompareVersions(bad, "0.1"); err == nil {
+			t.Errorf("expected an error for version %q", bad)
+		}
+	}
+}
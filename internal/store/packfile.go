@@ -0,0 +1,526 @@
+package store
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PackfileDir holds packfiles, relative to the store. Each packfile is a
+// pair: <id>.pack, the stored bytes of many objects concatenated after a
+// header, and <id>.idx, their hashes sorted with each object's offset and
+// length in the pack, followed by the SHA-256 of everything before it. The
+// id is the SHA-256 of the .pack file. Lookups binary-search the index,
+// reading only the entries they visit.
+const PackfileDir = "packfiles"
+
+const (
+	packHeader   = "ctx-pack v1\n"
+	idxHeader    = "ctx-idx v1\n"
+	idxEntrySize = sha256.Size + 8 + 8
+)
+
+// packEntry locates one object in a packfile.
+type packEntry struct {
+	hash   [sha256.Size]byte
+	offset uint64
+	length uint64
+}
+
+func (e packEntry) ref() string {
+	return hashPrefix + hex.EncodeToString(e.hash[:])
+}
+
+func packfilePath(root, id, ext string) string {
+	return filepath.Join(root, PackfileDir, id+ext)
+}
+
+// packfileList caches a store's packfile ids, keyed by the packfile
+// directory's modification time, which changes whenever a pack or index is
+// added, renamed or removed.
+type packfileList struct {
+	modTime time.Time
+	ids     []string
+}
+
+var packfileCache sync.Map
+
+// ListPackfiles returns the ids of the store's packfiles, sorted. A pack
+// without its index is not yet, or no longer, part of the store.
+func ListPackfiles(root string) ([]string, error) {
+	dir := filepath.Join(root, PackfileDir)
+	info, err := os.Stat(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading packfiles: %w", err)
+	}
+	if c, ok := packfileCache.Load(root); ok && c.(packfileList).modTime.Equal(info.ModTime()) {
+		return c.(packfileList).ids, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading packfiles: %w", err)
+	}
+	var ids []string
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".idx")
+		if !ok {
+			continue
+		}
+		if _, err := os.Stat(packfilePath(root, id, ".pack")); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	packfileCache.Store(root, packfileList{modTime: info.ModTime(), ids: ids})
+	return ids, nil
+}
+
+// readPacked returns the stored bytes of ref from the first packfile that
+// holds it.
+func readPacked(root, ref string) ([]byte, bool, error) {
+	want, err := hashBytes(ref)
+	if err != nil {
+		return nil, false, err
+	}
+	ids, err := ListPackfiles(root)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, id := range ids {
+		e, found, err := lookupIndex(packfilePath(root, id, ".idx"), want)
+		if err != nil {
+			return nil, false, err
+		}
+		if found {
+			data, err := readPackEntry(packfilePath(root, id, ".pack"), e)
+			return data, true, err
+		}
+	}
+	return nil, false, nil
+}
+
+// isPacked reports whether any packfile holds ref.
+func isPacked(root, ref string) bool {
+	want, err := hashBytes(ref)
+	if err != nil {
+		return false
+	}
+	ids, _ := ListPackfiles(root)
+	for _, id := range ids {
+		if _, found, _ := lookupIndex(packfilePath(root, id, ".idx"), want); found {
+			return true
+		}
+	}
+	return false
+}
+
+func hashBytes(ref string) ([sha256.Size]byte, error) {
+	var h [sha256.Size]byte
+	_, hexStr, err := ParseHash(ref)
+	if err != nil {
+		return h, err
+	}
+	hex.Decode(h[:], []byte(hexStr))
+	return h, nil
+}
+
+// lookupIndex binary-searches an index file for a hash.
+func lookupIndex(path string, want [sha256.Size]byte) (packEntry, bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return packEntry{}, false, fmt.Errorf("opening packfile index: %w", err)
+	}
+	defer f.Close()
+
+	count, err := readIndexHeader(f)
+	if err != nil {
+		return packEntry{}, false, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	var e packEntry
+	var readErr error
+	i := sort.Search(count, func(i int) bool {
+		if readErr != nil {
+			return true
+		}
+		e, readErr = readIndexEntry(f, i)
+		return bytes.Compare(e.hash[:], want[:]) >= 0
+	})
+	if readErr != nil {
+		return packEntry{}, false, fmt.Errorf("%s: %w", filepath.Base(path), readErr)
+	}
+	if i == count {
+		return packEntry{}, false, nil
+	}
+	if e, readErr = readIndexEntry(f, i); readErr != nil {
+		return packEntry{}, false, readErr
+	}
+	return e, e.hash == want, nil
+}
+
+// readIndexHeader returns an index's entry count, checking that the file is
+// exactly the size that count implies.
+func readIndexHeader(f *os.File) (int, error) {
+	buf := make([]byte, len(idxHeader)+4)
+	if _, err := f.ReadAt(buf, 0); err != nil {
+		return 0, fmt.Errorf("reading index header: %w", err)
+	}
+	if string(buf[:len(idxHeader)]) != idxHeader {
+		return 0, fmt.Errorf("not a packfile index")
+	}
+	count := int64(binary.BigEndian.Uint32(buf[len(idxHeader):]))
+	info, err := f.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("reading index header: %w", err)
+	}
+	if want := idxSize(count); info.Size() != want {
+		return 0, fmt.Errorf("index is %d bytes, expected %d for %d entries", info.Size(), want, count)
+	}
+	return int(count), nil
+}
+
+// idxSize is the size of an index file with count entries.
+func idxSize(count int64) int64 {
+	return int64(len(idxHeader)) + 4 + count*idxEntrySize + sha256.Size
+}
+
+func readIndexEntry(f *os.File, i int) (packEntry, error) {
+	var buf [idxEntrySize]byte
+	if _, err := f.ReadAt(buf[:], int64(len(idxHeader)+4+i*idxEntrySize)); err != nil {
+		return packEntry{}, fmt.Errorf("reading index entry %d: %w", i, err)
+	}
+	var e packEntry
+	copy(e.hash[:], buf[:sha256.Size])
+	e.offset = binary.BigEndian.Uint64(buf[sha256.Size:])
+	e.length = binary.BigEndian.Uint64(buf[sha256.Size+8:])
+	return e, nil
+}
+
+// readIndex reads every entry of an index file.
+func readIndex(path string) ([]packEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening packfile index: %w", err)
+	}
+	defer f.Close()
+	count, err := readIndexHeader(f)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]packEntry, count)
+	for i := range entries {
+		if entries[i], err = readIndexEntry(f, i); err != nil {
+			return nil, err
+		}
+		if i > 0 && bytes.Compare(entries[i-1].hash[:], entries[i].hash[:]) >= 0 {
+			return nil, fmt.Errorf("index entries out of order at %d", i)
+		}
+	}
+	return entries, nil
+}
+
+// readPackEntry reads one object from a pack, refusing an entry that does
+// not lie within the pack's objects.
+func readPackEntry(path string, e packEntry) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening packfile: %w", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("opening packfile: %w", err)
+	}
+	size := uint64(info.Size())
+	if e.offset < uint64(len(packHeader)) || e.offset > size || e.length > size-e.offset {
+		return nil, fmt.Errorf("index entry for %s lies outside the %d-byte packfile", ShortHash(e.ref(), 12), size)
+	}
+	data := make([]byte, e.length)
+	if _, err := f.ReadAt(data, int64(e.offset)); err != nil {
+		return nil, fmt.Errorf("reading %s from packfile: %w", ShortHash(e.ref(), 12), err)
+	}
+	return data, nil
+}
+
+// WalkPackfile calls fn with every object in a packfile, decrypted and
+// decompressed but not verified, or with the error reading it.
+func WalkPackfile(root, id string, fn func(ref string, data []byte, err error) error) error {
+	entries, err := readIndex(packfilePath(root, id, ".idx"))
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		data, err := readPackEntry(packfilePath(root, id, ".pack"), e)
+		if err == nil {
+			data, err = decodeStored(root, e.ref(), data)
+		}
+		if err := fn(e.ref(), data, err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// VerifyPackfile checks that a packfile's content hashes to its id and
+// that its index matches its checksum.
+func VerifyPackfile(root, id string) error {
+	f, err := os.Open(packfilePath(root, id, ".pack"))
+	if err != nil {
+		return fmt.Errorf("opening packfile: %w", err)
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return fmt.Errorf("reading packfile: %w", err)
+	}
+	if actual := hex.EncodeToString(h.Sum(nil)); actual != id {
+		return fmt.Errorf("packfile content hashes to %s", actual[:12])
+	}
+
+	idx, err := os.ReadFile(packfilePath(root, id, ".idx"))
+	if err != nil {
+		return fmt.Errorf("reading packfile index: %w", err)
+	}
+	if len(idx) < sha256.Size {
+		return fmt.Errorf("packfile index is truncated")
+	}
+	body := idx[:len(idx)-sha256.Size]
+	if sum := sha256.Sum256(body); !bytes.Equal(sum[:], idx[len(body):]) {
+		return fmt.Errorf("packfile index does not match its checksum")
+	}
+	return nil
+}
+
+// PackStats summarizes a PackObjects run.
+type PackStats struct {
+	Packfile      string `json:"packfile,omitempty"`
+	Objects       int    `json:"objects"`
+	LooseRemoved  int    `json:"loose_removed"`
+	PacksReplaced int    `json:"packs_replaced"`
+}
+
+// PackObjects moves every valid loose object into a new packfile. With all,
+// the objects of existing packfiles are merged into it too and the old
+// packfiles removed. Corrupt loose objects are left in place for fsck;
+// a corrupt packed object aborts the merge.
+func PackObjects(root string, all bool) (*PackStats, error) {
+	defer packfileCache.Delete(root)
+	stats := &PackStats{}
+	dir := filepath.Join(root, PackfileDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("creating packfile directory: %w", err)
+	}
+	oldPacks, err := ListPackfiles(root)
+	if err != nil {
+		return nil, err
+	}
+
+	tmp, err := os.CreateTemp(dir, "pack-*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("creating packfile: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	w := &packWriter{f: tmp, hash: sha256.New(), seen: make(map[[sha256.Size]byte]bool)}
+	if err := w.write([]byte(packHeader)); err != nil {
+		return nil, err
+	}
+
+	if all {
+		for _, id := range oldPacks {
+			entries, err := readIndex(packfilePath(root, id, ".idx"))
+			if err != nil {
+				return nil, fmt.Errorf("packfile %s: %w", id[:12], err)
+			}
+			for _, e := range entries {
+				data, err := readPackEntry(packfilePath(root, id, ".pack"), e)
+				if err != nil {
+					return nil, err
+				}
+				if hasValidLoose(root, e.ref()) {
+					continue // Loose copies shadow packed ones; packed from objects/ below
+				}
+				if !validObject(root, e.ref(), data) {
+					return nil, fmt.Errorf("packfile %s: %s is corrupt (run ctx fsck)", id[:12], ShortHash(e.ref(), 12))
+				}
+				if err := w.add(e.hash, data); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	var loose []string
+	objects := filepath.Join(root, "objects")
+	err = filepath.WalkDir(objects, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == objects {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasSuffix(path, ".tmp") {
+			return nil
+		}
+		ref := hashPrefix + filepath.Base(filepath.Dir(path)) + d.Name()
+		h, err := hashBytes(ref)
+		if err != nil {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", ShortHash(ref, 12), err)
+		}
+		if !validObject(root, ref, data) {
+			return nil
+		}
+		if !all && isPacked(root, ref) {
+			return nil // Shadows the packed copy until ctx repack --all
+		}
+		loose = append(loose, path)
+		return w.add(h, data)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("packing objects: %w", err)
+	}
+
+	if len(w.entries) > 0 {
+		id, err := w.finish(root)
+		if err != nil {
+			return nil, err
+		}
+		stats.Packfile = id
+		stats.Objects = len(w.entries)
+	}
+
+	for _, path := range loose {
+		if err := os.Remove(path); err != nil {
+			return stats, fmt.Errorf("removing packed loose object: %w", err)
+		}
+		os.Remove(filepath.Dir(path)) // Only succeeds once the directory is empty
+		stats.LooseRemoved++
+	}
+	if all {
+		for _, id := range oldPacks {
+			if id == stats.Packfile {
+				continue
+			}
+			os.Remove(packfilePath(root, id, ".idx"))
+			if err := os.Remove(packfilePath(root, id, ".pack")); err != nil {
+				return stats, fmt.Errorf("removing packfile %s: %w", id[:12], err)
+			}
+			stats.PacksReplaced++
+		}
+	}
+	return stats, nil
+}
+
+// validObject reports whether stored object bytes hold the content for ref,
+// or a chunk list. An encrypted object whose key the store lacks cannot be
+// checked and is taken as valid; it is moved, never rewritten.
+func validObject(root, ref string, data []byte) bool {
+	data, err := decodeStored(root, ref, data)
+	if errors.Is(err, ErrKeyUnavailable) {
+		return true
+	}
+	if err != nil {
+		return false
+	}
+	if HashContent(data) == ref {
+		return true
+	}
+	_, ok := ParseChunkList(data)
+	return ok
+}
+
+// hasValidLoose reports whether a loose object holds valid content for ref.
+func hasValidLoose(root, ref string) bool {
+	path, err := blobPath(root, ref)
+	if err != nil {
+		return false
+	}
+	data, err := os.ReadFile(path)
+	return err == nil && validObject(root, ref, data)
+}
+
+// packWriter appends objects to a packfile being built.
+type packWriter struct {
+	f       *os.File
+	hash    hash.Hash
+	offset  uint64
+	entries []packEntry
+	seen    map[[sha256.Size]byte]bool
+}
+
+func (w *packWriter) write(data []byte) error {
+	if _, err := w.f.Write(data); err != nil {
+		return fmt.Errorf("writing packfile: %w", err)
+	}
+	w.hash.Write(data)
+	w.offset += uint64(len(data))
+	return nil
+}
+
+func (w *packWriter) add(h [sha256.Size]byte, data []byte) error {
+	if w.seen[h] {
+		return nil
+	}
+	w.seen[h] = true
+	w.entries = append(w.entries, packEntry{hash: h, offset: w.offset, length: uint64(len(data))})
+	return w.write(data)
+}
+
+// finish writes the index and moves both files into place, the index last
+// so that readers never see an index without its pack.
+func (w *packWriter) finish(root string) (string, error) {
+	if err := w.f.Sync(); err != nil {
+		return "", fmt.Errorf("writing packfile: %w", err)
+	}
+	if err := w.f.Close(); err != nil {
+		return "", fmt.Errorf("writing packfile: %w", err)
+	}
+	id := hex.EncodeToString(w.hash.Sum(nil))
+
+	sort.Slice(w.entries, func(i, j int) bool { return bytes.Compare(w.entries[i].hash[:], w.entries[j].hash[:]) < 0 })
+	idx := make([]byte, 0, idxSize(int64(len(w.entries))))
+	idx = append(idx, idxHeader...)
+	idx = binary.BigEndian.AppendUint32(idx, uint32(len(w.entries)))
+	for _, e := range w.entries {
+		idx = append(idx, e.hash[:]...)
+		idx = binary.BigEndian.AppendUint64(idx, e.offset)
+		idx = binary.BigEndian.AppendUint64(idx, e.length)
+	}
+	sum := sha256.Sum256(idx)
+	idx = append(idx, sum[:]...)
+
+	if err := os.Chmod(w.f.Name(), 0444); err != nil {
+		return "", fmt.Errorf("writing packfile: %w", err)
+	}
+	if err := os.Rename(w.f.Name(), packfilePath(root, id, ".pack")); err != nil {
+		return "", fmt.Errorf("finalizing packfile: %w", err)
+	}
+	idxTmp := packfilePath(root, id, ".idx.tmp")
+	if err := os.WriteFile(idxTmp, idx, 0444); err != nil {
+		return "", fmt.Errorf("writing packfile index: %w", err)
+	}
+	if err := os.Rename(idxTmp, packfilePath(root, id, ".idx")); err != nil {
+		os.Remove(idxTmp)
+		return "", fmt.Errorf("finalizing packfile index: %w", err)
+	}
+	return id, nil
+}
@@ -0,0 +1,159 @@
+package store
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func looseCount(t *testing.T, root string) int {
+	t.Helper()
+	n := 0
+	filepath.WalkDir(filepath.Join(root, "objects"), func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			n++
+		}
+		return nil
+	})
+	return n
+}
+
+func TestPackObjects(t *testing.T) {
+	root := setupTestStore(t)
+	contents := map[string][]byte{}
+	for _, c := range []string{"alpha", "beta", "gamma", strings.Repeat("compressible\n", 300)} {
+		ref, _ := WriteBlob(root, []byte(c))
+		contents[ref] = []byte(c)
+	}
+	big := randomBytes(2*ChunkThreshold, 7)
+	bigRef, _ := WriteBlob(root, big)
+	contents[bigRef] = big
+	CompressObjects(root, CompressionZstd)
+	objects := looseCount(t, root)
+
+	stats, err := PackObjects(root, false)
+	if err != nil {
+		t.Fatalf("PackObjects failed: %v", err)
+	}
+	if stats.Objects != objects || stats.LooseRemoved != objects {
+		t.Errorf("expected all %d objects packed and removed, got %+v", objects, stats)
+	}
+	if n := looseCount(t, root); n != 0 {
+		t.Errorf("expected no loose objects left, got %d", n)
+	}
+	if err := VerifyPackfile(root, stats.Packfile); err != nil {
+		t.Errorf("VerifyPackfile failed: %v", err)
+	}
+
+	for ref, want := range contents {
+		if !BlobExists(root, ref) {
+			t.Errorf("expected %s to exist in the packfile", ShortHash(ref, 12))
+		}
+		got, err := ReadBlob(root, ref)
+		if err != nil || !bytes.Equal(got, want) {
+			t.Errorf("expected %s readable from the packfile, got error %v", ShortHash(ref, 12), err)
+		}
+	}
+	missing := "sha256:" + strings.Repeat("00", 32)
+	if BlobExists(root, missing) {
+		t.Error("expected an absent blob not to exist")
+	}
+	if _, err := ReadBlob(root, missing); err == nil || !strings.Contains(err.Error(), "blob not found") {
+		t.Errorf("expected blob not found, got %v", err)
+	}
+
+	// Writing packed content again does not create a loose copy
+	WriteBlob(root, []byte("alpha"))
+	if n := looseCount(t, root); n != 0 {
+		t.Errorf("expected packed content to be deduplicated, got %d loose objects", n)
+	}
+}
+
+func TestPackObjectsAll(t *testing.T) {
+	root := setupTestStore(t)
+	a, _ := WriteBlob(root, []byte("first"))
+	PackObjects(root, false)
+	b, _ := WriteBlob(root, []byte("second"))
+	PackObjects(root, false)
+
+	ids, _ := ListPackfiles(root)
+	if len(ids) != 2 {
+		t.Fatalf("expected two packfiles, got %v", ids)
+	}
+
+	stats, err := PackObjects(root, true)
+	if err != nil {
+		t.Fatalf("PackObjects failed: %v", err)
+	}
+	if stats.Objects != 2 || stats.PacksReplaced != 2 {
+		t.Errorf("expected both packfiles merged, got %+v", stats)
+	}
+	ids, _ = ListPackfiles(root)
+	if len(ids) != 1 || ids[0] != stats.Packfile {
+		t.Errorf("expected only the merged packfile, got %v", ids)
+	}
+	for _, ref := range []string{a, b} {
+		if _, err := ReadBlob(root, ref); err != nil {
+			t.Errorf("ReadBlob failed after merge: %v", err)
+		}
+	}
+}
+
+func TestPackObjectsSkipsCorruptLoose(t *testing.T) {
+	root := setupTestStore(t)
+	good, _ := WriteBlob(root, []byte("good"))
+	bad, _ := WriteBlob(root, []byte("bad"))
+	path, _ := blobPath(root, bad)
+	os.Chmod(path, 0644)
+	os.WriteFile(path, []byte("tampered"), 0644)
+
+	stats, err := PackObjects(root, false)
+	if err != nil {
+		t.Fatalf("PackObjects failed: %v", err)
+	}
+	if stats.Objects != 1 {
+		t.Errorf("expected only the good object packed, got %+v", stats)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Error("expected the corrupt object left loose")
+	}
+	if _, err := ReadBlob(root, good); err != nil {
+		t.Errorf("ReadBlob failed: %v", err)
+	}
+}
+
+func TestCorruptPackfileIndex(t *testing.T) {
+	root := setupTestStore(t)
+	ref, _ := WriteBlob(root, []byte("indexed content"))
+	stats, err := PackObjects(root, false)
+	if err != nil {
+		t.Fatalf("PackObjects failed: %v", err)
+	}
+	idxPath := packfilePath(root, stats.Packfile, ".idx")
+	idx, _ := os.ReadFile(idxPath)
+	os.Chmod(idxPath, 0644)
+
+	// An entry whose length runs past the end of the pack is refused
+	bad := bytes.Clone(idx)
+	lengthAt := len(idxHeader) + 4 + sha256.Size + 8
+	binary.BigEndian.PutUint64(bad[lengthAt:], 1<<62)
+	os.WriteFile(idxPath, bad, 0644)
+	if _, err := ReadBlob(root, ref); err == nil || !strings.Contains(err.Error(), "outside") {
+		t.Errorf("expected an out-of-bounds entry to be refused, got %v", err)
+	}
+	if err := VerifyPackfile(root, stats.Packfile); err == nil {
+		t.Error("expected VerifyPackfile to catch the altered index")
+	}
+
+	// A count that does not match the file size is refused
+	bad = bytes.Clone(idx)
+	binary.BigEndian.PutUint32(bad[len(idxHeader):], 1<<20)
+	os.WriteFile(idxPath, bad, 0644)
+	if _, err := ReadBlob(root, ref); err == nil || !strings.Contains(err.Error(), "expected") {
+		t.Errorf("expected a mismatched entry count to be refused, got %v", err)
+	}
+}
@@ -0,0 +1,266 @@
+// Package textdiff computes line-oriented differences between two texts.
+package textdiff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// OpKind identifies how a line participates in a diff.
+type OpKind int
+
+const (
+	Equal OpKind = iota
+	Delete
+	Insert
+)
+
+// Line is a single line of a diff. OldLine and NewLine are 1-based line numbers
+// in the respective texts, or 0 when the line does not appear there.
+type Line struct {
+	Kind    OpKind
+	Text    string
+	OldLine int
+	NewLine int
+}
+
+// SplitLines splits text into lines without their terminating newlines.
+// A trailing newline does not produce an extra empty line.
+func SplitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.Split(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// Lines returns the line-by-line edit script that turns a into b, based on the
+// longest common subsequence of lines.
+func Lines(a, b string) []Line {
+	return diffSeq(SplitLines(a), SplitLines(b))
+}
+
+// Strings returns the edit script that turns one sequence of strings into
+// another. OldLine and NewLine are 1-based positions in a and b.
+func Strings(a, b []string) []Line {
+	return diffSeq(a, b)
+}
+
+// MaxCells bounds the LCS table diffSeq builds over the changed middle of
+// two sequences. Beyond it the middle is reported as deleted and inserted
+// whole, which keeps memory bounded but is not a minimal edit script.
+const MaxCells = 4 << 20
+
+// LinesFit reports whether Lines(a, b) stays within MaxCells and so returns
+// a minimal edit script.
+func LinesFit(a, b string) bool {
+	return fits(SplitLines(a), SplitLines(b))
+}
+
+// WordsFit reports whether Words(a, b) stays within MaxCells.
+func WordsFit(a, b string) bool {
+	return fits(SplitWords(a), SplitWords(b))
+}
+
+func fits(a, b []string) bool {
+	pre, suf := trimCommon(a, b)
+	return cells(len(a)-pre-suf, len(b)-pre-suf) <= MaxCells
+}
+
+func cells(n, m int) int64 {
+	return int64(n+1) * int64(m+1)
+}
+
+// trimCommon returns the lengths of the common prefix and suffix of a and b.
+func trimCommon(a, b []string) (pre, suf int) {
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+	return pre, suf
+}
+
+// diffSeq computes an LCS edit script over two sequences of tokens.
+func diffSeq(a, b []string) []Line {
+	// Trim common prefix and suffix so the LCS table only covers the changed middle.
+	pre, suf := trimCommon(a, b)
+	ma, mb := a[pre:len(a)-suf], b[pre:len(b)-suf]
+
+	// lcs[i][j] is the LCS length of ma[i:] and mb[j:]. Without a table,
+	// every line of the middle counts as changed.
+	tooLarge := cells(len(ma), len(mb)) > MaxCells
+	lcs := make([][]int32, len(ma)+1)
+	for i := range lcs {
+		if tooLarge {
+			break
+		}
+		lcs[i] = make([]int32, len(mb)+1)
+	}
+	for i := len(ma) - 1; i >= 0 && !tooLarge; i-- {
+		for j := len(mb) - 1; j >= 0; j-- {
+			switch {
+			case ma[i] == mb[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	ops := make([]Line, 0, len(a)+len(b)-pre-suf)
+	oldN, newN := 0, 0
+	equal := func(text string) {
+		oldN++
+		newN++
+		ops = append(ops, Line{Kind: Equal, Text: text, OldLine: oldN, NewLine: newN})
+	}
+
+	for _, text := range a[:pre] {
+		equal(text)
+	}
+	i, j := 0, 0
+	for i < len(ma) || j < len(mb) {
+		switch {
+		case !tooLarge && i < len(ma) && j < len(mb) && ma[i] == mb[j]:
+			equal(ma[i])
+			i++
+			j++
+		case j == len(mb) || (i < len(ma) && (tooLarge || lcs[i+1][j] >= lcs[i][j+1])):
+			oldN++
+			ops = append(ops, Line{Kind: Delete, Text: ma[i], OldLine: oldN})
+			i++
+		default:
+			newN++
+			ops = append(ops, Line{Kind: Insert, Text: mb[j], NewLine: newN})
+			j++
+		}
+	}
+	for _, text := range a[len(a)-suf:] {
+		equal(text)
+	}
+
+	return ops
+}
+
+// HasChanges reports whether an edit script contains any insertions or deletions.
+func HasChanges(ops []Line) bool {
+	for _, op := range ops {
+		if op.Kind != Equal {
+			return true
+		}
+	}
+	return false
+}
+
+// Unified renders the difference between a and b in unified diff format with
+// the given number of context lines around each change. Returns an empty string
+// when the texts are identical.
+func Unified(a, b string, context int) string {
+	return FormatUnified(Lines(a, b), context)
+}
+
+// FormatUnified renders an edit script as unified diff hunks.
+func FormatUnified(ops []Line, context int) string {
+	if !HasChanges(ops) {
+		return ""
+	}
+
+	var sb strings.Builder
+	for _, h := range hunks(ops, context) {
+		oldStart, oldCount, newStart, newCount := h.span()
+		sb.WriteString(fmt.Sprintf("@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount))
+		for _, op := range h.ops {
+			switch op.Kind {
+			case Equal:
+				sb.WriteString(" ")
+			case Delete:
+				sb.WriteString("-")
+			case Insert:
+				sb.WriteString("+")
+			}
+			sb.WriteString(op.Text)
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String()
+}
+
+// hunk is a contiguous slice of an edit script together with the number of
+// old and new lines that precede it.
+type hunk struct {
+	ops              []Line
+	oldBase, newBase int
+}
+
+// span returns the unified-diff header ranges for a hunk. An empty side is
+// anchored at the line before the hunk, as in GNU diff.
+func (h hunk) span() (oldStart, oldCount, newStart, newCount int) {
+	for _, op := range h.ops {
+		if op.Kind != Insert {
+			oldCount++
+		}
+		if op.Kind != Delete {
+			newCount++
+		}
+	}
+	oldStart, newStart = h.oldBase, h.newBase
+	if oldCount > 0 {
+		oldStart++
+	}
+	if newCount > 0 {
+		newStart++
+	}
+	return
+}
+
+// hunks groups changed lines with up to context lines of surrounding equal lines,
+// merging groups whose context would overlap.
+func hunks(ops []Line, context int) []hunk {
+	var result []hunk
+	emit := func(start, end int) {
+		h := hunk{ops: ops[start:end]}
+		for _, op := range ops[:start] {
+			if op.Kind != Insert {
+				h.oldBase++
+			}
+			if op.Kind != Delete {
+				h.newBase++
+			}
+		}
+		result = append(result, h)
+	}
+
+	start, end := -1, -1
+	for i, op := range ops {
+		if op.Kind == Equal {
+			continue
+		}
+		lo := i - context
+		if lo < 0 {
+			lo = 0
+		}
+		hi := i + context + 1
+		if hi > len(ops) {
+			hi = len(ops)
+		}
+		if start >= 0 && lo <= end {
+			end = hi
+			continue
+		}
+		if start >= 0 {
+			emit(start, end)
+		}
+		start, end = lo, hi
+	}
+	if start >= 0 {
+		emit(start, end)
+	}
+	return result
+}
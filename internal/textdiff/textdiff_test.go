@@ -0,0 +1,113 @@
+package textdiff
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestLinesIdentical(t *testing.T) {
+	ops := Lines("a\nb\n", "a\nb\n")
+	if HasChanges(ops) {
+		t.Error("expected no changes for identical text")
+	}
+	if Unified("a\nb\n", "a\nb\n", 3) != "" {
+		t.Error("expected empty unified diff for identical text")
+	}
+}
+
+func TestLinesSingleChange(t *testing.T) {
+	ops := Lines("a\nb\nc\n", "a\nB\nc\n")
+
+	var deleted, inserted []string
+	for _, op := range ops {
+		switch op.Kind {
+		case Delete:
+			deleted = append(deleted, op.Text)
+		case Insert:
+			inserted = append(inserted, op.Text)
+		}
+	}
+	if len(deleted) != 1 || deleted[0] != "b" {
+		t.Errorf("expected deletion of b, got %v", deleted)
+	}
+	if len(inserted) != 1 || inserted[0] != "B" {
+		t.Errorf("expected insertion of B, got %v", inserted)
+	}
+}
+
+func TestUnifiedFormat(t *testing.T) {
+	got := Unified("one\ntwo\nthree\n", "one\n2\nthree\n", 1)
+	want := "@@ -1,3 +1,3 @@\n one\n-two\n+2\n three\n"
+	if got != want {
+		t.Errorf("unexpected unified diff:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestUnifiedSeparateHunks(t *testing.T) {
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n"
+	b := "1\nX\n3\n4\n5\n6\n7\n8\nY\n10\n"
+	got := Unified(a, b, 1)
+	want := "@@ -1,3 +1,3 @@\n 1\n-2\n+X\n 3\n@@ -8,3 +8,3 @@\n 8\n-9\n+Y\n 10\n"
+	if got != want {
+		t.Errorf("unexpected hunks:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestUnifiedInsertionOnly(t *testing.T) {
+	got := Unified("", "new\n", 3)
+	want := "@@ -0,0 +1,1 @@\n+new\n"
+	if got != want {
+		t.Errorf("unexpected diff for insertion into empty text:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestSplitWordsRoundTrip(t *testing.T) {
+	s := "  Hello,  world\nagain "
+	tokens := SplitWords(s)
+	joined := ""
+	for _, tok := range tokens {
+		joined += tok
+	}
+	if joined != s {
+		t.Errorf("expected tokens to rejoin to %q, got %q", s, joined)
+	}
+	if len(tokens) != 7 {
+		t.Errorf("expected 7 tokens, got %d: %q", len(tokens), tokens)
+	}
+}
+
+func TestFormatWords(t *testing.T) {
+	got := FormatWords(Words("You are a helpful assistant.", "You are a concise assistant."))
+	want := "You are a [-helpful-]{+concise+} assistant."
+	if got != want {
+		t.Errorf("unexpected word diff:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestLinesBeyondMaxCells(t *testing.T) {
+	var a, b strings.Builder
+	for i := 0; i < 3000; i++ {
+		fmt.Fprintf(&a, "a%d\n", i)
+		fmt.Fprintf(&b, "b%d\n", i)
+	}
+	if LinesFit(a.String(), b.String()) {
+		t.Fatal("expected 3000x3000 change to exceed MaxCells")
+	}
+	if !LinesFit("x\n"+a.String(), "y\n"+a.String()) {
+		t.Error("expected common suffix to be trimmed before sizing")
+	}
+
+	var deleted, inserted int
+	for _, op := range Lines(a.String(), b.String()) {
+		switch op.Kind {
+		case Delete:
+			deleted++
+		case Insert:
+			inserted++
+		}
+	}
+	if deleted != 3000 || inserted != 3000 {
+		t.Errorf("expected whole middle replaced, got %d deleted, %d inserted", deleted, inserted)
+	}
+}
@@ -0,0 +1,62 @@
+package textdiff
+
+import (
+	"strings"
+	"unicode"
+)
+
+// SplitWords splits text into alternating runs of non-space and space
+// characters, so that joining the tokens reproduces the text exactly.
+func SplitWords(s string) []string {
+	var tokens []string
+	start, space := 0, false
+	for i, r := range s {
+		if i > start && unicode.IsSpace(r) != space {
+			tokens = append(tokens, s[start:i])
+			start = i
+		}
+		if i == start {
+			space = unicode.IsSpace(r)
+		}
+	}
+	if start < len(s) {
+		tokens = append(tokens, s[start:])
+	}
+	return tokens
+}
+
+// Words returns the word-by-word edit script that turns a into b. Each op's
+// Text is a word or a run of whitespace; OldLine and NewLine count tokens.
+func Words(a, b string) []Line {
+	return diffSeq(SplitWords(a), SplitWords(b))
+}
+
+// FormatWords renders a word edit script inline, marking deleted text as
+// [-text-] and inserted text as {+text+}.
+func FormatWords(ops []Line) string {
+	var sb, del, ins strings.Builder
+	flush := func() {
+		if del.Len() > 0 {
+			sb.WriteString("[-" + del.String() + "-]")
+			del.Reset()
+		}
+		if ins.Len() > 0 {
+			sb.WriteString("{+" + ins.String() + "+}")
+			ins.Reset()
+		}
+	}
+
+	for _, op := range ops {
+		switch op.Kind {
+		case Equal:
+			flush()
+			sb.WriteString(op.Text)
+		case Delete:
+			del.WriteString(op.Text)
+		case Insert:
+			ins.WriteString(op.Text)
+		}
+	}
+	flush()
+	return sb.String()
+}
@@ -0,0 +1,111 @@
+package tui
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/term"
+)
+
+// Key is a key press: a single character, or one of the named keys below.
+type Key string
+
+// Named keys.
+const (
+	KeyUp        Key = "up"
+	KeyDown      Key = "down"
+	KeyLeft      Key = "left"
+	KeyRight     Key = "right"
+	KeyPgUp      Key = "pgup"
+	KeyPgDown    Key = "pgdown"
+	KeyHome      Key = "home"
+	KeyEnd       Key = "end"
+	KeyEnter     Key = "enter"
+	KeyEsc       Key = "esc"
+	KeyBackspace Key = "backspace"
+	KeyCtrlC     Key = "ctrl+c"
+)
+
+// escapeKeys maps the final bytes of CSI sequences to keys.
+var escapeKeys = map[string]Key{
+	"A": KeyUp, "B": KeyDown, "C": KeyRight, "D": KeyLeft,
+	"H": KeyHome, "F": KeyEnd, "1~": KeyHome, "4~": KeyEnd,
+	"5~": KeyPgUp, "6~": KeyPgDown,
+}
+
+// ReadKey reads one key press from a terminal in raw mode.
+func ReadKey(r *bufio.Reader) (Key, error) {
+	c, _, err := r.ReadRune()
+	if err != nil {
+		return "", err
+	}
+	switch c {
+	case '\r', '\n':
+		return KeyEnter, nil
+	case 127, '\b':
+		return KeyBackspace, nil
+	case 3:
+		return KeyCtrlC, nil
+	case 27:
+		// A lone escape has nothing buffered behind it
+		if r.Buffered() == 0 {
+			return KeyEsc, nil
+		}
+		next, _ := r.ReadByte()
+		if next != '[' && next != 'O' {
+			return KeyEsc, nil
+		}
+		var seq []byte
+		for {
+			b, err := r.ReadByte()
+			if err != nil {
+				return KeyEsc, nil
+			}
+			seq = append(seq, b)
+			if b >= 0x40 && b <= 0x7e {
+				break
+			}
+		}
+		if k, ok := escapeKeys[string(seq)]; ok {
+			return k, nil
+		}
+		return KeyEsc, nil
+	}
+	return Key(string(c)), nil
+}
+
+// Run takes over the terminal and runs the app until the user quits. The
+// terminal is switched to raw mode and restored on exit.
+func Run(a *App, in *os.File, out io.Writer) error {
+	fd := int(in.Fd())
+	saved, err := term.MakeRaw(fd)
+	if err != nil {
+		return fmt.Errorf("ctx tui needs an interactive terminal: %w", err)
+	}
+	defer term.Restore(fd, saved)
+
+	// Alternate screen, hidden cursor
+	fmt.Fprint(out, "\x1b[?1049h\x1b[?25l")
+	defer fmt.Fprint(out, "\x1b[?25h\x1b[?1049l")
+
+	reader := bufio.NewReader(in)
+	for {
+		if cols, rows, err := term.GetSize(fd); err == nil {
+			a.Height, a.Width = rows, cols
+		}
+		fmt.Fprint(out, "\x1b[H\x1b[2J"+a.Render())
+
+		k, err := ReadKey(reader)
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("reading input: %w", err)
+		}
+		if a.HandleKey(k) {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,511 @@
+// Package tui implements ctx tui, a keyboard-driven terminal browser for
+// packs, diffs and the indexed context graph. Raw mode and the window size
+// come from golang.org/x/term; the screen is drawn with ANSI escape sequences.
+package tui
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/contextsubstrate/ctx/internal/diff"
+	"github.com/contextsubstrate/ctx/internal/graph"
+	"github.com/contextsubstrate/ctx/internal/pack"
+	"github.com/contextsubstrate/ctx/internal/sharing"
+	"github.com/contextsubstrate/ctx/internal/store"
+)
+
+// previewLines caps how much of a blob is shown in a preview pane.
+const previewLines = 200
+
+// item is one selectable row of a view. Ref, if set, is a blob previewed
+// when the row is selected; Open, if set, is called when it is entered.
+type item struct {
+	Text string
+	Ref  string
+	Open func() (*view, error)
+}
+
+// view is a scrollable, searchable list of rows.
+type view struct {
+	title  string
+	items  []item
+	query  string
+	cursor int // Index into visible()
+	offset int
+	packs  bool // The pack list, where packs can be marked for diffing
+}
+
+// visible returns the indexes of the items matching the search query.
+func (v *view) visible() []int {
+	var idx []int
+	q := strings.ToLower(v.query)
+	for i, it := range v.items {
+		if q == "" || strings.Contains(strings.ToLower(it.Text), q) {
+			idx = append(idx, i)
+		}
+	}
+	return idx
+}
+
+// selected returns the item under the cursor, or nil if nothing is visible.
+func (v *view) selected() *item {
+	idx := v.visible()
+	if v.cursor < 0 || v.cursor >= len(idx) {
+		return nil
+	}
+	return &v.items[idx[v.cursor]]
+}
+
+// App is the state of a terminal session: a stack of views, the packs
+// marked for diffing and a cache of loaded blob previews.
+type App struct {
+	root      string
+	stack     []*view
+	marked    []string
+	previews  map[string][]string
+	searching bool
+	status    string
+	Width     int
+	Height    int
+}
+
+// New loads the pack list for a store and returns an app showing it.
+func New(storeRoot string) (*App, error) {
+	a := &App{root: storeRoot, previews: make(map[string][]string), Width: 80, Height: 24}
+	v, err := a.packList()
+	if err != nil {
+		return nil, err
+	}
+	a.stack = []*view{v}
+	return a, nil
+}
+
+func (a *App) top() *view { return a.stack[len(a.stack)-1] }
+
+func (a *App) packList() (*view, error) {
+	summaries, err := sharing.ListPacks(a.root, 0)
+	if err != nil {
+		return nil, err
+	}
+	v := &view{title: fmt.Sprintf("Packs (%d)", len(summaries)), packs: true}
+	for _, s := range summaries {
+		hash := s.Hash
+		text := fmt.Sprintf("%s  %s  %-20s %3d steps", store.ShortHash(hash, 12), s.Created, s.Model, s.Steps)
+		if s.Parent != "" {
+			text += "  ← " + store.ShortHash(s.Parent, 12)
+		}
+		v.items = append(v.items, item{Text: text, Ref: hash, Open: func() (*view, error) { return a.packDetail(hash) }})
+	}
+	return v, nil
+}
+
+func (a *App) packDetail(hash string) (*view, error) {
+	p, err := pack.LoadPack(a.root, hash)
+	if err != nil {
+		return nil, err
+	}
+	v := &view{title: fmt.Sprintf("Pack %s · %s · %s", store.ShortHash(p.Hash, 12), p.Model.Identifier, p.Created.Format("2006-01-02 15:04:05"))}
+	add := func(text, ref string) { v.items = append(v.items, item{Text: text, Ref: ref}) }
+
+	add("system prompt", p.SystemPrompt)
+	for i, pr := range p.Prompts {
+		add(fmt.Sprintf("prompt %d (%s)", i, pr.Role), pr.ContentRef)
+	}
+	for _, in := range p.Inputs {
+		if in.MediaType != "" {
+			add(fmt.Sprintf("input  %s (%s, %d bytes)", in.Name, in.MediaType, in.Size), in.ContentRef)
+		} else {
+			add(fmt.Sprintf("input  %s (%d bytes)", in.Name, in.Size), in.ContentRef)
+		}
+	}
+	for _, s := range p.Steps {
+		params, _ := json.Marshal(s.Parameters)
+		text := fmt.Sprintf("step %d %s %s", s.Index, s.Tool, params)
+		if s.Agent != "" {
+			text += " [" + s.Agent + "]"
+		}
+		it := item{Text: text, Ref: s.OutputRef}
+		if s.ChildPack != "" {
+			child := s.ChildPack
+			it.Open = func() (*view, error) { return a.packDetail(child) }
+		}
+		v.items = append(v.items, it)
+	}
+	for _, out := range p.Outputs {
+		if out.MediaType != "" {
+			add(fmt.Sprintf("output %s (%s)", out.Name, out.MediaType), out.ContentRef)
+		} else {
+			add("output "+out.Name, out.ContentRef)
+		}
+	}
+	if p.Parent != "" {
+		parent := p.Parent
+		v.items = append(v.items, item{Text: "parent " + store.ShortHash(parent, 12), Open: func() (*view, error) { return a.packDetail(parent) }})
+	}
+	return v, nil
+}
+
+func (a *App) diffView(hashA, hashB string) (*view, error) {
+	report, err := diff.Diff(a.root, hashA, hashB)
+	if err != nil {
+		return nil, err
+	}
+	return textView(fmt.Sprintf("Diff %s → %s", report.PackHashA, report.PackHashB), report.Human()), nil
+}
+
+func (a *App) commitList() (*view, error) {
+	commits, err := graph.ReadRecords[graph.CommitRecord](graph.CommitsPath(a.root))
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(commits, func(i, j int) bool { return commits[i].AuthoredAt.After(commits[j].AuthoredAt) })
+
+	v := &view{title: fmt.Sprintf("Indexed commits (%d)", len(commits))}
+	for _, c := range commits {
+		sha := c.SHA
+		text := fmt.Sprintf("%s  %s  %-16s %s", shortSHA(sha), c.AuthoredAt.Format("2006-01-02"), c.Author, firstLine(c.Message))
+		v.items = append(v.items, item{Text: text, Open: func() (*view, error) { return a.commitView(sha) }})
+	}
+	return v, nil
+}
+
+func (a *App) commitView(sha string) (*view, error) {
+	paths, err := graph.ReadRecords[graph.PathRecord](graph.PathsPath(a.root))
+	if err != nil {
+		return nil, err
+	}
+	files, err := graph.ReadRecords[graph.FileSnapshot](graph.FilesPath(a.root, sha))
+	if err != nil {
+		return nil, err
+	}
+	symbols, err := graph.ReadRecords[graph.SymbolRecord](graph.SymbolsPath(a.root, sha))
+	if err != nil {
+		return nil, err
+	}
+	imports, err := graph.ReadRecords[graph.ImportEdge](graph.ImportEdgesPath(a.root, sha))
+	if err != nil {
+		return nil, err
+	}
+	calls, err := graph.ReadRecords[graph.CallEdge](graph.CallEdgesPath(a.root, sha))
+	if err != nil {
+		return nil, err
+	}
+
+	pathNames := make(map[string]string)
+	for _, p := range paths {
+		pathNames[p.PathID] = p.Path
+	}
+	symbolNames := make(map[string]string)
+	for _, s := range symbols {
+		symbolNames[s.SymbolID] = s.FQName
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Files (%d)\n", len(files))
+	var fileRows []string
+	for _, f := range files {
+		fileRows = append(fileRows, fmt.Sprintf("  file    %-48s %-12s %6d LOC", pathNames[f.PathID], f.Language, f.LOC))
+	}
+	sort.Strings(fileRows)
+	for _, row := range fileRows {
+		b.WriteString(row + "\n")
+	}
+	fmt.Fprintf(&b, "Symbols (%d)\n", len(symbols))
+	for _, s := range symbols {
+		fmt.Fprintf(&b, "  symbol  %-10s %s  (%s)\n", s.Kind, s.FQName, pathNames[s.PathID])
+	}
+	fmt.Fprintf(&b, "Import edges (%d)\n", len(imports))
+	for _, e := range imports {
+		to := pathNames[e.ToPathID]
+		if to == "" {
+			to = e.ToExternalModule
+		}
+		fmt.Fprintf(&b, "  import  %s → %s\n", pathNames[e.FromPathID], to)
+	}
+	fmt.Fprintf(&b, "Call edges (%d)\n", len(calls))
+	for _, e := range calls {
+		to := symbolNames[e.ToSymbolID]
+		if to == "" {
+			to = e.ToExternalRef
+		}
+		fmt.Fprintf(&b, "  call    %s → %s\n", symbolNames[e.FromSymbolID], to)
+	}
+	return textView("Commit "+shortSHA(sha), b.String()), nil
+}
+
+// textView turns multi-line text into a view with one row per line.
+func textView(title, text string) *view {
+	v := &view{title: title}
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		v.items = append(v.items, item{Text: line})
+	}
+	return v
+}
+
+// preview returns the lines of a blob, loading it on first use.
+func (a *App) preview(ref string) []string {
+	if lines, ok := a.previews[ref]; ok {
+		return lines
+	}
+	var lines []string
+	data, err := store.ReadBlob(a.root, ref)
+	switch {
+	case err != nil:
+		lines = []string{fmt.Sprintf("(unavailable: %v)", err)}
+	case bytes.IndexByte(data, 0) >= 0 || !utf8.Valid(data):
+		lines = []string{fmt.Sprintf("(binary content, %d bytes)", len(data))}
+	default:
+		lines = strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+		if len(lines) > previewLines {
+			lines = append(lines[:previewLines], fmt.Sprintf("… (%d more lines)", len(lines)-previewLines))
+		}
+	}
+	a.previews[ref] = lines
+	return lines
+}
+
+// HandleKey applies a key press and reports whether the app should exit.
+func (a *App) HandleKey(k Key) bool {
+	v := a.top()
+	a.status = ""
+
+	if a.searching {
+		switch k {
+		case KeyEnter:
+			a.searching = false
+		case KeyEsc:
+			a.searching = false
+			v.query = ""
+		case KeyBackspace:
+			if v.query != "" {
+				_, size := utf8.DecodeLastRuneInString(v.query)
+				v.query = v.query[:len(v.query)-size]
+			}
+		default:
+			if utf8.RuneCountInString(string(k)) == 1 {
+				v.query += string(k)
+			}
+		}
+		v.cursor, v.offset = 0, 0
+		return false
+	}
+
+	n := len(v.visible())
+	page := a.listHeight()
+	switch k {
+	case "q", KeyCtrlC:
+		return true
+	case KeyUp, "k":
+		v.cursor--
+	case KeyDown, "j":
+		v.cursor++
+	case KeyPgUp:
+		v.cursor -= page
+	case KeyPgDown, " ":
+		if k == " " && v.packs {
+			a.toggleMark()
+			break
+		}
+		v.cursor += page
+	case KeyHome, "g":
+		v.cursor = 0
+	case KeyEnd, "G":
+		v.cursor = n - 1
+	case "/":
+		a.searching = true
+	case KeyEsc, KeyBackspace, KeyLeft, "h":
+		if v.query != "" {
+			v.query = ""
+			v.cursor, v.offset = 0, 0
+		} else if len(a.stack) > 1 {
+			a.stack = a.stack[:len(a.stack)-1]
+		}
+		return false
+	case KeyEnter, KeyRight, "l":
+		if it := v.selected(); it != nil && it.Open != nil {
+			a.push(it.Open)
+		}
+	case "d":
+		a.diffMarked()
+	case "c":
+		a.push(a.commitList)
+	}
+
+	if v.cursor >= n {
+		v.cursor = n - 1
+	}
+	if v.cursor < 0 {
+		v.cursor = 0
+	}
+	return false
+}
+
+// push opens a new view on top of the stack, reporting errors in the status line.
+func (a *App) push(open func() (*view, error)) {
+	next, err := open()
+	if err != nil {
+		a.status = err.Error()
+		return
+	}
+	a.stack = append(a.stack, next)
+}
+
+// toggleMark marks or unmarks the selected pack. At most two packs stay
+// marked; marking a third drops the oldest.
+func (a *App) toggleMark() {
+	it := a.top().selected()
+	if it == nil {
+		return
+	}
+	for i, h := range a.marked {
+		if h == it.Ref {
+			a.marked = append(a.marked[:i], a.marked[i+1:]...)
+			return
+		}
+	}
+	a.marked = append(a.marked, it.Ref)
+	if len(a.marked) > 2 {
+		a.marked = a.marked[1:]
+	}
+}
+
+func (a *App) diffMarked() {
+	if len(a.marked) != 2 {
+		a.status = "mark two packs with space to diff them"
+		return
+	}
+	hashA, hashB := a.marked[0], a.marked[1]
+	a.push(func() (*view, error) { return a.diffView(hashA, hashB) })
+}
+
+func (a *App) isMarked(hash string) bool {
+	for _, h := range a.marked {
+		if h == hash {
+			return true
+		}
+	}
+	return false
+}
+
+// listHeight is the number of rows available to the list, after the title,
+// footer and any preview pane.
+func (a *App) listHeight() int {
+	h := a.Height - 3
+	if a.hasPreview() {
+		h = (a.Height - 3) / 2
+	}
+	if h < 1 {
+		h = 1
+	}
+	return h
+}
+
+func (a *App) hasPreview() bool {
+	v := a.top()
+	return !v.packs && v.selected() != nil && v.selected().Ref != ""
+}
+
+// Render draws the current screen as Height lines of at most Width columns.
+func (a *App) Render() string {
+	v := a.top()
+	var lines []string
+
+	title := v.title
+	if v.query != "" {
+		title += fmt.Sprintf("  [/%s]", v.query)
+	}
+	lines = append(lines, "\x1b[1m"+clip(title, a.Width)+"\x1b[0m")
+
+	idx := v.visible()
+	height := a.listHeight()
+	if v.cursor < v.offset {
+		v.offset = v.cursor
+	}
+	if v.cursor >= v.offset+height {
+		v.offset = v.cursor - height + 1
+	}
+	for row := 0; row < height; row++ {
+		i := v.offset + row
+		if i >= len(idx) {
+			lines = append(lines, "")
+			continue
+		}
+		it := v.items[idx[i]]
+		prefix := "  "
+		if v.packs && a.isMarked(it.Ref) {
+			prefix = "* "
+		}
+		text := clip(prefix+it.Text, a.Width)
+		if i == v.cursor {
+			text = "\x1b[7m" + text + "\x1b[0m"
+		}
+		lines = append(lines, text)
+	}
+
+	if a.hasPreview() {
+		lines = append(lines, "\x1b[2m"+strings.Repeat("─", a.Width)+"\x1b[0m")
+		body := a.preview(v.selected().Ref)
+		for row := 0; row < a.Height-3-height; row++ {
+			if row < len(body) {
+				lines = append(lines, clip(body[row], a.Width))
+			} else {
+				lines = append(lines, "")
+			}
+		}
+	}
+
+	lines = append(lines, "\x1b[2m"+clip(a.footer(), a.Width)+"\x1b[0m")
+	return strings.Join(lines, "\r\n")
+}
+
+func (a *App) footer() string {
+	switch {
+	case a.searching:
+		return "/" + a.top().query + "_"
+	case a.status != "":
+		return a.status
+	case a.top().packs:
+		return "↑↓ move · enter open · space mark · d diff marked · c commits · / search · q quit"
+	default:
+		return "↑↓ move · enter open · esc back · / search · q quit"
+	}
+}
+
+// clip truncates s to width runes. Tabs become spaces so columns stay
+// aligned, and other control characters are replaced so blob content cannot
+// drive the terminal.
+func clip(s string, width int) string {
+	s = strings.ReplaceAll(s, "\t", "    ")
+	s = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return '·'
+		}
+		return r
+	}, s)
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	r := []rune(s)
+	if width < 1 {
+		return ""
+	}
+	return string(r[:width-1]) + "…"
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 12 {
+		return sha[:12]
+	}
+	return sha
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
@@ -0,0 +1,192 @@
+package tui
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/contextsubstrate/ctx/internal/graph"
+	"github.com/contextsubstrate/ctx/internal/pack"
+)
+
+func setupTestStore(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	root := filepath.Join(dir, ".ctx")
+	os.MkdirAll(filepath.Join(root, "objects"), 0755)
+	os.MkdirAll(filepath.Join(root, "packs"), 0755)
+	os.MkdirAll(filepath.Join(root, "refs"), 0755)
+	return root
+}
+
+func createPack(t *testing.T, root string, output string) *pack.Pack {
+	t.Helper()
+	log := &pack.ExecutionLog{
+		Model:        pack.LogModel{Identifier: "test-model"},
+		SystemPrompt: "You are helpful.",
+		Prompts:      []pack.LogPrompt{{Role: "user", Content: "do something"}},
+		Steps: []pack.LogStep{
+			{Index: 0, Type: "tool_call", Tool: "echo", Parameters: map[string]interface{}{"text": output}, Output: output + "\nsecond line", Deterministic: true},
+		},
+		Environment: pack.LogEnvironment{OS: "linux", Runtime: "go1.22", ToolVersions: map[string]string{}},
+	}
+	p, err := pack.CreatePack(root, log)
+	if err != nil {
+		t.Fatalf("CreatePack failed: %v", err)
+	}
+	if err := pack.RegisterPack(root, p.Hash); err != nil {
+		t.Fatalf("RegisterPack failed: %v", err)
+	}
+	return p
+}
+
+func press(a *App, keys ...Key) {
+	for _, k := range keys {
+		a.HandleKey(k)
+	}
+}
+
+func TestPackDetailPreview(t *testing.T) {
+	root := setupTestStore(t)
+	createPack(t, root, "hello")
+
+	a, err := New(root)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if !strings.Contains(a.Render(), "Packs (1)") {
+		t.Fatal("expected the pack list to be shown first")
+	}
+
+	press(a, KeyEnter)
+	if len(a.previews) != 0 {
+		t.Error("expected blobs to load only when previewed")
+	}
+	// system prompt, prompt 0, step 0
+	press(a, KeyDown, KeyDown)
+	screen := a.Render()
+	if !strings.Contains(screen, `step 0 echo {"text":"hello"}`) {
+		t.Errorf("expected the step row, got:\n%s", screen)
+	}
+	if !strings.Contains(screen, "second line") {
+		t.Errorf("expected the step output preview, got:\n%s", screen)
+	}
+	if len(a.previews) != 1 {
+		t.Errorf("expected exactly one blob loaded, got %d", len(a.previews))
+	}
+
+	press(a, KeyEsc)
+	if !a.top().packs {
+		t.Error("expected esc to return to the pack list")
+	}
+	if !a.HandleKey("q") {
+		t.Error("expected q to quit")
+	}
+}
+
+func TestDiffMarked(t *testing.T) {
+	root := setupTestStore(t)
+	createPack(t, root, "alpha")
+	createPack(t, root, "beta")
+
+	a, err := New(root)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	press(a, "d")
+	if !strings.Contains(a.Render(), "mark two packs") {
+		t.Error("expected a hint when diffing without marks")
+	}
+
+	press(a, " ", KeyDown, " ")
+	if len(a.marked) != 2 {
+		t.Fatalf("expected 2 marked packs, got %d", len(a.marked))
+	}
+	if strings.Count(a.Render(), "* ") != 2 {
+		t.Error("expected both marked packs to be flagged")
+	}
+
+	press(a, "d")
+	if !strings.HasPrefix(a.top().title, "Diff ") {
+		t.Fatalf("expected the diff view, got %q", a.top().title)
+	}
+	if !strings.Contains(a.Render(), "drift") {
+		t.Errorf("expected drift in the diff view, got:\n%s", a.Render())
+	}
+}
+
+func TestSearch(t *testing.T) {
+	root := setupTestStore(t)
+	createPack(t, root, "alpha")
+
+	a, err := New(root)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	press(a, KeyEnter, "/", "e", "c", "h", "o", KeyEnter)
+	v := a.top()
+	if len(v.visible()) != 1 || !strings.Contains(v.selected().Text, "echo") {
+		t.Fatalf("expected the search to leave only the echo step, got %d rows", len(v.visible()))
+	}
+	if !strings.Contains(a.Render(), "[/echo]") {
+		t.Error("expected the active query in the title")
+	}
+
+	press(a, KeyEsc)
+	if v.query != "" || a.top() != v {
+		t.Error("expected esc to clear the search before leaving the view")
+	}
+}
+
+func TestCommitBrowser(t *testing.T) {
+	root := setupTestStore(t)
+	sha := strings.Repeat("ab", 20)
+	graph.AppendRecord(graph.CommitsPath(root), graph.CommitRecord{Type: graph.TypeCommit, SHA: sha, Author: "dev", Message: "initial\n\nbody", AuthoredAt: time.Unix(0, 0)})
+	graph.AppendRecord(graph.PathsPath(root), graph.PathRecord{Type: graph.TypePath, PathID: "p1", Path: "main.go"})
+	graph.AppendRecord(graph.FilesPath(root, sha), graph.FileSnapshot{PathID: "p1", Language: "go", LOC: 10})
+	graph.AppendRecord(graph.SymbolsPath(root, sha), graph.SymbolRecord{SymbolID: "s1", PathID: "p1", Kind: "function", FQName: "main.main"})
+
+	a, err := New(root)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	press(a, "c")
+	screen := a.Render()
+	if !strings.Contains(screen, "ababababab") || !strings.Contains(screen, "initial") || strings.Contains(screen, "body") {
+		t.Errorf("expected the commit with its subject line, got:\n%s", screen)
+	}
+
+	press(a, KeyEnter)
+	screen = a.Render()
+	for _, want := range []string{"main.go", "main.main", "Call edges (0)"} {
+		if !strings.Contains(screen, want) {
+			t.Errorf("expected %q in commit view, got:\n%s", want, screen)
+		}
+	}
+}
+
+func TestReadKey(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("\x1b[Aq\r\x1b[6~\x7fé\x03"))
+	want := []Key{KeyUp, "q", KeyEnter, KeyPgDown, KeyBackspace, "é", KeyCtrlC}
+	for i, w := range want {
+		k, err := ReadKey(r)
+		if err != nil {
+			t.Fatalf("ReadKey %d failed: %v", i, err)
+		}
+		if k != w {
+			t.Errorf("key %d: expected %q, got %q", i, w, k)
+		}
+	}
+}
+
+func TestClip(t *testing.T) {
+	if got := clip("abcdef", 4); got != "abc…" {
+		t.Errorf("expected truncation, got %q", got)
+	}
+	if got := clip("a\x1b[2Jb", 10); strings.ContainsRune(got, 0x1b) {
+		t.Errorf("expected control characters to be replaced, got %q", got)
+	}
+}
@@ -0,0 +1,411 @@
+// Package ui serves a local, read-only web interface for browsing a ctx
+// store: packs, their blobs and fork lineage, diffs between packs, and the
+// indexed context graph.
+package ui
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/contextsubstrate/ctx/internal/diff"
+	"github.com/contextsubstrate/ctx/internal/graph"
+	"github.com/contextsubstrate/ctx/internal/htmlreport"
+	"github.com/contextsubstrate/ctx/internal/pack"
+	"github.com/contextsubstrate/ctx/internal/sharing"
+	"github.com/contextsubstrate/ctx/internal/store"
+)
+
+// DefaultAddr is the address ctx ui listens on unless told otherwise. It is
+// bound to loopback so the store is not exposed to the network.
+const DefaultAddr = "127.0.0.1:8787"
+
+// maxGraphRows caps each table on a commit page.
+const maxGraphRows = 500
+
+const nav = `<a href="/">Packs</a><a href="/graph">Context graph</a>`
+
+// Handler returns the UI's HTTP handler for a store served at addr.
+// Requests must name a loopback host or addr itself in their Host header,
+// so a page on another site cannot reach the store through DNS rebinding.
+func Handler(storeRoot, addr string) http.Handler {
+	s := &server{root: storeRoot}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", s.packs)
+	mux.HandleFunc("GET /packs/{hash}", s.pack)
+	mux.HandleFunc("GET /blobs/{hash}", s.blob)
+	mux.HandleFunc("GET /diff", s.diff)
+	mux.HandleFunc("GET /graph", s.commits)
+	mux.HandleFunc("GET /graph/{sha}", s.commit)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !allowedHost(r.Host, addr) {
+			http.Error(w, "unexpected Host header", http.StatusForbidden)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// allowedHost reports whether a request's Host names localhost, a loopback
+// address, or the address the UI was bound to.
+func allowedHost(host, addr string) bool {
+	if host == addr {
+		return true
+	}
+	name := host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		name = h
+	}
+	name = strings.TrimSuffix(strings.TrimPrefix(name, "["), "]")
+	if strings.EqualFold(name, "localhost") {
+		return true
+	}
+	if ip := net.ParseIP(name); ip != nil && ip.IsLoopback() {
+		return true
+	}
+	if bound, _, err := net.SplitHostPort(addr); err == nil && bound != "" {
+		return strings.EqualFold(name, bound)
+	}
+	return false
+}
+
+type server struct {
+	root string
+}
+
+// page renders a template body inside the shared report document.
+func (s *server) page(w http.ResponseWriter, title string, body template.HTML) {
+	data, err := htmlreport.Document(title, template.HTML(nav), body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(data)
+}
+
+// execute renders a UI template into a page.
+func (s *server) execute(w http.ResponseWriter, title string, t *template.Template, data interface{}) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.page(w, title, template.HTML(buf.String()))
+}
+
+var funcs = template.FuncMap{
+	"short": func(hash string) string { return store.ShortHash(hash, 12) },
+}
+
+var packsTemplate = template.Must(template.New("packs").Funcs(funcs).Parse(`
+<h1>Context packs ({{len .}})</h1>
+{{if .}}
+<form action="/diff" method="get">
+Compare
+<select name="a">{{range .}}<option value="{{.Hash}}">{{short .Hash}} · {{.Created}}</option>{{end}}</select>
+with
+<select name="b">{{range .}}<option value="{{.Hash}}">{{short .Hash}} · {{.Created}}</option>{{end}}</select>
+<button type="submit">Diff</button>
+</form>
+<table>
+<tr><th>Pack</th><th>Created</th><th>Model</th><th>Steps</th><th>Tokens</th><th>Forked from</th></tr>
+{{range .}}<tr>
+<td class="mono"><a href="/packs/{{.Hash}}">{{short .Hash}}</a></td>
+<td>{{.Created}}</td><td class="mono">{{.Model}}</td><td>{{.Steps}}</td>
+<td>{{if .Tokens}}{{.Tokens}}{{end}}</td>
+<td class="mono">{{with .Parent}}<a href="/packs/{{.}}">{{short .}}</a>{{end}}</td>
+</tr>{{end}}
+</table>
+{{else}}<p>No context packs found.</p>{{end}}
+`))
+
+func (s *server) packs(w http.ResponseWriter, r *http.Request) {
+	summaries, err := sharing.ListPacks(s.root, 0)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.execute(w, "Context packs", packsTemplate, summaries)
+}
+
+var lineageTemplate = template.Must(template.New("lineage").Funcs(funcs).Parse(`
+<p class="muted">
+<a href="/blobs/{{.Hash}}">manifest</a>
+{{if .Ancestors}} · forked from {{range $i, $a := .Ancestors}}{{if $i}} ← {{end}}<a class="mono" href="/packs/{{$a}}">{{short $a}}</a>{{end}}{{with .Change}} ({{.}}){{end}}{{end}}
+{{if .Children}} · forks: {{range $i, $c := .Children}}{{if $i}}, {{end}}<a class="mono" href="/packs/{{$c}}">{{short $c}}</a>{{end}}{{end}}
+{{with .Parent}} · <a href="/diff?a={{.}}&b={{$.Hash}}">diff against parent</a>{{end}}
+</p>
+`))
+
+func (s *server) pack(w http.ResponseWriter, r *http.Request) {
+	p, err := pack.LoadPack(s.root, r.PathValue("hash"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	lineage, err := s.lineage(p)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var head bytes.Buffer
+	if err := lineageTemplate.Execute(&head, lineage); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	body, err := htmlreport.PackBody(s.root, p)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.page(w, "Pack "+store.ShortHash(p.Hash, 12), template.HTML(head.String())+body)
+}
+
+type lineage struct {
+	Hash      string
+	Parent    string
+	Change    string
+	Ancestors []string // Nearest first
+	Children  []string
+}
+
+// lineage summarizes a pack's fork family for the pack page.
+func (s *server) lineage(p *pack.Pack) (*lineage, error) {
+	family, err := sharing.BuildLineage(s.root, p.Hash)
+	if err != nil {
+		return nil, err
+	}
+	l := &lineage{Hash: p.Hash, Parent: p.Parent, Change: family.Target.Change}
+	for i := len(family.Ancestors) - 1; i >= 0; i-- {
+		l.Ancestors = append(l.Ancestors, family.Ancestors[i].Hash)
+	}
+	for _, c := range family.Target.Children {
+		l.Children = append(l.Children, c.Hash)
+	}
+	return l, nil
+}
+
+func (s *server) blob(w http.ResponseWriter, r *http.Request) {
+	ref, err := store.NormalizeHash(r.PathValue("hash"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	data, err := store.ReadBlob(s.root, ref)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if bytes.IndexByte(data, 0) >= 0 || !utf8.Valid(data) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+	} else {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Write(data)
+}
+
+func (s *server) diff(w http.ResponseWriter, r *http.Request) {
+	a, b := r.URL.Query().Get("a"), r.URL.Query().Get("b")
+	if a == "" || b == "" {
+		http.Error(w, "diff requires the a and b query parameters", http.StatusBadRequest)
+		return
+	}
+
+	report, err := diff.Diff(s.root, a, b)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	body, err := htmlreport.DiffBody(s.root, report)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.page(w, "Drift "+report.PackHashA+" vs "+report.PackHashB, body)
+}
+
+var commitsTemplate = template.Must(template.New("commits").Parse(`
+<h1>Context graph</h1>
+{{if .}}<table>
+<tr><th>Commit</th><th>Authored</th><th>Author</th><th>Message</th></tr>
+{{range .}}<tr>
+<td class="mono"><a href="/graph/{{.SHA}}">{{slice .SHA 0 12}}</a></td>
+<td>{{.AuthoredAt.Format "2006-01-02 15:04"}}</td><td>{{.Author}}</td><td>{{.Message}}</td>
+</tr>{{end}}
+</table>
+{{else}}<p>No commits indexed. Run <code>ctx index</code> first.</p>{{end}}
+`))
+
+func (s *server) commits(w http.ResponseWriter, r *http.Request) {
+	commits, err := graph.ReadRecords[graph.CommitRecord](graph.CommitsPath(s.root))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sort.SliceStable(commits, func(i, j int) bool { return commits[i].AuthoredAt.After(commits[j].AuthoredAt) })
+	s.execute(w, "Context graph", commitsTemplate, commits)
+}
+
+var commitTemplate = template.Must(template.New("commit").Parse(`
+<h1>Commit <span class="mono">{{.SHA}}</span></h1>
+
+<h2>Files ({{.FileCount}})</h2>
+<table class="mono">
+<tr><th>Path</th><th>Language</th><th>LOC</th><th>Bytes</th></tr>
+{{range .Files}}<tr><td>{{.Path}}</td><td>{{.Language}}</td><td>{{.LOC}}</td><td>{{.Size}}</td></tr>{{end}}
+</table>
+
+<h2>Symbols ({{.SymbolCount}})</h2>
+<table class="mono">
+<tr><th>Kind</th><th>Name</th><th>File</th></tr>
+{{range .Symbols}}<tr><td>{{.Kind}}</td><td title="{{.Signature}}">{{.Name}}</td><td>{{.Path}}</td></tr>{{end}}
+</table>
+
+<h2>Import edges ({{.ImportCount}})</h2>
+<table class="mono">
+{{range .Imports}}<tr><td>{{.From}}</td><td>→</td><td>{{.To}}</td></tr>{{end}}
+</table>
+
+<h2>Call edges ({{.CallCount}})</h2>
+<table class="mono">
+{{range .Calls}}<tr><td>{{.From}}</td><td>→</td><td>{{.To}}</td></tr>{{end}}
+</table>
+{{if .Truncated}}<p class="muted">Tables are limited to {{.Limit}} rows.</p>{{end}}
+`))
+
+type fileRow struct {
+	Path, Language string
+	LOC, Size      int
+}
+
+type symbolRow struct {
+	Kind, Name, Signature, Path string
+}
+
+type edgeRow struct {
+	From, To string
+}
+
+func (s *server) commit(w http.ResponseWriter, r *http.Request) {
+	sha := r.PathValue("sha")
+	if !isCommitSHA(sha) {
+		http.Error(w, "invalid commit SHA", http.StatusBadRequest)
+		return
+	}
+
+	paths, err := graph.ReadRecords[graph.PathRecord](graph.PathsPath(s.root))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	files, err := graph.ReadRecords[graph.FileSnapshot](graph.FilesPath(s.root, sha))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if files == nil {
+		http.Error(w, fmt.Sprintf("commit %s is not indexed", sha), http.StatusNotFound)
+		return
+	}
+	symbols, err := graph.ReadRecords[graph.SymbolRecord](graph.SymbolsPath(s.root, sha))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	imports, err := graph.ReadRecords[graph.ImportEdge](graph.ImportEdgesPath(s.root, sha))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	calls, err := graph.ReadRecords[graph.CallEdge](graph.CallEdgesPath(s.root, sha))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	pathNames := make(map[string]string)
+	for _, p := range paths {
+		pathNames[p.PathID] = p.Path
+	}
+	symbolNames := make(map[string]string)
+	for _, sym := range symbols {
+		symbolNames[sym.SymbolID] = sym.FQName
+	}
+
+	data := struct {
+		SHA                                            string
+		FileCount, SymbolCount, ImportCount, CallCount int
+		Files                                          []fileRow
+		Symbols                                        []symbolRow
+		Imports, Calls                                 []edgeRow
+		Truncated                                      bool
+		Limit                                          int
+	}{
+		SHA:         sha,
+		FileCount:   len(files),
+		SymbolCount: len(symbols),
+		ImportCount: len(imports),
+		CallCount:   len(calls),
+		Limit:       maxGraphRows,
+	}
+
+	for _, f := range files {
+		data.Files = append(data.Files, fileRow{Path: pathNames[f.PathID], Language: f.Language, LOC: f.LOC, Size: f.ByteSize})
+	}
+	sort.Slice(data.Files, func(i, j int) bool { return data.Files[i].Path < data.Files[j].Path })
+	for _, sym := range symbols {
+		data.Symbols = append(data.Symbols, symbolRow{Kind: sym.Kind, Name: sym.FQName, Signature: sym.Signature, Path: pathNames[sym.PathID]})
+	}
+	for _, e := range imports {
+		to := pathNames[e.ToPathID]
+		if to == "" {
+			to = e.ToExternalModule
+		}
+		data.Imports = append(data.Imports, edgeRow{From: pathNames[e.FromPathID], To: to})
+	}
+	for _, e := range calls {
+		to := symbolNames[e.ToSymbolID]
+		if to == "" {
+			to = e.ToExternalRef
+		}
+		data.Calls = append(data.Calls, edgeRow{From: symbolNames[e.FromSymbolID], To: to})
+	}
+
+	data.Files, data.Truncated = truncate(data.Files, data.Truncated)
+	data.Symbols, data.Truncated = truncate(data.Symbols, data.Truncated)
+	data.Imports, data.Truncated = truncate(data.Imports, data.Truncated)
+	data.Calls, data.Truncated = truncate(data.Calls, data.Truncated)
+
+	s.execute(w, "Commit "+sha[:12], commitTemplate, data)
+}
+
+// truncate caps rows at maxGraphRows, reporting whether anything was cut.
+func truncate[T any](rows []T, truncated bool) ([]T, bool) {
+	if len(rows) > maxGraphRows {
+		return rows[:maxGraphRows], true
+	}
+	return rows, truncated
+}
+
+// isCommitSHA reports whether s looks like a full hex commit SHA, which also
+// keeps it safe to use as a snapshot directory name.
+func isCommitSHA(s string) bool {
+	if len(s) < 40 {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
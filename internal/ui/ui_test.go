@@ -0,0 +1,221 @@
+package ui
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/contextsubstrate/ctx/internal/graph"
+	"github.com/contextsubstrate/ctx/internal/pack"
+	"github.com/contextsubstrate/ctx/internal/sharing"
+	"github.com/contextsubstrate/ctx/internal/store"
+)
+
+func setupTestStore(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	root := filepath.Join(dir, ".ctx")
+	os.MkdirAll(filepath.Join(root, "objects"), 0755)
+	os.MkdirAll(filepath.Join(root, "packs"), 0755)
+	os.MkdirAll(filepath.Join(root, "refs"), 0755)
+	return root
+}
+
+func createPack(t *testing.T, root string, output string) *pack.Pack {
+	t.Helper()
+	log := &pack.ExecutionLog{
+		Model:        pack.LogModel{Identifier: "test-model"},
+		SystemPrompt: "You are helpful.",
+		Prompts:      []pack.LogPrompt{{Role: "user", Content: "do something"}},
+		Steps: []pack.LogStep{
+			{Index: 0, Type: "tool_call", Tool: "echo", Parameters: map[string]interface{}{"text": output}, Output: output, Deterministic: true},
+		},
+		Outputs:     []pack.LogOutput{{Name: "result.txt", Content: output}},
+		Environment: pack.LogEnvironment{OS: "linux", Runtime: "go1.22", ToolVersions: map[string]string{}},
+	}
+	p, err := pack.CreatePack(root, log)
+	if err != nil {
+		t.Fatalf("CreatePack failed: %v", err)
+	}
+	if err := pack.RegisterPack(root, p.Hash); err != nil {
+		t.Fatalf("RegisterPack failed: %v", err)
+	}
+	return p
+}
+
+// request builds a GET request addressed to the default UI address.
+func request(url string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, url, nil)
+	r.Host = DefaultAddr
+	return r
+}
+
+func get(t *testing.T, h http.Handler, url string) (int, string) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, request(url))
+	body, _ := io.ReadAll(rec.Result().Body)
+	return rec.Code, string(body)
+}
+
+func TestPackListAndPage(t *testing.T) {
+	root := setupTestStore(t)
+	parent := createPack(t, root, "hello")
+
+	draft, err := sharing.Fork(root, parent.Hash)
+	if err != nil {
+		t.Fatalf("Fork failed: %v", err)
+	}
+	child, err := sharing.FinalizeDraft(root, draft)
+	if err != nil {
+		t.Fatalf("FinalizeDraft failed: %v", err)
+	}
+
+	h := Handler(root, DefaultAddr)
+
+	code, body := get(t, h, "/")
+	if code != http.StatusOK {
+		t.Fatalf("expected 200 for pack list, got %d", code)
+	}
+	if !strings.Contains(body, "/packs/"+parent.Hash) || !strings.Contains(body, "/packs/"+child.Hash) {
+		t.Error("expected both packs to be linked from the list")
+	}
+
+	code, body = get(t, h, "/packs/"+parent.Hash)
+	if code != http.StatusOK {
+		t.Fatalf("expected 200 for pack page, got %d", code)
+	}
+	if !strings.Contains(body, "forks:") || !strings.Contains(body, "/packs/"+child.Hash) {
+		t.Error("expected the parent page to link its fork")
+	}
+
+	code, body = get(t, h, "/packs/"+child.Hash)
+	if code != http.StatusOK {
+		t.Fatalf("expected 200 for child page, got %d", code)
+	}
+	if !strings.Contains(body, "forked from") || !strings.Contains(body, "diff against parent") {
+		t.Error("expected the child page to show its ancestry")
+	}
+
+	if code, _ := get(t, h, "/packs/sha256:"+strings.Repeat("0", 64)); code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown pack, got %d", code)
+	}
+}
+
+func TestBlob(t *testing.T) {
+	root := setupTestStore(t)
+	text, _ := store.WriteBlob(root, []byte("plain text"))
+	binary, _ := store.WriteBlob(root, []byte{0, 1, 2})
+	h := Handler(root, DefaultAddr)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, request("/blobs/"+text))
+	if rec.Code != http.StatusOK || rec.Body.String() != "plain text" {
+		t.Errorf("unexpected text blob response: %d %q", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("expected text/plain, got %q", ct)
+	}
+	if opt := rec.Header().Get("X-Content-Type-Options"); opt != "nosniff" {
+		t.Errorf("expected nosniff, got %q", opt)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, request("/blobs/"+binary))
+	if ct := rec.Header().Get("Content-Type"); ct != "application/octet-stream" {
+		t.Errorf("expected application/octet-stream, got %q", ct)
+	}
+
+	if code, _ := get(t, h, "/blobs/not-a-hash"); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid hash, got %d", code)
+	}
+}
+
+func TestDiffPage(t *testing.T) {
+	root := setupTestStore(t)
+	a := createPack(t, root, "alpha")
+	b := createPack(t, root, "beta")
+	h := Handler(root, DefaultAddr)
+
+	code, body := get(t, h, "/diff?a="+a.Hash+"&b="+b.Hash)
+	if code != http.StatusOK {
+		t.Fatalf("expected 200 for diff, got %d", code)
+	}
+	if !strings.Contains(body, "alpha") || !strings.Contains(body, "beta") {
+		t.Error("expected both outputs in the diff page")
+	}
+
+	if code, _ := get(t, h, "/diff?a="+a.Hash); code != http.StatusBadRequest {
+		t.Errorf("expected 400 without b, got %d", code)
+	}
+}
+
+func TestGraphPages(t *testing.T) {
+	root := setupTestStore(t)
+	sha := strings.Repeat("ab", 20)
+	records := map[string][]any{
+		graph.CommitsPath(root):    {graph.CommitRecord{Type: graph.TypeCommit, SHA: sha, Author: "dev", Message: "initial", AuthoredAt: time.Unix(0, 0)}},
+		graph.PathsPath(root):      {graph.PathRecord{Type: graph.TypePath, PathID: "p1", Path: "main.go"}},
+		graph.FilesPath(root, sha): {graph.FileSnapshot{Commit: sha, PathID: "p1", Language: "go", LOC: 10}},
+		graph.SymbolsPath(root, sha): {
+			graph.SymbolRecord{Commit: sha, SymbolID: "s1", PathID: "p1", Kind: "function", FQName: "main.main"},
+		},
+		graph.CallEdgesPath(root, sha): {graph.CallEdge{Commit: sha, FromSymbolID: "s1", ToExternalRef: "fmt.Println"}},
+	}
+	for path, recs := range records {
+		if err := graph.WriteRecords(path, recs); err != nil {
+			t.Fatalf("WriteRecords failed: %v", err)
+		}
+	}
+	h := Handler(root, DefaultAddr)
+
+	code, body := get(t, h, "/graph")
+	if code != http.StatusOK || !strings.Contains(body, "/graph/"+sha) {
+		t.Fatalf("expected the commit to be listed, got %d", code)
+	}
+
+	code, body = get(t, h, "/graph/"+sha)
+	if code != http.StatusOK {
+		t.Fatalf("expected 200 for commit page, got %d", code)
+	}
+	for _, want := range []string{"main.go", "main.main", "fmt.Println"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected %q in commit page", want)
+		}
+	}
+
+	if code, _ := get(t, h, "/graph/../packs"); code == http.StatusOK {
+		t.Error("expected a non-SHA commit path to be rejected")
+	}
+	if code, _ := get(t, h, "/graph/"+strings.Repeat("cd", 20)); code != http.StatusNotFound {
+		t.Errorf("expected 404 for unindexed commit, got %d", code)
+	}
+}
+
+func TestHostCheck(t *testing.T) {
+	root := setupTestStore(t)
+	h := Handler(root, "192.168.1.5:9000")
+
+	for host, want := range map[string]int{
+		"localhost:9000":    http.StatusOK,
+		"127.0.0.1:9000":    http.StatusOK,
+		"[::1]:9000":        http.StatusOK,
+		"192.168.1.5:9000":  http.StatusOK,
+		"evil.example:9000": http.StatusForbidden,
+		"evil.example":      http.StatusForbidden,
+		"":                  http.StatusForbidden,
+	} {
+		rec := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Host = host
+		h.ServeHTTP(rec, r)
+		if rec.Code != want {
+			t.Errorf("Host %q: expected %d, got %d", host, want, rec.Code)
+		}
+	}
+}
@@ -12,6 +12,7 @@ import (
 
 type SidecarMetadata struct {
 	ContextPack string   `json:"context_pack"`
+	MediaType   string   `json:"media_type,omitempty"`
 	Inputs      []string `json:"inputs"`
 	Tools       []string `json:"tools"`
 	Confidence  string   `json:"confidence,omitempty"`
@@ -72,6 +73,7 @@ func GenerateSidecars(p *pack.Pack, outputDir string) (int, error) {
 		sidecarPath := SidecarPath(filepath.Join(outputDir, out.Name))
 		meta := &SidecarMetadata{
 			ContextPack: p.Hash,
+			MediaType:   out.MediaType,
 			Inputs:      inputRefs,
 			Tools:       tools,
 		}
@@ -89,10 +91,12 @@ type VerifyResult struct {
 	ArtifactPath    string
 	PackHash        string
 	PackCreated     string
+	MediaType       string
 	Tools           []string
 	ContentMatch    bool
 	ContentExpected string
 	ContentActual   string
+	Redacted        bool
 	Confidence      string
 	Notes           string
 }
@@ -132,9 +136,11 @@ func Verify(storeRoot string, artifactPath string) (*VerifyResult, error) {
 	baseName := filepath.Base(artifactPath)
 	for _, out := range p.Outputs {
 		if out.Name == baseName {
+			result.MediaType = out.MediaType
 			result.ContentExpected = out.ContentRef
 			result.ContentActual = actualHash
 			result.ContentMatch = (actualHash == out.ContentRef)
+			result.Redacted = p.Redacted(fmt.Sprintf("outputs[%s]", out.Name))
 			break
 		}
 	}
@@ -148,6 +154,9 @@ func FormatVerifyResult(r *VerifyResult) string {
 	s += fmt.Sprintf("Artifact:  %s\n", r.ArtifactPath)
 	s += fmt.Sprintf("Pack:      %s\n", store.ShortHash(r.PackHash, 12))
 	s += fmt.Sprintf("Created:   %s\n", r.PackCreated)
+	if r.MediaType != "" {
+		s += fmt.Sprintf("Type:      %s\n", r.MediaType)
+	}
 
 	if len(r.Tools) > 0 {
 		s += fmt.Sprintf("Tools:     %v\n", r.Tools)
@@ -156,6 +165,10 @@ func FormatVerifyResult(r *VerifyResult) string {
 	if r.ContentExpected != "" {
 		if r.ContentMatch {
 			s += "Integrity: verified\n"
+		} else if r.Redacted {
+			// The pack holds the redacted output, so an artifact written
+			// before redaction can't match it.
+			s += "Integrity: unverifiable (output was redacted when packed)\n"
 		} else {
 			s += fmt.Sprintf("Integrity: modified (expected %s, actual %s)\n",
 				store.ShortHash(r.ContentExpected, 12),
@@ -1,8 +1,10 @@
 package verify
 
 import (
+	"encoding/base64"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/contextsubstrate/ctx/internal/pack"
@@ -238,3 +240,74 @@ func TestFormatVerifyResult(t *testing.T) {
 		t.Error("expected non-empty output")
 	}
 }
+
+func TestGenerateSidecarsBinaryOutput(t *testing.T) {
+	root := setupTestStore(t)
+	png := []byte("\x89PNG\r\n\x1a\n\x00binary")
+	log := &pack.ExecutionLog{
+		Model:        pack.LogModel{Identifier: "test-model"},
+		SystemPrompt: "test prompt",
+		Outputs:      []pack.LogOutput{{Name: "chart.png", Content: base64.StdEncoding.EncodeToString(png), Encoding: pack.EncodingBase64, MediaType: "image/png"}},
+		Environment:  pack.LogEnvironment{OS: "darwin", Runtime: "go1.22"},
+	}
+	p, err := pack.CreatePack(root, log)
+	if err != nil {
+		t.Fatalf("CreatePack failed: %v", err)
+	}
+	pack.RegisterPack(root, p.Hash)
+
+	outputDir := t.TempDir()
+	artifactPath := filepath.Join(outputDir, "chart.png")
+	os.WriteFile(artifactPath, png, 0644)
+	GenerateSidecars(p, outputDir)
+
+	meta, err := ReadSidecar(SidecarPath(artifactPath))
+	if err != nil {
+		t.Fatalf("ReadSidecar failed: %v", err)
+	}
+	if meta.MediaType != "image/png" {
+		t.Errorf("expected the media type in the sidecar, got %q", meta.MediaType)
+	}
+
+	result, err := Verify(root, artifactPath)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if !result.ContentMatch || result.MediaType != "image/png" {
+		t.Errorf("expected the binary artifact to verify, got %+v", result)
+	}
+	if !strings.Contains(FormatVerifyResult(result), "Type:      image/png") {
+		t.Errorf("expected the media type in the summary:\n%s", FormatVerifyResult(result))
+	}
+}
+
+func TestVerifyRedactedOutput(t *testing.T) {
+	root := setupTestStore(t)
+	log := &pack.ExecutionLog{
+		Model:        pack.LogModel{Identifier: "test-model"},
+		SystemPrompt: "test prompt",
+		Outputs:      []pack.LogOutput{{Name: "contacts.txt", Content: "alice@example.com\n"}},
+		Environment:  pack.LogEnvironment{OS: "darwin", Runtime: "go1.22"},
+	}
+	p, err := pack.CreatePack(root, log)
+	if err != nil {
+		t.Fatalf("CreatePack failed: %v", err)
+	}
+	pack.RegisterPack(root, p.Hash)
+
+	outputDir := t.TempDir()
+	artifactPath := filepath.Join(outputDir, "contacts.txt")
+	os.WriteFile(artifactPath, []byte("alice@example.com\n"), 0644)
+	GenerateSidecars(p, outputDir)
+
+	result, err := Verify(root, artifactPath)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if result.ContentMatch || !result.Redacted {
+		t.Errorf("expected a redacted mismatch, got %+v", result)
+	}
+	if !strings.Contains(FormatVerifyResult(result), "Integrity: unverifiable") {
+		t.Errorf("expected an unverifiable summary:\n%s", FormatVerifyResult(result))
+	}
+}